@@ -56,6 +56,9 @@ const (
 
 	// TableEvents is the events table name.
 	TableEvents = "event_v7"
+
+	// TableCrawlers is the crawlers table name.
+	TableCrawlers = "crawler_v7"
 )
 
 const (
@@ -20,7 +20,7 @@ const (
 	defaultMaxIdleConnections    = 5
 )
 
-// ClickHouse implements the Storage interface.
+// ClickHouse implements the Storage and CrawlerStorage interfaces.
 type ClickHouse struct {
 	clickhouse.Conn
 
@@ -94,6 +94,8 @@ func (ch *ClickHouse) SaveSessions(ctx context.Context, sessions []model.Session
 		site_id,
 		visitor_id,
 		session_id,
+		is_returning,
+		user_id,
 		time,
 		start,
 		duration_seconds,
@@ -108,6 +110,11 @@ func (ch *ClickHouse) SaveSessions(ctx context.Context, sessions []model.Session
 		country_code,
 		region,
 		city,
+		continent_code,
+		time_zone,
+		latitude,
+		longitude,
+		in_eu,
 		referrer,
 		referrer_name,
 		referrer_icon,
@@ -135,6 +142,8 @@ func (ch *ClickHouse) SaveSessions(ctx context.Context, sessions []model.Session
 			session.SiteID,
 			session.VisitorID,
 			session.SessionID,
+			session.IsReturning,
+			session.UserID,
 			session.Time.UnixMilli(),
 			session.Start,
 			session.DurationSeconds,
@@ -149,6 +158,11 @@ func (ch *ClickHouse) SaveSessions(ctx context.Context, sessions []model.Session
 			session.CountryCode,
 			session.Region,
 			session.City,
+			session.ContinentCode,
+			session.TimeZone,
+			session.Latitude,
+			session.Longitude,
+			session.InEU,
 			session.Referrer,
 			session.ReferrerName,
 			session.ReferrerIcon,
@@ -185,6 +199,8 @@ func (ch *ClickHouse) SavePageViews(ctx context.Context, pageViews []model.PageV
 	stmt, err := ch.PrepareBatch(ctx, `INSERT INTO "page_view_v7" (site_id,
 		visitor_id,
 		session_id,
+		is_returning,
+		user_id,
 		time,
 		hostname,
 		duration_seconds,
@@ -194,6 +210,11 @@ func (ch *ClickHouse) SavePageViews(ctx context.Context, pageViews []model.PageV
 		country_code,
 		region,
 		city,
+		continent_code,
+		time_zone,
+		latitude,
+		longitude,
+		in_eu,
 		referrer,
 		referrer_name,
 		referrer_icon,
@@ -219,6 +240,8 @@ func (ch *ClickHouse) SavePageViews(ctx context.Context, pageViews []model.PageV
 		if err := stmt.Append(pageView.SiteID,
 			pageView.VisitorID,
 			pageView.SessionID,
+			pageView.IsReturning,
+			pageView.UserID,
 			pageView.Time.UnixMilli(),
 			pageView.Hostname,
 			pageView.DurationSeconds,
@@ -228,6 +251,11 @@ func (ch *ClickHouse) SavePageViews(ctx context.Context, pageViews []model.PageV
 			pageView.CountryCode,
 			pageView.Region,
 			pageView.City,
+			pageView.ContinentCode,
+			pageView.TimeZone,
+			pageView.Latitude,
+			pageView.Longitude,
+			pageView.InEU,
 			pageView.Referrer,
 			pageView.ReferrerName,
 			pageView.ReferrerIcon,
@@ -265,6 +293,8 @@ func (ch *ClickHouse) SaveEvents(ctx context.Context, events []model.Event) erro
 		visitor_id,
 		time,
 		session_id,
+		is_returning,
+		user_id,
 		name,
 		meta_data,
 		hostname, 
@@ -274,6 +304,11 @@ func (ch *ClickHouse) SaveEvents(ctx context.Context, events []model.Event) erro
 		country_code, 
 		region, 
 		city, 
+		continent_code,
+		time_zone,
+		latitude,
+		longitude,
+		in_eu,
 		referrer, 
 		referrer_name,
 		referrer_icon,
@@ -299,6 +334,8 @@ func (ch *ClickHouse) SaveEvents(ctx context.Context, events []model.Event) erro
 			event.VisitorID,
 			event.Time.UnixMilli(),
 			event.SessionID,
+			event.IsReturning,
+			event.UserID,
 			event.Name,
 			ch.json(event.MetaData),
 			event.Hostname,
@@ -308,6 +345,11 @@ func (ch *ClickHouse) SaveEvents(ctx context.Context, events []model.Event) erro
 			event.CountryCode,
 			event.Region,
 			event.City,
+			event.ContinentCode,
+			event.TimeZone,
+			event.Latitude,
+			event.Longitude,
+			event.InEU,
 			event.Referrer,
 			event.ReferrerName,
 			event.ReferrerIcon,
@@ -347,6 +389,8 @@ func (ch *ClickHouse) SaveRequests(ctx context.Context, requests []model.Request
 		path,
 		query,
 		ip,
+		asn,
+		asn_organization,
 		user_agent,
 		headers,
 		event_name,
@@ -357,7 +401,12 @@ func (ch *ClickHouse) SaveRequests(ctx context.Context, requests []model.Request
 		utm_content,
 		utm_term,
 		bot,
-		bot_reason)`)
+		bot_reason,
+		crawler,
+		crawler_verified,
+		bot_score,
+		bot_reasons,
+		shadow_bot_reason)`)
 
 	if err != nil {
 		return err
@@ -371,6 +420,8 @@ func (ch *ClickHouse) SaveRequests(ctx context.Context, requests []model.Request
 			req.Path,
 			req.Query,
 			req.IP,
+			req.ASN,
+			req.ASNOrganization,
 			req.UserAgent,
 			req.Headers,
 			req.EventName,
@@ -381,7 +432,12 @@ func (ch *ClickHouse) SaveRequests(ctx context.Context, requests []model.Request
 			req.UTMContent,
 			req.UTMTerm,
 			req.Bot,
-			req.BotReason); err != nil {
+			req.BotReason,
+			req.Crawler,
+			req.CrawlerVerified,
+			req.BotScore,
+			req.BotReasons,
+			req.ShadowBotReason); err != nil {
 			return err
 		}
 	}
@@ -397,6 +453,43 @@ func (ch *ClickHouse) SaveRequests(ctx context.Context, requests []model.Request
 	return nil
 }
 
+// SaveCrawlers implements the CrawlerStorage interface.
+func (ch *ClickHouse) SaveCrawlers(ctx context.Context, crawlers []model.Crawler) error {
+	stmt, err := ch.PrepareBatch(ctx, `INSERT INTO "crawler_v7" (site_id,
+		time,
+		hostname,
+		path,
+		crawler,
+		category,
+		status)`)
+
+	if err != nil {
+		return err
+	}
+
+	for _, crawler := range crawlers {
+		if err := stmt.Append(crawler.SiteID,
+			crawler.Time.UnixMilli(),
+			crawler.Hostname,
+			crawler.Path,
+			crawler.Crawler,
+			crawler.Category,
+			crawler.Status); err != nil {
+			return err
+		}
+	}
+
+	if err := stmt.Send(); err != nil {
+		return err
+	}
+
+	if ch.debug {
+		ch.logger.Debug("crawlers saved", "count", len(crawlers))
+	}
+
+	return nil
+}
+
 // Session implements the Storage interface.
 func (ch *ClickHouse) Session(ctx context.Context, siteID, fingerprint uint64, maxAge time.Time) (*model.Session, error) {
 	query := `SELECT sign,
@@ -404,6 +497,8 @@ func (ch *ClickHouse) Session(ctx context.Context, siteID, fingerprint uint64, m
 		site_id,
 		visitor_id,
 		session_id,
+		is_returning,
+		user_id,
 		time,
 		start,
 		duration_seconds,
@@ -418,6 +513,11 @@ func (ch *ClickHouse) Session(ctx context.Context, siteID, fingerprint uint64, m
 		country_code,
 		region,
 		city,
+		continent_code,
+		time_zone,
+		latitude,
+		longitude,
+		in_eu,
 		referrer,
 		referrer_name,
 		referrer_icon,
@@ -446,6 +546,8 @@ func (ch *ClickHouse) Session(ctx context.Context, siteID, fingerprint uint64, m
 		&session.SiteID,
 		&session.VisitorID,
 		&session.SessionID,
+		&session.IsReturning,
+		&session.UserID,
 		&session.Time,
 		&session.Start,
 		&session.DurationSeconds,
@@ -460,6 +562,11 @@ func (ch *ClickHouse) Session(ctx context.Context, siteID, fingerprint uint64, m
 		&session.CountryCode,
 		&session.Region,
 		&session.City,
+		&session.ContinentCode,
+		&session.TimeZone,
+		&session.Latitude,
+		&session.Longitude,
+		&session.InEU,
 		&session.Referrer,
 		&session.ReferrerName,
 		&session.ReferrerIcon,
@@ -9,12 +9,13 @@ import (
 	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
 )
 
-// Mock implements the Storage interface.
+// Mock implements the Storage and CrawlerStorage interfaces.
 type Mock struct {
 	pageViews     []model.PageView
 	sessions      []model.Session
 	events        []model.Event
 	requests      []model.Request
+	crawlers      []model.Crawler
 	ReturnSession *model.Session
 	m             sync.Mutex
 }
@@ -26,6 +27,7 @@ func NewMock() *Mock {
 		sessions:  make([]model.Session, 0),
 		events:    make([]model.Event, 0),
 		requests:  make([]model.Request, 0),
+		crawlers:  make([]model.Crawler, 0),
 	}
 }
 
@@ -61,6 +63,14 @@ func (client *Mock) SaveRequests(_ context.Context, requests []model.Request) er
 	return nil
 }
 
+// SaveCrawlers implements the CrawlerStorage interface.
+func (client *Mock) SaveCrawlers(_ context.Context, crawlers []model.Crawler) error {
+	client.m.Lock()
+	defer client.m.Unlock()
+	client.crawlers = append(client.crawlers, crawlers...)
+	return nil
+}
+
 // Session implements the Storage interface.
 func (client *Mock) Session(context.Context, uint64, uint64, time.Time) (*model.Session, error) {
 	return client.ReturnSession, nil
@@ -122,3 +132,19 @@ func (client *Mock) Requests() []model.Request {
 	})
 	return data
 }
+
+// Crawlers returns a sorted copy of the crawler slice.
+func (client *Mock) Crawlers() []model.Crawler {
+	client.m.Lock()
+	defer client.m.Unlock()
+	data := make([]model.Crawler, len(client.crawlers))
+	copy(data, client.crawlers)
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].Time.Before(data[j].Time) {
+			return true
+		}
+
+		return false
+	})
+	return data
+}
@@ -0,0 +1 @@
+ALTER TABLE "request_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "shadow_bot_reason" LowCardinality(String);
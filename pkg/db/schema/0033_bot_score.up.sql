@@ -0,0 +1,2 @@
+ALTER TABLE "request_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "bot_score" Float64;
+ALTER TABLE "request_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "bot_reasons" Array(LowCardinality(String));
@@ -0,0 +1,2 @@
+ALTER TABLE "request_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "asn" UInt32;
+ALTER TABLE "request_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "asn_organization" LowCardinality(String);
@@ -0,0 +1,2 @@
+ALTER TABLE "request_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "crawler" LowCardinality(String);
+ALTER TABLE "request_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "crawler_verified" Bool;
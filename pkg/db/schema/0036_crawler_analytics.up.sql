@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS crawler_v7 {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} (
+    `site_id` UInt64,
+    `time` DateTime64(3, 'UTC'),
+    `hostname` String,
+    `path` String,
+    `crawler` LowCardinality(String),
+    `category` LowCardinality(String),
+    `status` LowCardinality(String)
+)
+ENGINE = {{if .Cluster}}ReplicatedMergeTree('/clickhouse/tables/crawler_v7/{shard}', '{replica}'){{else}}MergeTree{{end}}
+PARTITION BY toYYYYMM(time)
+ORDER BY (site_id, time)
+SETTINGS index_granularity = 8192;
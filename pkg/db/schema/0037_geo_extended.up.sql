@@ -0,0 +1,15 @@
+ALTER TABLE "session_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "continent_code" LowCardinality(String);
+ALTER TABLE "session_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "time_zone" LowCardinality(String);
+ALTER TABLE "session_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "latitude" Float32;
+ALTER TABLE "session_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "longitude" Float32;
+ALTER TABLE "session_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "in_eu" Bool;
+ALTER TABLE "page_view_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "continent_code" LowCardinality(String);
+ALTER TABLE "page_view_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "time_zone" LowCardinality(String);
+ALTER TABLE "page_view_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "latitude" Float32;
+ALTER TABLE "page_view_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "longitude" Float32;
+ALTER TABLE "page_view_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "in_eu" Bool;
+ALTER TABLE "event_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "continent_code" LowCardinality(String);
+ALTER TABLE "event_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "time_zone" LowCardinality(String);
+ALTER TABLE "event_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "latitude" Float32;
+ALTER TABLE "event_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "longitude" Float32;
+ALTER TABLE "event_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "in_eu" Bool;
@@ -0,0 +1,3 @@
+ALTER TABLE "session_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "is_returning" Bool;
+ALTER TABLE "page_view_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "is_returning" Bool;
+ALTER TABLE "event_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "is_returning" Bool;
@@ -0,0 +1,3 @@
+ALTER TABLE "session_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "user_id" UInt64;
+ALTER TABLE "page_view_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "user_id" UInt64;
+ALTER TABLE "event_v7" {{if .Cluster}}ON CLUSTER '{{.Cluster}}'{{end}} ADD COLUMN "user_id" UInt64;
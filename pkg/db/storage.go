@@ -24,3 +24,19 @@ type Storage interface {
 	// Session returns the last hit for a given client, fingerprint, and maximum age.
 	Session(context.Context, uint64, uint64, time.Time) (*model.Session, error)
 }
+
+// CrawlerStorage is an optional interface for a Storage to save crawler requests.
+type CrawlerStorage interface {
+	// SaveCrawlers saves given crawler requests.
+	SaveCrawlers(context.Context, []model.Crawler) error
+}
+
+// SaveCrawlers saves given crawler requests if the Storage implements CrawlerStorage.
+// Otherwise, the crawler requests are discarded.
+func SaveCrawlers(ctx context.Context, storage Storage, crawlers []model.Crawler) error {
+	if crawlerStorage, ok := storage.(CrawlerStorage); ok {
+		return crawlerStorage.SaveCrawlers(ctx, crawlers)
+	}
+
+	return nil
+}
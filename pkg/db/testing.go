@@ -54,6 +54,7 @@ func CleanupDB(t *testing.T, client *ClickHouse) {
 		"page_view_v7",
 		"event_v7",
 		"request_v7",
+		"crawler_v7",
 		"session",
 		"page_view",
 		"event",
@@ -0,0 +1,103 @@
+package ingest
+
+const (
+	defaultBotScoreWeight    = 1
+	defaultBotScoreThreshold = 1
+)
+
+// BotDetector is implemented by bot filters that can report all heuristics matching a request.
+// In contrast to a PipeStep, which is cancelled on the first match, this allows weighting multiple signals.
+type BotDetector interface {
+	// BotReasons returns the bot reasons for all heuristics matching the request.
+	BotReasons(*Request) []string
+}
+
+// BotScoreOptions is the configuration for the BotScore step.
+type BotScoreOptions struct {
+	// Detectors is the list of bot filters contributing to the score.
+	Detectors []BotDetector
+
+	// Weights is the weight per bot reason (like "ae-missing").
+	Weights map[string]float64
+
+	// DefaultWeight is the weight for bot reasons not found in Weights.
+	// If set to <= 0, the default value of 1 will be used.
+	DefaultWeight float64
+
+	// Threshold is the score at which a request is cancelled.
+	// If set to <= 0, the default value of 1 will be used.
+	Threshold float64
+}
+
+func (options *BotScoreOptions) validate() {
+	if options.Weights == nil {
+		options.Weights = make(map[string]float64)
+	}
+
+	if options.DefaultWeight <= 0 {
+		options.DefaultWeight = defaultBotScoreWeight
+	}
+
+	if options.Threshold <= 0 {
+		options.Threshold = defaultBotScoreThreshold
+	}
+}
+
+// BotScore is a score-based bot filter.
+// Every detector contributes the weights of all matching heuristics to the score,
+// and the request is cancelled once the score reaches the threshold.
+// The score and all triggered reasons are stored on the Request, so that the weights can be tuned from the request log.
+// Using the default options, a single matching heuristic cancels the request, like the individual filters do.
+type BotScore struct {
+	detectors     []BotDetector
+	weights       map[string]float64
+	defaultWeight float64
+	threshold     float64
+}
+
+// NewBotScore returns a new BotScore step for the given options.
+func NewBotScore(options BotScoreOptions) *BotScore {
+	options.validate()
+	return &BotScore{
+		detectors:     options.Detectors,
+		weights:       options.Weights,
+		defaultWeight: options.DefaultWeight,
+		threshold:     options.Threshold,
+	}
+}
+
+// Step implements ingest.PipeStep to process a step.
+// If cancelled, the BotReason is set to the triggered reason with the highest weight.
+func (s *BotScore) Step(request *Request) (bool, error) {
+	if request.DisableBotFilter {
+		return false, nil
+	}
+
+	botReason := ""
+	maxWeight := 0.0
+
+	for _, detector := range s.detectors {
+		for _, reason := range detector.BotReasons(request) {
+			weight, found := s.weights[reason]
+
+			if !found {
+				weight = s.defaultWeight
+			}
+
+			request.BotScore += weight
+			request.BotReasons = append(request.BotReasons, reason)
+
+			if weight > maxWeight {
+				botReason = reason
+				maxWeight = weight
+			}
+		}
+	}
+
+	if request.BotScore >= s.threshold {
+		request.BotReason = botReason
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotScore(t *testing.T) {
+	step := NewBotScore(BotScoreOptions{
+		Detectors: []BotDetector{
+			botDetector{"ae-missing", "te"},
+			botDetector{"http11-sf"},
+		},
+		Weights: map[string]float64{
+			"ae-missing": 0.2,
+			"http11-sf":  0.5,
+		},
+		DefaultWeight: 0.4,
+		Threshold:     1,
+	})
+	request := newBotScoreRequest()
+	cancel, err := step.Step(request)
+	assert.NoError(t, err)
+	assert.True(t, cancel)
+	assert.InDelta(t, 1.1, request.BotScore, 0.0001)
+	assert.Equal(t, []string{"ae-missing", "te", "http11-sf"}, request.BotReasons)
+	assert.Equal(t, "http11-sf", request.BotReason)
+	log := request.RequestLog()
+	assert.InDelta(t, 1.1, log.BotScore, 0.0001)
+	assert.Len(t, log.BotReasons, 3)
+
+	// below threshold
+	step = NewBotScore(BotScoreOptions{
+		Detectors: []BotDetector{
+			botDetector{"ae-missing"},
+			botDetector{},
+		},
+		Weights: map[string]float64{
+			"ae-missing": 0.2,
+		},
+	})
+	request = newBotScoreRequest()
+	cancel, err = step.Step(request)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.InDelta(t, 0.2, request.BotScore, 0.0001)
+	assert.Equal(t, []string{"ae-missing"}, request.BotReasons)
+	assert.Empty(t, request.BotReason)
+
+	// disabled bot filter
+	request = newBotScoreRequest()
+	request.DisableBotFilter = true
+	cancel, err = step.Step(request)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Zero(t, request.BotScore)
+	assert.Empty(t, request.BotReasons)
+}
+
+func TestBotScoreDefaults(t *testing.T) {
+	step := NewBotScore(BotScoreOptions{
+		Detectors: []BotDetector{
+			botDetector{"ua-keyword"},
+		},
+	})
+	request := newBotScoreRequest()
+	cancel, err := step.Step(request)
+	assert.NoError(t, err)
+	assert.True(t, cancel)
+	assert.Equal(t, float64(1), request.BotScore)
+	assert.Equal(t, "ua-keyword", request.BotReason)
+}
+
+func newBotScoreRequest() *Request {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	return &Request{
+		Request: req,
+	}
+}
+
+type botDetector []string
+
+func (d botDetector) BotReasons(*Request) []string {
+	return d
+}
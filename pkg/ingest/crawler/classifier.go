@@ -0,0 +1,63 @@
+package crawler
+
+import (
+	"strings"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/ua"
+)
+
+// Classifier identifies search engine and AI crawlers by their User-Agent without verifying them.
+// Bots found in the User-Agent blacklist (see ua.Blacklisted) that aren't a known Crawler are left to the bot filters,
+// unless ClassifyOther is enabled to label them as Other.
+// The request is labeled with the crawler name and category and is cancelled afterward,
+// so that it is stored for crawler analytics instead of being counted as a visitor.
+// The BotReason is set to "crawler-unverified".
+// Use the Verifier instead to verify crawlers by their IP.
+type Classifier struct {
+	crawlers      []Crawler
+	classifyOther bool
+}
+
+// NewClassifier creates a new Classifier for given crawlers.
+// If the list is empty, Crawlers will be used.
+func NewClassifier(crawlers []Crawler) *Classifier {
+	if len(crawlers) == 0 {
+		crawlers = Crawlers
+	}
+
+	return &Classifier{
+		crawlers: crawlers,
+	}
+}
+
+// ClassifyOther labels bots in the User-Agent blacklist that aren't a known Crawler as Other if set to true.
+// This stores all blacklisted requests for crawler analytics, which can be a lot.
+func (c *Classifier) ClassifyOther(classifyOther bool) *Classifier {
+	c.classifyOther = classifyOther
+	return c
+}
+
+// Step implements ingest.PipeStep to process a step.
+func (c *Classifier) Step(request *ingest.Request) (bool, error) {
+	if request.DisableBotFilter {
+		return false, nil
+	}
+
+	userAgent := strings.ToLower(request.Request.UserAgent())
+	crawler := find(c.crawlers, userAgent)
+
+	if crawler == nil {
+		if !c.classifyOther || !ua.Blacklisted(userAgent) {
+			return false, nil
+		}
+
+		crawler = &Other
+	}
+
+	request.Crawler = crawler.Name
+	request.CrawlerCategory = crawler.Category
+	request.IsBot = true
+	request.BotReason = "crawler-unverified"
+	return true, nil
+}
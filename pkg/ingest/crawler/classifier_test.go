@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"testing"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/ua"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifier(t *testing.T) {
+	classifier := NewClassifier(nil)
+
+	for userAgent, expected := range map[string][2]string{
+		"Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; ClaudeBot/1.0; +claudebot@anthropic.com)":                           {"ClaudeBot", CategoryAI},
+		"Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; PerplexityBot/1.0; +https://perplexity.ai/perplexitybot)":           {"PerplexityBot", CategoryAIAssistant},
+		"Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.2; +https://openai.com/gptbot)":                            {"GPTBot", CategoryAI},
+		"Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm) Chrome/116.0 Safari": {"Bingbot", CategorySearch},
+		"Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)":                                                                {"AhrefsBot", CategorySEO},
+		"CCBot/2.0 (https://commoncrawl.org/faq/)": {"CCBot", CategoryArchive},
+	} {
+		req := newRequest(userAgent, "81.2.69.142")
+		cancel, err := classifier.Step(req)
+		assert.NoError(t, err)
+		assert.True(t, cancel)
+		assert.Equal(t, expected[0], req.Crawler)
+		assert.Equal(t, expected[1], req.CrawlerCategory)
+		assert.True(t, req.IsBot)
+		assert.False(t, req.CrawlerVerified)
+		assert.Equal(t, "crawler-unverified", req.BotReason)
+		assert.Equal(t, "unverified", req.CrawlerLog().Status)
+	}
+
+	req := newRequest("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/146.0.0.0 Safari/537.36", "81.2.69.142")
+	cancel, err := classifier.Step(req)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Empty(t, req.Crawler)
+
+	// blacklisted bots that aren't a known crawler must be left to the bot filters
+	req = newRequest("Mozilla/5.0 (compatible; UnknownBot/1.0)", "81.2.69.142")
+	cancel, err = classifier.Step(req)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Empty(t, req.Crawler)
+	req = newRequest("ClaudeBot/1.0", "81.2.69.142")
+	req.DisableBotFilter = true
+	cancel, err = classifier.Step(req)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Empty(t, req.Crawler)
+}
+
+func TestClassifierCustomList(t *testing.T) {
+	classifier := NewClassifier([]Crawler{
+		{
+			Name:      "Custom",
+			Category:  CategorySEO,
+			UserAgent: []string{"custombot"},
+		},
+	})
+	req := newRequest("Mozilla/5.0 (compatible; CustomBot/1.0)", "81.2.69.142")
+	cancel, err := classifier.Step(req)
+	assert.NoError(t, err)
+	assert.True(t, cancel)
+	assert.Equal(t, "Custom", req.Crawler)
+
+	// known crawlers that are not in the list must be ignored
+	req = newRequest("ClaudeBot/1.0", "81.2.69.142")
+	cancel, err = classifier.Step(req)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Empty(t, req.Crawler)
+}
+
+func TestClassifierOther(t *testing.T) {
+	classifier := NewClassifier(nil).ClassifyOther(true)
+	req := newRequest("Mozilla/5.0 (compatible; UnknownBot/1.0)", "81.2.69.142")
+	cancel, err := classifier.Step(req)
+	assert.NoError(t, err)
+	assert.True(t, cancel)
+	assert.Equal(t, "Other", req.Crawler)
+	assert.Equal(t, CategoryOther, req.CrawlerCategory)
+	assert.Equal(t, "crawler-unverified", req.BotReason)
+}
+
+func TestCrawlersBlacklisted(t *testing.T) {
+	// all crawlers must be filtered by the User-Agent blacklist as well
+	for _, crawler := range Crawlers {
+		for _, keyword := range crawler.UserAgent {
+			assert.True(t, ua.Blacklisted(keyword), keyword)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package crawler
+
+import "strings"
+
+const (
+	// CategorySearch is the category for search engine crawlers.
+	CategorySearch = "search"
+
+	// CategoryAI is the category for AI crawlers collecting training data.
+	CategoryAI = "ai"
+
+	// CategoryAIAssistant is the category for AI assistants and AI search fetching pages on behalf of a user.
+	CategoryAIAssistant = "ai-assistant"
+
+	// CategorySEO is the category for SEO and marketing tool crawlers.
+	CategorySEO = "seo"
+
+	// CategoryArchive is the category for archive and dataset crawlers.
+	CategoryArchive = "archive"
+
+	// CategoryOther is the category for bots in the User-Agent blacklist that are not a known Crawler.
+	CategoryOther = "other"
+)
+
+// Crawler is a search engine or AI crawler that can be identified by its User-Agent and optionally be verified.
+type Crawler struct {
+	// Name is the name of the crawler (like "Googlebot").
+	Name string
+
+	// Category is the category of the crawler (like CategorySearch).
+	Category string
+
+	// UserAgent is the list of lowercase keywords used to identify the crawler by its User-Agent header.
+	UserAgent []string
+
+	// Domains is the list of domains the reverse DNS hostname of the crawler IP must end with (like "googlebot.com").
+	// If empty, the crawler won't be verified using reverse DNS.
+	Domains []string
+
+	// Ranges is the URL to the published IP range file of the crawler.
+	// The file must be in JSON format, like: {"prefixes": [{"ipv4Prefix": "66.249.64.0/27"}, {"ipv6Prefix": "2001:4860:4801:10::/64"}]}.
+	// If empty, the crawler won't be verified using IP ranges.
+	Ranges string
+}
+
+// Crawlers is the default list of known search engine and AI crawlers.
+var Crawlers = []Crawler{
+	{
+		Name:      "Googlebot",
+		Category:  CategorySearch,
+		UserAgent: []string{"googlebot", "google-inspectiontool", "googleother"},
+		Domains:   []string{"googlebot.com", "google.com"},
+		Ranges:    "https://developers.google.com/static/search/apis/ipranges/googlebot.json",
+	},
+	{
+		// special-case crawlers can only be verified by IP, as their hostnames are not unique to Google
+		Name:      "Google-Special",
+		Category:  CategorySearch,
+		UserAgent: []string{"adsbot-google", "mediapartners-google", "apis-google", "google-safety"},
+		Ranges:    "https://developers.google.com/static/search/apis/ipranges/special-crawlers.json",
+	},
+	{
+		Name:      "Google-Fetcher",
+		Category:  CategorySearch,
+		UserAgent: []string{"feedfetcher-google", "google-read-aloud", "google-site-verification", "googleproducer"},
+		Ranges:    "https://developers.google.com/static/search/apis/ipranges/user-triggered-fetchers-google.json",
+	},
+	{
+		Name:      "Bingbot",
+		Category:  CategorySearch,
+		UserAgent: []string{"bingbot", "bingpreview"},
+		Domains:   []string{"search.msn.com"},
+		Ranges:    "https://www.bing.com/toolbox/bingbot.json",
+	},
+	{
+		Name:      "Applebot",
+		Category:  CategorySearch,
+		UserAgent: []string{"applebot"},
+		Domains:   []string{"applebot.apple.com"},
+	},
+	{
+		Name:      "YandexBot",
+		Category:  CategorySearch,
+		UserAgent: []string{"yandexbot", "yandeximages"},
+		Domains:   []string{"yandex.ru", "yandex.net", "yandex.com"},
+	},
+	{
+		Name:      "Baiduspider",
+		Category:  CategorySearch,
+		UserAgent: []string{"baiduspider"},
+		Domains:   []string{"baidu.com", "baidu.jp"},
+	},
+	{
+		Name:      "DuckDuckBot",
+		Category:  CategorySearch,
+		UserAgent: []string{"duckduckbot"},
+	},
+	{
+		Name:      "GPTBot",
+		Category:  CategoryAI,
+		UserAgent: []string{"gptbot"},
+		Ranges:    "https://openai.com/gptbot.json",
+	},
+	{
+		Name:      "ChatGPT-User",
+		Category:  CategoryAIAssistant,
+		UserAgent: []string{"chatgpt-user"},
+		Ranges:    "https://openai.com/chatgpt-user.json",
+	},
+	{
+		Name:      "OAI-SearchBot",
+		Category:  CategoryAIAssistant,
+		UserAgent: []string{"oai-searchbot"},
+		Ranges:    "https://openai.com/searchbot.json",
+	},
+	{
+		Name:      "ClaudeBot",
+		Category:  CategoryAI,
+		UserAgent: []string{"claudebot", "anthropic-ai"},
+	},
+	{
+		Name:      "Claude-User",
+		Category:  CategoryAIAssistant,
+		UserAgent: []string{"claude-user", "claude-searchbot"},
+	},
+	{
+		Name:      "PerplexityBot",
+		Category:  CategoryAIAssistant,
+		UserAgent: []string{"perplexitybot", "perplexity-user"},
+		Ranges:    "https://www.perplexity.com/perplexitybot.json",
+	},
+	{
+		Name:      "Meta-ExternalAgent",
+		Category:  CategoryAI,
+		UserAgent: []string{"meta-externalagent", "meta-externalfetcher"},
+	},
+	{
+		Name:      "Bytespider",
+		Category:  CategoryAI,
+		UserAgent: []string{"bytespider"},
+	},
+	{
+		Name:      "Amazonbot",
+		Category:  CategoryAI,
+		UserAgent: []string{"amazonbot"},
+	},
+	{
+		Name:      "CCBot",
+		Category:  CategoryArchive,
+		UserAgent: []string{"ccbot"},
+	},
+	{
+		Name:      "ia_archiver",
+		Category:  CategoryArchive,
+		UserAgent: []string{"ia_archiver", "archive.org_bot"},
+	},
+	{
+		Name:      "AhrefsBot",
+		Category:  CategorySEO,
+		UserAgent: []string{"ahrefsbot", "ahrefssiteaudit"},
+	},
+	{
+		Name:      "SemrushBot",
+		Category:  CategorySEO,
+		UserAgent: []string{"semrushbot"},
+	},
+	{
+		Name:      "MJ12bot",
+		Category:  CategorySEO,
+		UserAgent: []string{"mj12bot"},
+	},
+	{
+		Name:      "DotBot",
+		Category:  CategorySEO,
+		UserAgent: []string{"dotbot"},
+	},
+}
+
+// Other is the Crawler used by the Classifier for bots in the User-Agent blacklist that are not a known Crawler (see Classifier.ClassifyOther).
+var Other = Crawler{
+	Name:     "Other",
+	Category: CategoryOther,
+}
+
+// find returns the first crawler matching given lowercase User-Agent or nil if none matches.
+func find(crawlers []Crawler, userAgent string) *Crawler {
+	if userAgent == "" {
+		return nil
+	}
+
+	for i := range crawlers {
+		for _, keyword := range crawlers[i].UserAgent {
+			if strings.Contains(userAgent, keyword) {
+				return &crawlers[i]
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,420 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+)
+
+const (
+	defaultTimeout    = time.Second * 2
+	defaultWorkers    = 8
+	defaultCacheSize  = 10_000
+	defaultCacheTTL   = time.Hour * 24
+	defaultFailureTTL = time.Minute * 5
+)
+
+// Resolver resolves IPs and hostnames.
+// It is implemented by net.Resolver and can be replaced for testing.
+type Resolver interface {
+	// LookupAddr performs a reverse lookup for the given address, returning a list of names mapping to that address.
+	LookupAddr(context.Context, string) ([]string, error)
+
+	// LookupIPAddr looks up host and returns a slice of its IPv4 and IPv6 addresses.
+	LookupIPAddr(context.Context, string) ([]net.IPAddr, error)
+}
+
+// VerifierOptions is the configuration for the Verifier.
+type VerifierOptions struct {
+	// Crawlers is the list of crawlers to verify.
+	// If not set, Crawlers will be used.
+	Crawlers []Crawler
+
+	// Resolver is the Resolver used for reverse DNS lookups.
+	// If not set, net.DefaultResolver will be used.
+	Resolver Resolver
+
+	// Client is the http.Client used to download the IP range files.
+	// If not set, http.DefaultClient will be used.
+	Client *http.Client
+
+	// Timeout is the maximum time for the DNS lookups per IP.
+	// If set to <= 0, the default value of two seconds will be used.
+	Timeout time.Duration
+
+	// Workers is the maximum number of concurrent DNS lookups.
+	// Lookups exceeding the limit are skipped and retried on the next request of the crawler.
+	// If set to <= 0, the default value of 8 will be used.
+	Workers int
+
+	// CacheSize is the maximum number of verification results kept in memory.
+	// If set to <= 0, the default value of 10,000 will be used.
+	CacheSize int
+
+	// CacheTTL is the time verification results are kept in memory.
+	// If set to <= 0, the default value of 24 hours will be used.
+	CacheTTL time.Duration
+
+	// FailureTTL is the time failed DNS lookups are kept in memory before they are retried.
+	// If set to <= 0, the default value of five minutes will be used.
+	FailureTTL time.Duration
+}
+
+func (options *VerifierOptions) validate() {
+	if options.Crawlers == nil {
+		options.Crawlers = Crawlers
+	}
+
+	if options.Resolver == nil {
+		options.Resolver = net.DefaultResolver
+	}
+
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = defaultTimeout
+	}
+
+	if options.Workers <= 0 {
+		options.Workers = defaultWorkers
+	}
+
+	if options.CacheSize <= 0 {
+		options.CacheSize = defaultCacheSize
+	}
+
+	if options.CacheTTL <= 0 {
+		options.CacheTTL = defaultCacheTTL
+	}
+
+	if options.FailureTTL <= 0 {
+		options.FailureTTL = defaultFailureTTL
+	}
+}
+
+type verification int
+
+const (
+	unverified verification = iota
+	verified
+	spoofed
+)
+
+type cacheKey struct {
+	crawler string
+	ip      string
+}
+
+type cacheEntry struct {
+	result  verification
+	expires time.Time
+}
+
+// Verifier verifies search engine and AI crawlers claiming to be a known Crawler by their User-Agent.
+// Crawlers are verified using the published IP ranges or forward-confirmed reverse DNS.
+// The request is labeled with the crawler name, category, and whether it has been verified and is cancelled afterward,
+// so that it is stored for analysis instead of being discarded.
+// The BotReason is set to "crawler" for verified crawlers, "crawler-spoofed" for spoofed User-Agents,
+// and "crawler-unverified" if the crawler could not be verified (because the DNS lookup failed for example).
+// DNS lookups run in the background, so requests are labeled unverified until the lookup for the IP has finished.
+// This step operates on the ingest.Request IP, which must be set before this step is run.
+type Verifier struct {
+	crawlers   []Crawler
+	resolver   Resolver
+	client     *http.Client
+	timeout    time.Duration
+	workers    chan struct{}
+	cacheSize  int
+	cacheTTL   time.Duration
+	failureTTL time.Duration
+	ranges     map[string][]netip.Prefix
+	cache      map[cacheKey]cacheEntry
+	pending    map[cacheKey]struct{}
+	m          sync.RWMutex
+}
+
+// NewVerifier creates a new Verifier for the given options.
+// The IP ranges must be loaded by calling UpdateRanges or SetRanges.
+func NewVerifier(options VerifierOptions) *Verifier {
+	options.validate()
+	return &Verifier{
+		crawlers:   options.Crawlers,
+		resolver:   options.Resolver,
+		client:     options.Client,
+		timeout:    options.Timeout,
+		workers:    make(chan struct{}, options.Workers),
+		cacheSize:  options.CacheSize,
+		cacheTTL:   options.CacheTTL,
+		failureTTL: options.FailureTTL,
+		ranges:     make(map[string][]netip.Prefix),
+		cache:      make(map[cacheKey]cacheEntry),
+		pending:    make(map[cacheKey]struct{}),
+	}
+}
+
+// Step implements ingest.PipeStep to process a step.
+func (v *Verifier) Step(request *ingest.Request) (bool, error) {
+	if request.DisableBotFilter {
+		return false, nil
+	}
+
+	crawler := find(v.crawlers, strings.ToLower(request.Request.UserAgent()))
+
+	if crawler == nil {
+		return false, nil
+	}
+
+	request.Crawler = crawler.Name
+	request.CrawlerCategory = crawler.Category
+	request.IsBot = true
+
+	switch v.verify(crawler, request.IP) {
+	case verified:
+		request.CrawlerVerified = true
+		request.BotReason = "crawler"
+	case spoofed:
+		request.BotReason = "crawler-spoofed"
+	default:
+		request.BotReason = "crawler-unverified"
+	}
+
+	return true, nil
+}
+
+// UpdateRanges downloads the published IP range files for all crawlers.
+// Crawlers that fail to update keep their previous ranges.
+func (v *Verifier) UpdateRanges(ctx context.Context) error {
+	var errs []error
+
+	for _, crawler := range v.crawlers {
+		if crawler.Ranges == "" {
+			continue
+		}
+
+		prefixes, err := v.downloadRanges(ctx, crawler.Ranges)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error updating IP ranges for %s: %w", crawler.Name, err))
+			continue
+		}
+
+		v.SetRanges(crawler.Name, prefixes)
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetRanges sets the IP ranges for the crawler with given name and clears the cache.
+func (v *Verifier) SetRanges(name string, prefixes []netip.Prefix) {
+	v.m.Lock()
+	defer v.m.Unlock()
+	v.ranges[name] = prefixes
+	clear(v.cache)
+}
+
+func (v *Verifier) verify(crawler *Crawler, ip string) verification {
+	addr, err := netip.ParseAddr(ip)
+
+	if err != nil {
+		return spoofed
+	}
+
+	addr = addr.Unmap()
+	key := cacheKey{crawler.Name, addr.String()}
+	now := time.Now()
+	v.m.RLock()
+	entry, found := v.cache[key]
+	prefixes := v.ranges[crawler.Name]
+	v.m.RUnlock()
+
+	if found && entry.expires.After(now) {
+		return entry.result
+	}
+
+	result := v.verifyRanges(prefixes, addr)
+
+	if result != verified && len(crawler.Domains) > 0 {
+		v.lookup(crawler, addr, key)
+		return unverified
+	}
+
+	v.m.Lock()
+	defer v.m.Unlock()
+	v.store(key, result, now.Add(v.cacheTTL))
+	return result
+}
+
+// lookup verifies the crawler using reverse DNS in the background, unless a lookup for the IP is running already
+// or the maximum number of concurrent lookups has been reached.
+func (v *Verifier) lookup(crawler *Crawler, addr netip.Addr, key cacheKey) {
+	v.m.Lock()
+	defer v.m.Unlock()
+
+	if _, found := v.pending[key]; found {
+		return
+	}
+
+	select {
+	case v.workers <- struct{}{}:
+	default:
+		return
+	}
+
+	v.pending[key] = struct{}{}
+
+	go func() {
+		defer func() {
+			<-v.workers
+		}()
+		result := v.verifyDNS(crawler, addr)
+		ttl := v.cacheTTL
+
+		// a failed DNS lookup might be temporary, so it is retried sooner
+		if result == unverified {
+			ttl = v.failureTTL
+		}
+
+		v.m.Lock()
+		defer v.m.Unlock()
+		delete(v.pending, key)
+		v.store(key, result, time.Now().Add(ttl))
+	}()
+}
+
+func (v *Verifier) store(key cacheKey, result verification, expires time.Time) {
+	if len(v.cache) >= v.cacheSize {
+		v.removeExpired(time.Now())
+	}
+
+	v.cache[key] = cacheEntry{
+		result:  result,
+		expires: expires,
+	}
+}
+
+func (v *Verifier) verifyRanges(prefixes []netip.Prefix, addr netip.Addr) verification {
+	if len(prefixes) == 0 {
+		return unverified
+	}
+
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return verified
+		}
+	}
+
+	return spoofed
+}
+
+func (v *Verifier) verifyDNS(crawler *Crawler, addr netip.Addr) verification {
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+	hostnames, err := v.resolver.LookupAddr(ctx, addr.String())
+
+	if err != nil {
+		var dnsErr *net.DNSError
+
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return spoofed
+		}
+
+		return unverified
+	}
+
+	for _, hostname := range hostnames {
+		hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+
+		if !slices.ContainsFunc(crawler.Domains, func(domain string) bool {
+			return strings.HasSuffix(hostname, "."+domain)
+		}) {
+			continue
+		}
+
+		// the hostname must resolve to the original IP (forward-confirmed)
+		ips, err := v.resolver.LookupIPAddr(ctx, hostname)
+
+		if err != nil {
+			return unverified
+		}
+
+		for _, ip := range ips {
+			if resolved, ok := netip.AddrFromSlice(ip.IP); ok && resolved.Unmap() == addr {
+				return verified
+			}
+		}
+	}
+
+	return spoofed
+}
+
+func (v *Verifier) removeExpired(now time.Time) {
+	for key, entry := range v.cache {
+		if !entry.expires.After(now) {
+			delete(v.cache, key)
+		}
+	}
+
+	// drop all results if the cache is still full
+	if len(v.cache) >= v.cacheSize {
+		clear(v.cache)
+	}
+}
+
+func (v *Verifier) downloadRanges(ctx context.Context, url string) ([]netip.Prefix, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var ranges struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+		} `json:"prefixes"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&ranges); err != nil {
+		return nil, err
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(ranges.Prefixes))
+
+	for _, p := range ranges.Prefixes {
+		prefix, err := netip.ParsePrefix(p.IPv4Prefix + p.IPv6Prefix)
+
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
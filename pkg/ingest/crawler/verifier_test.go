@@ -0,0 +1,269 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifierDNS(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		resolver := &resolver{
+			addr: map[string][]string{
+				"66.249.66.1": {"crawl-66-249-66-1.googlebot.com."},
+				"66.249.66.2": {"crawl-66-249-66-2.googlebot.com."},
+				"66.249.66.4": {"66-249-66-4.bc.googleusercontent.com."},
+				"81.2.69.142": {"example.com."},
+			},
+			ips: map[string][]string{
+				"crawl-66-249-66-1.googlebot.com":      {"66.249.66.1"},
+				"crawl-66-249-66-2.googlebot.com":      {"66.249.66.9"},
+				"66-249-66-4.bc.googleusercontent.com": {"66.249.66.4"},
+			},
+		}
+		verifier := NewVerifier(VerifierOptions{
+			Resolver: resolver,
+		})
+		userAgent := "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+
+		// the request is unverified until the lookup has finished
+		req := newRequest(userAgent, "66.249.66.1")
+		cancel, err := verifier.Step(req)
+		assert.NoError(t, err)
+		assert.True(t, cancel)
+		assert.Equal(t, "crawler-unverified", req.BotReason)
+		synctest.Wait()
+
+		// verified
+		req = newRequest(userAgent, "66.249.66.1")
+		cancel, err = verifier.Step(req)
+		assert.NoError(t, err)
+		assert.True(t, cancel)
+		assert.Equal(t, "Googlebot", req.Crawler)
+		assert.Equal(t, CategorySearch, req.CrawlerCategory)
+		assert.True(t, req.CrawlerVerified)
+		assert.True(t, req.IsBot)
+		assert.Equal(t, "crawler", req.BotReason)
+		assert.Equal(t, "verified", req.CrawlerLog().Status)
+
+		// the result must be cached
+		lookups := resolver.lookups.Load()
+		_, err = verifier.Step(newRequest(userAgent, "66.249.66.1"))
+		assert.NoError(t, err)
+		assert.Equal(t, lookups, resolver.lookups.Load())
+
+		// forward lookup does not match, wrong domain, spoofable domain, and no hostname
+		for _, ip := range []string{"66.249.66.2", "81.2.69.142", "66.249.66.4", "81.2.69.143"} {
+			_, err = verifier.Step(newRequest(userAgent, ip))
+			assert.NoError(t, err)
+			synctest.Wait()
+			req = newRequest(userAgent, ip)
+			cancel, err = verifier.Step(req)
+			assert.NoError(t, err)
+			assert.True(t, cancel)
+			assert.Equal(t, "Googlebot", req.Crawler)
+			assert.False(t, req.CrawlerVerified)
+			assert.Equal(t, "crawler-spoofed", req.BotReason, ip)
+			assert.Equal(t, "spoofed", req.CrawlerLog().Status)
+		}
+
+		// lookup failed
+		resolver.err = errors.New("timeout")
+		_, err = verifier.Step(newRequest(userAgent, "66.249.66.3"))
+		assert.NoError(t, err)
+		synctest.Wait()
+		lookups = resolver.lookups.Load()
+		req = newRequest(userAgent, "66.249.66.3")
+		cancel, err = verifier.Step(req)
+		assert.NoError(t, err)
+		assert.True(t, cancel)
+		assert.Equal(t, "crawler-unverified", req.BotReason)
+		synctest.Wait()
+
+		// the failed lookup must be cached for the failure TTL
+		assert.Equal(t, lookups, resolver.lookups.Load())
+		time.Sleep(defaultFailureTTL)
+		_, err = verifier.Step(newRequest(userAgent, "66.249.66.3"))
+		assert.NoError(t, err)
+		synctest.Wait()
+		assert.Equal(t, lookups+1, resolver.lookups.Load())
+
+		// no crawler and disabled bot filter
+		req = newRequest("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/146.0.0.0 Safari/537.36", "66.249.66.1")
+		cancel, err = verifier.Step(req)
+		assert.NoError(t, err)
+		assert.False(t, cancel)
+		assert.Empty(t, req.Crawler)
+		req = newRequest(userAgent, "66.249.66.1")
+		req.DisableBotFilter = true
+		cancel, err = verifier.Step(req)
+		assert.NoError(t, err)
+		assert.False(t, cancel)
+		assert.Empty(t, req.Crawler)
+	})
+}
+
+func TestVerifierWorkers(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		resolver := &resolver{block: make(chan struct{})}
+		verifier := NewVerifier(VerifierOptions{
+			Resolver: resolver,
+			Workers:  1,
+		})
+
+		// the second lookup must be skipped while the first one is running, and the first one must not run twice
+		for _, ip := range []string{"81.2.69.142", "81.2.69.142", "81.2.69.143"} {
+			req := newRequest("Googlebot", ip)
+			_, err := verifier.Step(req)
+			assert.NoError(t, err)
+			assert.Equal(t, "crawler-unverified", req.BotReason)
+		}
+
+		synctest.Wait()
+		assert.Equal(t, int64(1), resolver.lookups.Load())
+		close(resolver.block)
+		synctest.Wait()
+		assert.Len(t, verifier.cache, 1)
+		assert.Empty(t, verifier.pending)
+	})
+}
+
+func TestVerifierRanges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gptbot.json" {
+			_, _ = w.Write([]byte(`{"creationTime": "2025-10-10T00:00:00", "prefixes": [{"ipv4Prefix": "20.15.240.64/28"}, {"ipv6Prefix": "2001:db8::/32"}]}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	verifier := NewVerifier(VerifierOptions{
+		Crawlers: []Crawler{
+			{
+				Name:      "GPTBot",
+				UserAgent: []string{"gptbot"},
+				Ranges:    server.URL + "/gptbot.json",
+			},
+			{
+				Name:      "Other",
+				UserAgent: []string{"otherbot"},
+				Ranges:    server.URL + "/other.json",
+			},
+		},
+		Resolver: new(resolver),
+	})
+	userAgent := "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.2; +https://openai.com/gptbot)"
+
+	// the ranges have not been loaded yet
+	req := newRequest(userAgent, "20.15.240.65")
+	_, err := verifier.Step(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "crawler-unverified", req.BotReason)
+
+	err = verifier.UpdateRanges(context.Background())
+	assert.ErrorContains(t, err, "Other")
+	assert.NotContains(t, err.Error(), "GPTBot")
+
+	for ip, reason := range map[string]string{
+		"20.15.240.65":        "crawler",
+		"::ffff:20.15.240.66": "crawler",
+		"2001:db8::1":         "crawler",
+		"20.15.240.1":         "crawler-spoofed",
+		"invalid":             "crawler-spoofed",
+	} {
+		req = newRequest(userAgent, ip)
+		cancel, err := verifier.Step(req)
+		assert.NoError(t, err)
+		assert.True(t, cancel)
+		assert.Equal(t, "GPTBot", req.Crawler)
+		assert.Equal(t, reason, req.BotReason, ip)
+		assert.Equal(t, reason == "crawler", req.CrawlerVerified)
+	}
+
+	verifier.SetRanges("GPTBot", []netip.Prefix{netip.MustParsePrefix("20.15.240.0/24")})
+	req = newRequest(userAgent, "20.15.240.1")
+	_, err = verifier.Step(req)
+	assert.NoError(t, err)
+	assert.True(t, req.CrawlerVerified)
+}
+
+func TestVerifierCacheSize(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		verifier := NewVerifier(VerifierOptions{
+			Resolver:  new(resolver),
+			CacheSize: 2,
+		})
+
+		for _, ip := range []string{"81.2.69.142", "81.2.69.143", "81.2.69.144"} {
+			_, err := verifier.Step(newRequest("Googlebot", ip))
+			assert.NoError(t, err)
+			synctest.Wait()
+		}
+
+		assert.Len(t, verifier.cache, 1)
+	})
+}
+
+func newRequest(userAgent, ip string) *ingest.Request {
+	r, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	r.Header.Set("User-Agent", userAgent)
+	return &ingest.Request{
+		Request: r,
+		IP:      ip,
+	}
+}
+
+type resolver struct {
+	addr    map[string][]string
+	ips     map[string][]string
+	err     error
+	block   chan struct{}
+	lookups atomic.Int64
+}
+
+func (r *resolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	r.lookups.Add(1)
+
+	if r.block != nil {
+		<-r.block
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	hostnames, found := r.addr[addr]
+
+	if !found {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+
+	return hostnames, nil
+}
+
+func (r *resolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups.Add(1)
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	ips := make([]net.IPAddr, 0)
+
+	for _, ip := range r.ips[host] {
+		ips = append(ips, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,169 @@
+package geo
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+)
+
+const (
+	asnPermalink     = "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-ASN&license_key=LICENCE_KEY&suffix=tar.gz"
+	asnTarGzFilename = "GeoLite2-ASN.tar.gz"
+	asnFilename      = "GeoLite2-ASN.mmdb"
+)
+
+// HostingASN is a list of autonomous system numbers of popular hosting and cloud providers.
+// It can be used as a deny list for the ASN step.
+var HostingASN = []uint32{
+	7224,   // Amazon
+	8075,   // Microsoft
+	12876,  // Scaleway
+	14061,  // DigitalOcean
+	14618,  // Amazon
+	15169,  // Google
+	16276,  // OVH
+	16509,  // Amazon
+	20473,  // Vultr
+	24940,  // Hetzner
+	31898,  // Oracle
+	45102,  // Alibaba
+	51167,  // Contabo
+	63949,  // Akamai (Linode)
+	132203, // Tencent
+	396982, // Google Cloud
+}
+
+// ASN maps IPs to their autonomous system number and organization based on MaxMinds GeoLite2 ASN (or compatible) database.
+// Requests from autonomous systems on the deny list are cancelled, like hosting and cloud providers (see HostingASN).
+// To add the deny list to an ingest.BotScore instead of cancelling requests, use DetectOnly.
+type ASN struct {
+	db         *database
+	deny       map[uint32]struct{}
+	detectOnly bool
+	m          sync.RWMutex
+}
+
+// NewASN creates a new ASN for the given licence key and deny list.
+// The download URL is optional and will be set to the default if empty.
+// "LICENCE_KEY" will be replaced with the configured licence key.
+// The deny list is optional. If empty, no requests will be cancelled.
+func NewASN(licenseKey, downloadPath, downloadURL string, deny []uint32) (*ASN, error) {
+	if downloadURL == "" {
+		downloadURL = asnPermalink
+	}
+
+	asn := &ASN{
+		db: newDatabase(licenseKey, downloadPath, downloadURL, asnTarGzFilename, asnFilename),
+	}
+	asn.SetDenyList(deny)
+
+	if licenseKey != "" && downloadPath != "" {
+		if err := asn.Update(); err != nil {
+			return nil, err
+		}
+	}
+
+	return asn, nil
+}
+
+// DetectOnly disables cancelling requests from autonomous systems on the deny list in the Step.
+// The ASN and organization are still set, and the deny list is only reported as a bot reason to an ingest.BotScore.
+func (asn *ASN) DetectOnly() *ASN {
+	asn.detectOnly = true
+	return asn
+}
+
+// Step implements ingest.PipeStep to process a step.
+// It looks up the autonomous system number and organization for given IP.
+// If the IP is invalid, it won't do anything.
+func (asn *ASN) Step(request *ingest.Request) (bool, error) {
+	asn.lookup(request)
+
+	if !asn.detectOnly && !request.DisableBotFilter && asn.denied(request.ASN) {
+		request.BotReason = "asn"
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// BotReasons implements the ingest.BotDetector interface.
+// If the ingest.Request ASN has not been set by the Step before, it's looked up first.
+func (asn *ASN) BotReasons(request *ingest.Request) []string {
+	if request.ASN == 0 {
+		asn.lookup(request)
+	}
+
+	if asn.denied(request.ASN) {
+		return []string{"asn"}
+	}
+
+	return nil
+}
+
+// SetDenyList updates the list of autonomous system numbers to cancel requests for.
+func (asn *ASN) SetDenyList(deny []uint32) {
+	denyList := make(map[uint32]struct{}, len(deny))
+
+	for _, number := range deny {
+		denyList[number] = struct{}{}
+	}
+
+	asn.m.Lock()
+	defer asn.m.Unlock()
+	asn.deny = denyList
+}
+
+func (asn *ASN) lookup(request *ingest.Request) {
+	parsedIP := net.ParseIP(request.IP)
+
+	if parsedIP == nil {
+		return
+	}
+
+	record := struct {
+		Number       uint32 `maxminddb:"autonomous_system_number"`
+		Organization string `maxminddb:"autonomous_system_organization"`
+	}{}
+
+	if err := asn.db.lookup(parsedIP, &record); err != nil {
+		return
+	}
+
+	request.ASN = record.Number
+	request.ASNOrganization = record.Organization
+}
+
+func (asn *ASN) denied(number uint32) bool {
+	asn.m.RLock()
+	defer asn.m.RUnlock()
+	_, found := asn.deny[number]
+	return number != 0 && found
+}
+
+// Update downloads and unpacks the MaxMind GeoLite2 ASN database.
+func (asn *ASN) Update() error {
+	return asn.db.update()
+}
+
+// UpdateFromFile updates ASN from a given file instead of downloading the database.
+func (asn *ASN) UpdateFromFile(path string) error {
+	return asn.db.updateFromFile(path)
+}
+
+// Watch loads the database from given file and reloads it whenever the file changes, until the context is cancelled.
+// The file is checked for changes in given interval (one minute if <= 0).
+// The database is validated before it replaces the previous version. Errors during reload are logged.
+func (asn *ASN) Watch(ctx context.Context, path string, interval time.Duration, logger *slog.Logger) error {
+	return asn.db.watch(ctx, path, interval, logger)
+}
+
+// Metadata returns the Metadata of the loaded database.
+// It returns false if no database has been loaded yet.
+func (asn *ASN) Metadata() (Metadata, bool) {
+	return asn.db.metadata()
+}
@@ -0,0 +1,106 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASN(t *testing.T) {
+	asn, err := NewASN("", "", "", nil)
+	assert.NoError(t, err)
+
+	// the database has not been loaded yet
+	req := &ingest.Request{IP: "81.2.69.142"}
+	cancel, err := asn.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.Zero(t, req.ASN)
+
+	assert.NoError(t, asn.UpdateFromFile("../../../test/GeoLite2-ASN-Test.mmdb"))
+	cancel, err = asn.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(20712), req.ASN)
+	assert.Equal(t, "Andrews & Arnold Ltd", req.ASNOrganization)
+	assert.Empty(t, req.BotReason)
+
+	// unknown and invalid IPs
+	for _, ip := range []string{"8.8.8.8", "invalid"} {
+		req = &ingest.Request{IP: ip}
+		cancel, err = asn.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		assert.Zero(t, req.ASN)
+		assert.Empty(t, req.ASNOrganization)
+	}
+}
+
+func TestASNDenyList(t *testing.T) {
+	asn, err := NewASN("", "", "", HostingASN)
+	assert.NoError(t, err)
+	assert.NoError(t, asn.UpdateFromFile("../../../test/GeoLite2-ASN-Test.mmdb"))
+	req := &ingest.Request{IP: "3.1.2.3"}
+	cancel, err := asn.Step(req)
+	assert.True(t, cancel)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(16509), req.ASN)
+	assert.Equal(t, "AMAZON-02", req.ASNOrganization)
+	assert.Equal(t, "asn", req.BotReason)
+	assert.Equal(t, []string{"asn"}, asn.BotReasons(req))
+	req = &ingest.Request{IP: "1.130.1.1"}
+	cancel, err = asn.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1221), req.ASN)
+	assert.Empty(t, asn.BotReasons(req))
+
+	// the bot filter is disabled
+	req = &ingest.Request{IP: "34.100.0.1", DisableBotFilter: true}
+	cancel, err = asn.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(396982), req.ASN)
+
+	// update the deny list
+	asn.SetDenyList([]uint32{1221})
+	req = &ingest.Request{IP: "3.1.2.3"}
+	cancel, err = asn.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	req = &ingest.Request{IP: "1.130.1.1"}
+	cancel, err = asn.Step(req)
+	assert.True(t, cancel)
+	assert.NoError(t, err)
+}
+
+func TestASNDetectOnly(t *testing.T) {
+	asn, err := NewASN("", "", "", HostingASN)
+	assert.NoError(t, err)
+	assert.NoError(t, asn.UpdateFromFile("../../../test/GeoLite2-ASN-Test.mmdb"))
+	asn.DetectOnly()
+
+	// the step must not cancel the request
+	req := &ingest.Request{IP: "3.1.2.3"}
+	cancel, err := asn.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(16509), req.ASN)
+	assert.Empty(t, req.BotReason)
+
+	// the detector must look up the ASN on its own
+	req = &ingest.Request{IP: "3.1.2.3"}
+	assert.Equal(t, []string{"asn"}, asn.BotReasons(req))
+	assert.Equal(t, uint32(16509), req.ASN)
+	score := ingest.NewBotScore(ingest.BotScoreOptions{
+		Detectors: []ingest.BotDetector{asn},
+		Weights:   map[string]float64{"asn": 0.5},
+	})
+	req = &ingest.Request{IP: "3.1.2.3"}
+	cancel, err = score.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, req.BotScore)
+	assert.Equal(t, []string{"asn"}, req.BotReasons)
+}
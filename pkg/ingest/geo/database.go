@@ -0,0 +1,251 @@
+package geo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+const (
+	licenseKeyPlaceholder = "LICENCE_KEY"
+	defaultWatchInterval  = time.Minute
+)
+
+// Metadata is the metadata of a loaded database.
+type Metadata struct {
+	// DatabaseType is the type of the database (like "GeoLite2-City").
+	DatabaseType string
+
+	// BuildTime is the time the database has been built.
+	BuildTime time.Time
+
+	// LoadedAt is the time the database has been loaded.
+	LoadedAt time.Time
+}
+
+// database downloads, unpacks, and reads a MaxMind (or compatible) mmdb database.
+type database struct {
+	licenseKey    string
+	downloadPath  string
+	downloadURL   string
+	tarGzFilename string
+	filename      string
+	db            *maxminddb.Reader
+	loadedAt      time.Time
+	m             sync.RWMutex
+}
+
+func newDatabase(licenseKey, downloadPath, downloadURL, tarGzFilename, filename string) *database {
+	return &database{
+		licenseKey:    licenseKey,
+		downloadPath:  downloadPath,
+		downloadURL:   downloadURL,
+		tarGzFilename: tarGzFilename,
+		filename:      filename,
+	}
+}
+
+func (d *database) lookup(ip net.IP, record any) error {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	if d.db == nil {
+		return nil
+	}
+
+	return d.db.Lookup(ip, record)
+}
+
+func (d *database) metadata() (Metadata, bool) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	if d.db == nil {
+		return Metadata{}, false
+	}
+
+	return Metadata{
+		DatabaseType: d.db.Metadata.DatabaseType,
+		BuildTime:    time.Unix(int64(d.db.Metadata.BuildEpoch), 0).UTC(),
+		LoadedAt:     d.loadedAt,
+	}, true
+}
+
+func (d *database) update() error {
+	if err := d.download(); err != nil {
+		return err
+	}
+
+	if err := d.unpackAndUpdate(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(d.downloadPath, d.tarGzFilename)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *database) updateFromFile(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	return d.load(data)
+}
+
+func (d *database) watch(ctx context.Context, path string, interval time.Duration, logger *slog.Logger) error {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return err
+	}
+
+	if err := d.updateFromFile(path); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		modTime, size := info.ModTime(), info.Size()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+
+				if err != nil {
+					logger.Error("Error reading database file", "err", err, "path", path)
+					continue
+				}
+
+				if info.ModTime().Equal(modTime) && info.Size() == size {
+					continue
+				}
+
+				// the previous database is kept if the file is invalid, it will be retried on the next change
+				if err := d.updateFromFile(path); err != nil {
+					logger.Error("Error reloading database, keeping the previous version", "err", err, "path", path)
+					continue
+				}
+
+				modTime, size = info.ModTime(), info.Size()
+				logger.Info("Database reloaded", "path", path)
+			}
+		}
+	}()
+	return nil
+}
+
+// load validates the database and swaps it with the current one.
+func (d *database) load(data []byte) error {
+	db, err := maxminddb.FromBytes(data)
+
+	if err != nil {
+		return err
+	}
+
+	if err := db.Verify(); err != nil {
+		return fmt.Errorf("invalid database: %w", err)
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.db = db
+	d.loadedAt = time.Now().UTC()
+	return nil
+}
+
+func (d *database) download() error {
+	if err := os.MkdirAll(d.downloadPath, 0755); err != nil {
+		return err
+	}
+
+	resp, err := http.Get(strings.Replace(d.downloadURL, licenseKeyPlaceholder, d.licenseKey, 1))
+
+	if err != nil {
+		return err
+	}
+
+	tarGz, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(d.downloadPath, d.tarGzFilename), tarGz, 0755); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (d *database) unpackAndUpdate() error {
+	file, err := os.Open(filepath.Join(d.downloadPath, d.tarGzFilename))
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+	gzipFile, err := gzip.NewReader(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = gzipFile.Close()
+	}()
+	r := tar.NewReader(gzipFile)
+	var out bytes.Buffer
+
+	for {
+		header, err := r.Next()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if filepath.Base(header.Name) == d.filename {
+			if _, err := io.Copy(&out, r); err != nil {
+				return err
+			}
+
+			break
+		}
+	}
+
+	return d.load(out.Bytes())
+}
@@ -1,36 +1,26 @@
 package geo
 
 import (
-	"archive/tar"
-	"bytes"
-	"compress/gzip"
+	"context"
 	"fmt"
-	"io"
+	"log/slog"
 	"net"
-	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
-	"sync"
+	"time"
 
-	"github.com/oschwald/maxminddb-golang"
 	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
 )
 
 const (
 	geoLite2Permalink     = "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-City&license_key=LICENCE_KEY&suffix=tar.gz"
-	geoLite2LicenseKey    = "LICENCE_KEY"
 	geoLite2TarGzFilename = "GeoLite2-City.tar.gz"
 	geoLite2Filename      = "GeoLite2-City.mmdb"
 )
 
 // Geo maps IPs to their geological location based on MaxMinds GeoLite2 or GeoIP2 database.
+// Compatible databases in the same format (like the DB-IP Lite City mmdb) can be loaded using UpdateFromFile.
 type Geo struct {
-	licenseKey   string
-	downloadPath string
-	downloadURL  string
-	db           *maxminddb.Reader
-	m            sync.RWMutex
+	db *database
 }
 
 // NewGeo creates a new Geo for the given licence key.
@@ -42,9 +32,7 @@ func NewGeo(licenseKey, downloadPath, downloadURL string) (*Geo, error) {
 	}
 
 	geoDB := &Geo{
-		licenseKey:   licenseKey,
-		downloadPath: downloadPath,
-		downloadURL:  downloadURL,
+		db: newDatabase(licenseKey, downloadPath, downloadURL, geoLite2TarGzFilename, geoLite2Filename),
 	}
 
 	if licenseKey != "" && downloadPath != "" {
@@ -57,18 +45,22 @@ func NewGeo(licenseKey, downloadPath, downloadURL string) (*Geo, error) {
 }
 
 // Step implements ingest.PipeStep to process a step.
-// It looks up the country code, subdivision (region), and city for given IP.
+// It looks up the country code, subdivision (region), city, continent, time zone, coordinates, and EU flag for given IP.
 // If the IP is invalid, it won't do anything.
 func (geo *Geo) Step(request *ingest.Request) (bool, error) {
-	parsedIP := net.ParseIP(request.IP)
-
-	if parsedIP == nil {
-		return false, nil
-	}
+	setLocation(geo, request)
+	return false, nil
+}
 
+// Lookup implements the Provider interface.
+func (geo *Geo) Lookup(ip net.IP) (Location, error) {
 	record := struct {
+		Continent struct {
+			Code string `maxminddb:"code"`
+		} `maxminddb:"continent"`
 		Country struct {
-			ISOCode string `maxminddb:"iso_code"`
+			ISOCode           string `maxminddb:"iso_code"`
+			IsInEuropeanUnion bool   `maxminddb:"is_in_european_union"`
 		} `maxminddb:"country"`
 		Subdivisions []struct {
 			ISOCode string `maxminddb:"iso_code"`
@@ -81,13 +73,15 @@ func (geo *Geo) Step(request *ingest.Request) (bool, error) {
 				En string `maxminddb:"en"`
 			} `maxminddb:"names"`
 		} `maxminddb:"city"`
+		Location struct {
+			Latitude  float64 `maxminddb:"latitude"`
+			Longitude float64 `maxminddb:"longitude"`
+			TimeZone  string  `maxminddb:"time_zone"`
+		} `maxminddb:"location"`
 	}{}
 
-	geo.m.RLock()
-	defer geo.m.RUnlock()
-
-	if err := geo.db.Lookup(parsedIP, &record); err != nil {
-		return false, nil
+	if err := geo.db.lookup(ip, &record); err != nil {
+		return Location{}, err
 	}
 
 	if record.Country.ISOCode == "US" && len(record.Subdivisions) > 0 && record.Subdivisions[0].ISOCode != "" {
@@ -100,121 +94,37 @@ func (geo *Geo) Step(request *ingest.Request) (bool, error) {
 		subdivision = record.Subdivisions[0].Names.En
 	}
 
-	request.CountryCode = strings.ToLower(record.Country.ISOCode)
-	request.Region = subdivision
-	request.City = record.City.Names.En
-	return false, nil
+	return Location{
+		CountryCode:   strings.ToLower(record.Country.ISOCode),
+		Region:        subdivision,
+		City:          record.City.Names.En,
+		ContinentCode: record.Continent.Code,
+		TimeZone:      record.Location.TimeZone,
+		Latitude:      record.Location.Latitude,
+		Longitude:     record.Location.Longitude,
+		InEU:          record.Country.IsInEuropeanUnion,
+	}, nil
 }
 
 // Update downloads and unpacks the MaxMind GeoLite2 database.
 func (geo *Geo) Update() error {
-	if err := geo.download(); err != nil {
-		return err
-	}
-
-	if err := geo.unpackAndUpdate(); err != nil {
-		return err
-	}
-
-	if err := os.Remove(filepath.Join(geo.downloadPath, geoLite2TarGzFilename)); err != nil {
-		return err
-	}
-
-	return nil
+	return geo.db.update()
 }
 
 // UpdateFromFile updates Geo from a given file instead of downloading the database.
 func (geo *Geo) UpdateFromFile(path string) error {
-	data, err := os.ReadFile(path)
-
-	if err != nil {
-		return err
-	}
-
-	geo.m.Lock()
-	defer geo.m.Unlock()
-	geoDB, err := maxminddb.FromBytes(data)
-
-	if err != nil {
-		return err
-	}
-
-	geo.db = geoDB
-	return nil
+	return geo.db.updateFromFile(path)
 }
 
-func (geo *Geo) download() error {
-	if err := os.MkdirAll(geo.downloadPath, 0755); err != nil {
-		return err
-	}
-
-	resp, err := http.Get(strings.Replace(geo.downloadURL, geoLite2LicenseKey, geo.licenseKey, 1))
-
-	if err != nil {
-		return err
-	}
-
-	tarGz, err := io.ReadAll(resp.Body)
-
-	if err != nil {
-		return err
-	}
-
-	if err := os.WriteFile(filepath.Join(geo.downloadPath, geoLite2TarGzFilename), tarGz, 0755); err != nil {
-		return err
-	}
-
-	return nil
+// Watch loads the database from given file and reloads it whenever the file changes, until the context is cancelled.
+// The file is checked for changes in given interval (one minute if <= 0).
+// The database is validated before it replaces the previous version. Errors during reload are logged.
+func (geo *Geo) Watch(ctx context.Context, path string, interval time.Duration, logger *slog.Logger) error {
+	return geo.db.watch(ctx, path, interval, logger)
 }
 
-func (geo *Geo) unpackAndUpdate() error {
-	file, err := os.Open(filepath.Join(geo.downloadPath, geoLite2TarGzFilename))
-
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		_ = file.Close()
-	}()
-	gzipFile, err := gzip.NewReader(file)
-
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		_ = gzipFile.Close()
-	}()
-	r := tar.NewReader(gzipFile)
-	var out bytes.Buffer
-
-	for {
-		header, err := r.Next()
-
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
-		}
-
-		if filepath.Base(header.Name) == geoLite2Filename {
-			if _, err := io.Copy(&out, r); err != nil {
-				return err
-			}
-
-			break
-		}
-	}
-
-	geo.m.Lock()
-	defer geo.m.Unlock()
-	geoDB, err := maxminddb.FromBytes(out.Bytes())
-
-	if err != nil {
-		return err
-	}
-
-	geo.db = geoDB
-	return nil
+// Metadata returns the Metadata of the loaded database.
+// It returns false if no database has been loaded yet.
+func (geo *Geo) Metadata() (Metadata, bool) {
+	return geo.db.metadata()
 }
@@ -1,9 +1,11 @@
 package geo
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
 	"github.com/stretchr/testify/assert"
@@ -38,4 +40,64 @@ func TestGeoLocation(t *testing.T) {
 	assert.Equal(t, "gb", req.CountryCode)
 	assert.Equal(t, "England", req.Region)
 	assert.Equal(t, "London", req.City)
+	assert.Equal(t, "EU", req.ContinentCode)
+	assert.Equal(t, "Europe/London", req.TimeZone)
+	assert.Equal(t, float32(51.5), req.Latitude)
+	assert.Equal(t, float32(-0.1), req.Longitude)
+	assert.True(t, req.InEU) // the test database is from before Brexit
+}
+
+func TestGeoWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	copyFile(t, "../../../test/GeoIP2-City-Test.mmdb", path)
+	geoDB, _ := NewGeo("", "", "")
+	_, loaded := geoDB.Metadata()
+	assert.False(t, loaded)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, geoDB.Watch(ctx, path, time.Millisecond*10, nil))
+	metadata, loaded := geoDB.Metadata()
+	assert.True(t, loaded)
+	assert.Equal(t, "GeoIP2-City", metadata.DatabaseType)
+	assert.False(t, metadata.BuildTime.IsZero())
+	assert.False(t, metadata.LoadedAt.IsZero())
+	req := &ingest.Request{IP: "81.2.69.142"}
+	_, _ = geoDB.Step(req)
+	assert.Equal(t, "London", req.City)
+
+	// an invalid file must not replace the database
+	assert.NoError(t, os.WriteFile(path, []byte("invalid"), 0644))
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	time.Sleep(time.Millisecond * 50)
+	metadata, _ = geoDB.Metadata()
+	assert.Equal(t, "GeoIP2-City", metadata.DatabaseType)
+	req = &ingest.Request{IP: "81.2.69.142"}
+	_, _ = geoDB.Step(req)
+	assert.Equal(t, "London", req.City)
+
+	// a valid file replaces the database
+	copyFile(t, "../../../test/GeoLite2-ASN-Test.mmdb", path)
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second*2)))
+	assert.Eventually(t, func() bool {
+		metadata, _ = geoDB.Metadata()
+		return metadata.DatabaseType == "GeoLite2-ASN"
+	}, time.Second, time.Millisecond*10)
+
+	// missing files cannot be watched
+	assert.Error(t, geoDB.Watch(ctx, filepath.Join(t.TempDir(), "missing.mmdb"), 0, nil))
+}
+
+func TestGeoUpdateFromFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.mmdb")
+	assert.NoError(t, os.WriteFile(path, []byte("invalid"), 0644))
+	geoDB, _ := NewGeo("", "", "")
+	assert.Error(t, geoDB.UpdateFromFile(path))
+	_, loaded := geoDB.Metadata()
+	assert.False(t, loaded)
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := os.ReadFile(src)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(dst, data, 0644))
 }
@@ -0,0 +1,227 @@
+package geo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ip2LocationHeaderSize = 64
+)
+
+var (
+	errIP2LocationOutOfRange = errors.New("invalid IP2Location database: offset out of range")
+)
+
+// ip2LocationHeader is the header of an IP2Location BIN database.
+// All numbers are little endian and addresses start at 1.
+type ip2LocationHeader struct {
+	dbType      uint8
+	columns     uint8
+	year        uint8
+	month       uint8
+	day         uint8
+	ipv4Count   uint32
+	ipv4Addr    uint32
+	ipv6Count   uint32
+	ipv6Addr    uint32
+	productCode uint8
+}
+
+// IP2Location is a Provider for the IP2Location LITE BIN databases (DB1, DB3, DB5, DB11, ...).
+// Both the IPv4 and IPv6 versions are supported. The database is kept in memory.
+// Use LoadIP2Location for the CSV format.
+type IP2Location struct {
+	data     []byte
+	header   ip2LocationHeader
+	loadedAt time.Time
+}
+
+// NewIP2Location creates a new IP2Location provider for given BIN database.
+func NewIP2Location(data []byte) (*IP2Location, error) {
+	header, ok := parseIP2LocationHeader(data)
+
+	if !ok {
+		return nil, errors.New("invalid IP2Location BIN database")
+	}
+
+	db := &IP2Location{
+		data:     data,
+		header:   header,
+		loadedAt: time.Now().UTC(),
+	}
+
+	if !db.inRange(int(header.ipv4Addr)-1, int(header.ipv4Count)*db.rowSize(false)) ||
+		header.ipv6Count > 0 && !db.inRange(int(header.ipv6Addr)-1, int(header.ipv6Count)*db.rowSize(true)) {
+		return nil, errIP2LocationOutOfRange
+	}
+
+	return db, nil
+}
+
+// Lookup implements the Provider interface.
+func (db *IP2Location) Lookup(ip net.IP) (Location, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+
+	if !ok {
+		return Location{}, nil
+	}
+
+	addr = addr.Unmap()
+	ipv6 := !addr.Is4()
+	count, base := int(db.header.ipv4Count), int(db.header.ipv4Addr)-1
+
+	if ipv6 {
+		count, base = int(db.header.ipv6Count), int(db.header.ipv6Addr)-1
+	}
+
+	// each row starts with the first IP of its range, which ends before the next row, so the last row only marks the end
+	size := db.rowSize(ipv6)
+	i := sort.Search(count, func(i int) bool {
+		return db.from(base+i*size, ipv6).Compare(addr) > 0
+	})
+
+	if i == count {
+		i--
+	}
+
+	if i <= 0 {
+		return Location{}, nil
+	}
+
+	return db.location(base+(i-1)*size, ipv6)
+}
+
+// Metadata returns the metadata of the database.
+func (db *IP2Location) Metadata() (Metadata, bool) {
+	return Metadata{
+		DatabaseType: fmt.Sprintf("IP2Location DB%d", db.header.dbType),
+		BuildTime:    time.Date(2000+int(db.header.year), time.Month(db.header.month), int(db.header.day), 0, 0, 0, 0, time.UTC),
+		LoadedAt:     db.loadedAt,
+	}, true
+}
+
+func (db *IP2Location) location(offset int, ipv6 bool) (Location, error) {
+	// the columns following the IP are pointers to the country, region, and city
+	offset += 4
+
+	if ipv6 {
+		offset += 12
+	}
+
+	country, err := db.string(db.uint32(offset))
+
+	if err != nil {
+		return Location{}, err
+	}
+
+	// unknown locations are marked with a dash
+	if country == "-" || country == "" {
+		return Location{}, nil
+	}
+
+	location := Location{CountryCode: strings.ToLower(country)}
+
+	// region and city are available for DB3 and above
+	if db.header.dbType >= 3 && db.header.columns >= 4 {
+		region, err := db.string(db.uint32(offset + 4))
+
+		if err != nil {
+			return Location{}, err
+		}
+
+		city, err := db.string(db.uint32(offset + 8))
+
+		if err != nil {
+			return Location{}, err
+		}
+
+		if region != "-" {
+			location.Region = region
+		}
+
+		if city != "-" {
+			location.City = city
+		}
+	}
+
+	return location, nil
+}
+
+func (db *IP2Location) from(offset int, ipv6 bool) netip.Addr {
+	if !ipv6 {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], db.uint32(offset))
+		return netip.AddrFrom4(b)
+	}
+
+	// IPv6 numbers are stored as little endian 128-bit integers
+	var b [16]byte
+
+	for i := range b {
+		b[i] = db.data[offset+15-i]
+	}
+
+	return netip.AddrFrom16(b)
+}
+
+func (db *IP2Location) uint32(offset int) uint32 {
+	return binary.LittleEndian.Uint32(db.data[offset:])
+}
+
+// string reads a string prefixed by its length at given offset (starting at 0).
+func (db *IP2Location) string(offset uint32) (string, error) {
+	if !db.inRange(int(offset), 1) || !db.inRange(int(offset)+1, int(db.data[offset])) {
+		return "", errIP2LocationOutOfRange
+	}
+
+	return string(db.data[offset+1 : offset+1+uint32(db.data[offset])]), nil
+}
+
+func (db *IP2Location) rowSize(ipv6 bool) int {
+	if ipv6 {
+		return 16 + (int(db.header.columns)-1)*4
+	}
+
+	return int(db.header.columns) * 4
+}
+
+func (db *IP2Location) inRange(offset, n int) bool {
+	return offset >= 0 && n >= 0 && offset+n <= len(db.data)
+}
+
+// parseIP2LocationHeader parses and validates the header of a BIN database.
+func parseIP2LocationHeader(data []byte) (ip2LocationHeader, bool) {
+	if len(data) < ip2LocationHeaderSize {
+		return ip2LocationHeader{}, false
+	}
+
+	header := ip2LocationHeader{
+		dbType:      data[0],
+		columns:     data[1],
+		year:        data[2],
+		month:       data[3],
+		day:         data[4],
+		ipv4Count:   binary.LittleEndian.Uint32(data[5:]),
+		ipv4Addr:    binary.LittleEndian.Uint32(data[9:]),
+		ipv6Count:   binary.LittleEndian.Uint32(data[13:]),
+		ipv6Addr:    binary.LittleEndian.Uint32(data[17:]),
+		productCode: data[29],
+	}
+
+	// databases before 2021 don't have a product code
+	if header.dbType < 1 || header.dbType > 26 || header.columns < 2 ||
+		header.month < 1 || header.month > 12 || header.day < 1 || header.day > 31 ||
+		header.ipv4Addr < ip2LocationHeaderSize || header.ipv6Count > 0 && header.ipv6Addr < ip2LocationHeaderSize ||
+		header.productCode != 1 && (header.productCode != 0 || header.year > 20) {
+		return ip2LocationHeader{}, false
+	}
+
+	return header, true
+}
@@ -0,0 +1,132 @@
+package geo
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIP2Location(t *testing.T) {
+	db, err := NewIP2Location(newIP2LocationBIN([]ip2LocationRow{
+		{"0.0.0.0", "-", "-", "-"},
+		{"1.0.0.0", "AU", "Queensland", "Brisbane"},
+		{"1.0.1.0", "-", "-", "-"},
+		{"81.2.69.0", "GB", "England", "London"},
+		{"81.2.70.0", "-", "-", "-"},
+		{"255.255.255.255", "-", "-", "-"},
+	}, []ip2LocationRow{
+		{"::", "-", "-", "-"},
+		{"2001:db8::", "DE", "Berlin", "-"},
+		{"2001:db9::", "-", "-", "-"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "-", "-", "-"},
+	}))
+	assert.NoError(t, err)
+
+	for ip, expected := range map[string]Location{
+		"81.2.69.142":           {CountryCode: "gb", Region: "England", City: "London"},
+		"::ffff:81.2.69.142":    {CountryCode: "gb", Region: "England", City: "London"},
+		"1.0.0.0":               {CountryCode: "au", Region: "Queensland", City: "Brisbane"},
+		"1.0.0.255":             {CountryCode: "au", Region: "Queensland", City: "Brisbane"},
+		"1.0.1.0":               {},
+		"0.0.0.1":               {},
+		"255.255.255.255":       {},
+		"2001:db8::1":           {CountryCode: "de", Region: "Berlin"},
+		"2001:db9::1":           {},
+		"ffff:ffff:ffff:ffff::": {},
+	} {
+		location, err := db.Lookup(net.ParseIP(ip))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, location, ip)
+	}
+
+	metadata, ok := db.Metadata()
+	assert.True(t, ok)
+	assert.Equal(t, "IP2Location DB3", metadata.DatabaseType)
+	assert.Equal(t, time.Date(2025, 10, 17, 0, 0, 0, 0, time.UTC), metadata.BuildTime)
+}
+
+func TestIP2LocationIPv4(t *testing.T) {
+	db, err := NewIP2Location(newIP2LocationBIN([]ip2LocationRow{
+		{"0.0.0.0", "-", "-", "-"},
+		{"81.2.69.0", "GB", "England", "London"},
+		{"81.2.70.0", "-", "-", "-"},
+		{"255.255.255.255", "-", "-", "-"},
+	}, nil))
+	assert.NoError(t, err)
+	location, err := db.Lookup(net.ParseIP("81.2.69.142"))
+	assert.NoError(t, err)
+	assert.Equal(t, "gb", location.CountryCode)
+	location, err = db.Lookup(net.ParseIP("2001:db8::1"))
+	assert.NoError(t, err)
+	assert.Empty(t, location.CountryCode)
+}
+
+func TestIP2LocationInvalid(t *testing.T) {
+	_, err := NewIP2Location([]byte(`"0","16777215","-","-","-","-"`))
+	assert.Error(t, err)
+	data := newIP2LocationBIN([]ip2LocationRow{
+		{"0.0.0.0", "-", "-", "-"},
+		{"255.255.255.255", "-", "-", "-"},
+	}, nil)
+	_, err = NewIP2Location(data[:ip2LocationHeaderSize+4])
+	assert.Error(t, err)
+}
+
+type ip2LocationRow struct {
+	from    string
+	country string
+	region  string
+	city    string
+}
+
+// newIP2LocationBIN creates a DB3 BIN database for given rows.
+func newIP2LocationBIN(ipv4, ipv6 []ip2LocationRow) []byte {
+	const columns = 4
+	ipv4Size, ipv6Size := columns*4, 16+(columns-1)*4
+	ipv4Addr := ip2LocationHeaderSize + 1
+	ipv6Addr := ipv4Addr + len(ipv4)*ipv4Size
+	data := make([]byte, ipv6Addr-1+len(ipv6)*ipv6Size)
+	data[0], data[1], data[2], data[3], data[4] = 3, columns, 25, 10, 17
+	binary.LittleEndian.PutUint32(data[5:], uint32(len(ipv4)))
+	binary.LittleEndian.PutUint32(data[9:], uint32(ipv4Addr))
+	binary.LittleEndian.PutUint32(data[13:], uint32(len(ipv6)))
+	binary.LittleEndian.PutUint32(data[17:], uint32(ipv6Addr))
+	data[29] = 1
+	addString := func(s string) uint32 {
+		offset := uint32(len(data))
+		data = append(data, byte(len(s)))
+		data = append(data, s...)
+		return offset
+	}
+	addRow := func(offset int, row ip2LocationRow) {
+		pointers := []uint32{addString(row.country), addString(row.region), addString(row.city)}
+
+		for i, pointer := range pointers {
+			binary.LittleEndian.PutUint32(data[offset+i*4:], pointer)
+		}
+	}
+
+	for i, row := range ipv4 {
+		offset := ipv4Addr - 1 + i*ipv4Size
+		b := netip.MustParseAddr(row.from).As4()
+		binary.LittleEndian.PutUint32(data[offset:], binary.BigEndian.Uint32(b[:]))
+		addRow(offset+4, row)
+	}
+
+	for i, row := range ipv6 {
+		offset := ipv6Addr - 1 + i*ipv6Size
+		b := netip.MustParseAddr(row.from).As16()
+
+		for j := range b {
+			data[offset+j] = b[15-j]
+		}
+
+		addRow(offset+16, row)
+	}
+
+	return data
+}
@@ -0,0 +1,142 @@
+package geo
+
+import (
+	"math"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+)
+
+// Location is the geolocation for an IP.
+type Location struct {
+	// CountryCode is the lowercase country ISO code (like "gb").
+	CountryCode string
+
+	// Region is the name of the subdivision (like "England").
+	Region string
+
+	// City is the name of the city (like "London").
+	City string
+
+	// ContinentCode is the continent code (like "EU").
+	ContinentCode string
+
+	// TimeZone is the IANA time zone (like "Europe/London").
+	TimeZone string
+
+	// Latitude is the approximate latitude.
+	Latitude float64
+
+	// Longitude is the approximate longitude.
+	Longitude float64
+
+	// InEU is set to true if the country is a member state of the European Union.
+	InEU bool
+}
+
+// Provider looks up the Location for an IP.
+// It is implemented by Geo for MaxMind (or compatible, like DB-IP) databases,
+// by Ranges for static IP range and CIDR lists, by IP2Location for IP2Location BIN databases,
+// and by Chain to combine multiple providers.
+type Provider interface {
+	// Lookup returns the Location for given IP.
+	// An empty Location is returned if the IP could not be found.
+	Lookup(net.IP) (Location, error)
+}
+
+// EUCountries is the list of lowercase country codes of member states of the European Union.
+// It's used to set the ingest.Request InEU flag if the Provider doesn't.
+var EUCountries = []string{
+	"at", "be", "bg", "cy", "cz", "de", "dk", "ee", "es", "fi", "fr", "gr", "hr", "hu",
+	"ie", "it", "lt", "lu", "lv", "mt", "nl", "pl", "pt", "ro", "se", "si", "sk",
+}
+
+// Locator sets the geolocation fields for a request using a Provider.
+type Locator struct {
+	provider Provider
+}
+
+// NewLocator creates a new Locator for given Provider.
+func NewLocator(provider Provider) *Locator {
+	return &Locator{
+		provider: provider,
+	}
+}
+
+// Step implements ingest.PipeStep to process a step.
+// It looks up the country code, subdivision (region), city, continent, time zone, coordinates, and EU flag for given IP.
+// If the IP is invalid or cannot be found, it won't do anything.
+func (locator *Locator) Step(request *ingest.Request) (bool, error) {
+	setLocation(locator.provider, request)
+	return false, nil
+}
+
+// Chain is a Provider that looks up an IP using multiple providers in order.
+// The first Location found is returned, unless it has no city.
+// In that case, the following providers are used to find a Location with a city for the same country.
+type Chain struct {
+	providers []Provider
+}
+
+// NewChain creates a new Chain for given providers.
+func NewChain(providers ...Provider) *Chain {
+	return &Chain{
+		providers: providers,
+	}
+}
+
+// Lookup implements the Provider interface.
+// Providers returning an error are skipped.
+func (chain *Chain) Lookup(ip net.IP) (Location, error) {
+	var location Location
+
+	for _, provider := range chain.providers {
+		l, err := provider.Lookup(ip)
+
+		if err != nil || l.CountryCode == "" {
+			continue
+		}
+
+		if location.CountryCode == "" {
+			location = l
+		} else if l.CountryCode != location.CountryCode {
+			continue
+		}
+
+		if l.City != "" {
+			return l, nil
+		}
+	}
+
+	return location, nil
+}
+
+func setLocation(provider Provider, request *ingest.Request) {
+	parsedIP := net.ParseIP(request.IP)
+
+	if parsedIP == nil {
+		return
+	}
+
+	location, err := provider.Lookup(parsedIP)
+
+	if err != nil {
+		return
+	}
+
+	request.CountryCode = strings.ToLower(location.CountryCode)
+	request.Region = location.Region
+	request.City = location.City
+	request.ContinentCode = strings.ToUpper(location.ContinentCode)
+	request.TimeZone = location.TimeZone
+	request.Latitude = roundCoordinate(location.Latitude)
+	request.Longitude = roundCoordinate(location.Longitude)
+	request.InEU = location.InEU || slices.Contains(EUCountries, request.CountryCode)
+}
+
+// roundCoordinate rounds the coordinate to one decimal place (about 11 km), so that visitors cannot be located precisely.
+func roundCoordinate(coordinate float64) float32 {
+	return float32(math.Round(coordinate*10) / 10)
+}
@@ -0,0 +1,96 @@
+package geo
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocator(t *testing.T) {
+	locator := NewLocator(newTestRanges(t, "81.2.69.0", Location{CountryCode: "gb", Region: "England", City: "London"}))
+	req := &ingest.Request{IP: "81.2.69.142"}
+	cancel, err := locator.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.Equal(t, "gb", req.CountryCode)
+	assert.Equal(t, "England", req.Region)
+	assert.Equal(t, "London", req.City)
+	assert.False(t, req.InEU)
+
+	// extended fields and the EU flag derived from the country code
+	locator = NewLocator(newTestRanges(t, "81.2.69.0", Location{
+		CountryCode:   "DE",
+		City:          "Berlin",
+		ContinentCode: "eu",
+		TimeZone:      "Europe/Berlin",
+		Latitude:      52.5167,
+		Longitude:     13.3833,
+	}))
+	req = &ingest.Request{IP: "81.2.69.142"}
+	_, err = locator.Step(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "de", req.CountryCode)
+	assert.Equal(t, "EU", req.ContinentCode)
+	assert.Equal(t, "Europe/Berlin", req.TimeZone)
+	assert.Equal(t, float32(52.5), req.Latitude)
+	assert.Equal(t, float32(13.4), req.Longitude)
+	assert.True(t, req.InEU)
+	req = &ingest.Request{IP: "invalid"}
+	cancel, err = locator.Step(req)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.Empty(t, req.CountryCode)
+}
+
+func TestChain(t *testing.T) {
+	country := newTestRanges(t, "81.2.69.0", Location{CountryCode: "gb"})
+	city := newTestRanges(t, "81.2.69.0", Location{CountryCode: "gb", Region: "England", City: "London"})
+	otherCountry := newTestRanges(t, "81.2.69.0", Location{CountryCode: "de", City: "Berlin"})
+	geoDB, _ := NewGeo("", "", "")
+	assert.NoError(t, geoDB.UpdateFromFile("../../../test/GeoIP2-City-Test.mmdb"))
+	ip := net.ParseIP("81.2.69.142")
+
+	// fall back to the next provider if the city is missing
+	location, err := NewChain(country, city).Lookup(ip)
+	assert.NoError(t, err)
+	assert.Equal(t, "London", location.City)
+
+	// the first provider with a city wins
+	location, _ = NewChain(city, otherCountry).Lookup(ip)
+	assert.Equal(t, "London", location.City)
+
+	// a city for a different country is ignored
+	location, _ = NewChain(country, otherCountry).Lookup(ip)
+	assert.Equal(t, Location{CountryCode: "gb"}, location)
+
+	// errors and missing results are skipped
+	location, _ = NewChain(errorProvider{}, newTestRanges(t, "1.0.0.0", Location{CountryCode: "au"}), geoDB).Lookup(ip)
+	assert.Equal(t, "gb", location.CountryCode)
+	assert.Equal(t, "England", location.Region)
+	assert.Equal(t, "London", location.City)
+	location, _ = NewChain(country).Lookup(net.ParseIP("1.0.0.1"))
+	assert.Empty(t, location)
+}
+
+func newTestRanges(t *testing.T, from string, location Location) *Ranges {
+	addr := netip.MustParseAddr(from)
+	ranges, err := NewRanges([]Range{
+		{
+			From:     addr,
+			To:       lastAddr(netip.PrefixFrom(addr, 24)),
+			Location: location,
+		},
+	})
+	assert.NoError(t, err)
+	return ranges
+}
+
+type errorProvider struct{}
+
+func (errorProvider) Lookup(net.IP) (Location, error) {
+	return Location{}, errors.New("lookup failed")
+}
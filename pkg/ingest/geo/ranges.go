@@ -0,0 +1,249 @@
+package geo
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Range is a range of IPs mapped to a Location.
+type Range struct {
+	// From is the first IP of the range.
+	From netip.Addr
+
+	// To is the last IP of the range (inclusive).
+	To netip.Addr
+
+	// Location is the Location for all IPs in the range.
+	Location Location
+}
+
+var (
+	// ErrBinaryDatabase is returned by LoadIP2Location if the database is in the binary (BIN) format.
+	// Use NewIP2Location for BIN databases.
+	ErrBinaryDatabase = errors.New("binary database format, use NewIP2Location")
+)
+
+// Ranges is a Provider mapping static IP ranges to their Location.
+// It can be used for CIDR-to-country lists, the IP2Location LITE CSV databases, or for testing.
+// The ranges must not overlap.
+type Ranges struct {
+	ranges []Range
+}
+
+// NewRanges creates a new Ranges provider for given list of ranges.
+func NewRanges(ranges []Range) (*Ranges, error) {
+	r := make([]Range, 0, len(ranges))
+
+	for _, ipRange := range ranges {
+		ipRange.From = ipRange.From.Unmap()
+		ipRange.To = ipRange.To.Unmap()
+
+		if !ipRange.From.IsValid() || !ipRange.To.IsValid() ||
+			ipRange.From.Is4() != ipRange.To.Is4() ||
+			ipRange.From.Compare(ipRange.To) > 0 {
+			return nil, fmt.Errorf("invalid IP range %s - %s", ipRange.From, ipRange.To)
+		}
+
+		ipRange.Location.CountryCode = strings.ToLower(ipRange.Location.CountryCode)
+		r = append(r, ipRange)
+	}
+
+	slices.SortFunc(r, func(a, b Range) int {
+		return a.From.Compare(b.From)
+	})
+	return &Ranges{
+		ranges: r,
+	}, nil
+}
+
+// LoadCIDR creates a new Ranges provider from a CSV file mapping CIDR notations to their Location.
+// Each line must contain the CIDR and country code, optionally followed by the region and city,
+// like: 81.2.69.0/24,GB,England,London.
+// A header line and lines starting with # are ignored.
+func LoadCIDR(r io.Reader) (*Ranges, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	ranges := make([]Range, 0)
+
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected at least two fields", i+1)
+		}
+
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		ipRange := Range{
+			From:     prefix.Masked().Addr(),
+			To:       lastAddr(prefix),
+			Location: Location{CountryCode: strings.TrimSpace(record[1])},
+		}
+
+		if len(record) > 2 {
+			ipRange.Location.Region = strings.TrimSpace(record[2])
+		}
+
+		if len(record) > 3 {
+			ipRange.Location.City = strings.TrimSpace(record[3])
+		}
+
+		ranges = append(ranges, ipRange)
+	}
+
+	return NewRanges(ranges)
+}
+
+// LoadIP2Location creates a new Ranges provider from an IP2Location LITE CSV database (DB1, DB3, DB5, DB11, ...).
+// Both the IPv4 and IPv6 versions are supported.
+// The columns must be: ip_from, ip_to, country_code, country_name, and optionally region_name and city_name.
+// ErrBinaryDatabase is returned for the BIN format, which can be read using NewIP2Location.
+func LoadIP2Location(r io.Reader) (*Ranges, error) {
+	buffered := bufio.NewReader(r)
+
+	if header, err := buffered.Peek(ip2LocationHeaderSize); err == nil {
+		if _, ok := parseIP2LocationHeader(header); ok {
+			return nil, ErrBinaryDatabase
+		}
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	ranges := make([]Range, 0)
+
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if len(record) < 4 {
+			return nil, fmt.Errorf("line %d: expected at least four fields", i+1)
+		}
+
+		// unknown locations are marked with a dash
+		if record[2] == "-" || record[2] == "" {
+			continue
+		}
+
+		from, err := decimalToAddr(record[0])
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		to, err := decimalToAddr(record[1])
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		ipRange := Range{
+			From:     from,
+			To:       to,
+			Location: Location{CountryCode: record[2]},
+		}
+
+		if len(record) > 4 && record[4] != "-" {
+			ipRange.Location.Region = record[4]
+		}
+
+		if len(record) > 5 && record[5] != "-" {
+			ipRange.Location.City = record[5]
+		}
+
+		ranges = append(ranges, ipRange)
+	}
+
+	return NewRanges(ranges)
+}
+
+// Lookup implements the Provider interface.
+func (ranges *Ranges) Lookup(ip net.IP) (Location, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+
+	if !ok {
+		return Location{}, nil
+	}
+
+	addr = addr.Unmap()
+
+	// find the first range starting after the IP, the range before must contain the IP
+	i, _ := slices.BinarySearchFunc(ranges.ranges, addr, func(r Range, addr netip.Addr) int {
+		if r.From.Compare(addr) <= 0 {
+			return -1
+		}
+
+		return 1
+	})
+
+	if i > 0 && ranges.ranges[i-1].To.Compare(addr) >= 0 {
+		return ranges.ranges[i-1].Location, nil
+	}
+
+	return Location{}, nil
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Masked().Addr()
+	b := addr.AsSlice()
+	bits := prefix.Bits()
+
+	for i := range b {
+		if bits >= 8 {
+			bits -= 8
+			continue
+		}
+
+		b[i] |= byte(0xff >> bits)
+		bits = 0
+	}
+
+	last, _ := netip.AddrFromSlice(b)
+	return last
+}
+
+func decimalToAddr(decimal string) (netip.Addr, error) {
+	n, ok := new(big.Int).SetString(strings.TrimSpace(decimal), 10)
+
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return netip.Addr{}, fmt.Errorf("invalid IP number %s", decimal)
+	}
+
+	if n.BitLen() <= 32 {
+		var b [4]byte
+		n.FillBytes(b[:])
+		return netip.AddrFrom4(b), nil
+	}
+
+	var b [16]byte
+	n.FillBytes(b[:])
+	return netip.AddrFrom16(b).Unmap(), nil
+}
@@ -0,0 +1,102 @@
+package geo
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRanges(t *testing.T) {
+	ranges, err := NewRanges([]Range{
+		{
+			From:     netip.MustParseAddr("81.2.69.0"),
+			To:       netip.MustParseAddr("81.2.69.255"),
+			Location: Location{CountryCode: "GB", Region: "England", City: "London"},
+		},
+		{
+			From:     netip.MustParseAddr("1.0.0.0"),
+			To:       netip.MustParseAddr("1.0.0.255"),
+			Location: Location{CountryCode: "au"},
+		},
+		{
+			From:     netip.MustParseAddr("2001:db8::"),
+			To:       netip.MustParseAddr("2001:db8::ffff"),
+			Location: Location{CountryCode: "de", City: "Berlin"},
+		},
+	})
+	assert.NoError(t, err)
+
+	for ip, expected := range map[string]Location{
+		"81.2.69.0":          {CountryCode: "gb", Region: "England", City: "London"},
+		"81.2.69.142":        {CountryCode: "gb", Region: "England", City: "London"},
+		"::ffff:81.2.69.255": {CountryCode: "gb", Region: "England", City: "London"},
+		"1.0.0.1":            {CountryCode: "au"},
+		"2001:db8::1":        {CountryCode: "de", City: "Berlin"},
+		"81.2.70.0":          {},
+		"0.0.0.1":            {},
+		"2001:db8::1:0":      {},
+		"::1":                {},
+	} {
+		location, err := ranges.Lookup(net.ParseIP(ip))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, location, ip)
+	}
+
+	_, err = NewRanges([]Range{{From: netip.MustParseAddr("1.0.0.1"), To: netip.MustParseAddr("1.0.0.0")}})
+	assert.Error(t, err)
+	_, err = NewRanges([]Range{{From: netip.MustParseAddr("1.0.0.1"), To: netip.MustParseAddr("2001:db8::")}})
+	assert.Error(t, err)
+}
+
+func TestLoadCIDR(t *testing.T) {
+	ranges, err := LoadCIDR(strings.NewReader(`cidr,country,region,city
+# comment
+81.2.69.0/24,GB,England,London
+1.0.0.0/23,AU
+2001:db8::/32, DE, Berlin, Berlin
+`))
+	assert.NoError(t, err)
+	assert.Len(t, ranges.ranges, 3)
+	location, _ := ranges.Lookup(net.ParseIP("81.2.69.142"))
+	assert.Equal(t, Location{CountryCode: "gb", Region: "England", City: "London"}, location)
+	location, _ = ranges.Lookup(net.ParseIP("1.0.1.255"))
+	assert.Equal(t, "au", location.CountryCode)
+	location, _ = ranges.Lookup(net.ParseIP("1.0.2.0"))
+	assert.Empty(t, location.CountryCode)
+	location, _ = ranges.Lookup(net.ParseIP("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"))
+	assert.Equal(t, Location{CountryCode: "de", Region: "Berlin", City: "Berlin"}, location)
+	_, err = LoadCIDR(strings.NewReader("81.2.69.0/24,GB\ninvalid,GB"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestLoadIP2Location(t *testing.T) {
+	// IPv4 and IPv6 (IPv4 mapped and native) DB3 rows
+	ranges, err := LoadIP2Location(strings.NewReader(`"0","16777215","-","-","-","-"
+"1359103232","1359103487","GB","United Kingdom of Great Britain and Northern Ireland","England","London"
+"281470698520576","281470698520831","AU","Australia","Queensland","Brisbane"
+"42540766411282592856903984951653826560","42540766411282592856903984951653892095","DE","Germany","Berlin","-"
+`))
+	assert.NoError(t, err)
+	assert.Len(t, ranges.ranges, 3)
+	location, _ := ranges.Lookup(net.ParseIP("81.2.69.142"))
+	assert.Equal(t, Location{CountryCode: "gb", Region: "England", City: "London"}, location)
+	location, _ = ranges.Lookup(net.ParseIP("1.0.0.1"))
+	assert.Equal(t, Location{CountryCode: "au", Region: "Queensland", City: "Brisbane"}, location)
+	location, _ = ranges.Lookup(net.ParseIP("2001:db8::1"))
+	assert.Equal(t, Location{CountryCode: "de", Region: "Berlin"}, location)
+	location, _ = ranges.Lookup(net.ParseIP("0.0.0.1"))
+	assert.Empty(t, location.CountryCode)
+	_, err = LoadIP2Location(strings.NewReader(`"a","b","GB","United Kingdom"`))
+	assert.Error(t, err)
+
+	// BIN databases must be rejected
+	_, err = LoadIP2Location(bytes.NewReader(newIP2LocationBIN([]ip2LocationRow{
+		{"0.0.0.0", "-", "-", "-"},
+		{"255.255.255.255", "-", "-", "-"},
+	}, nil)))
+	assert.ErrorIs(t, err, ErrBinaryDatabase)
+}
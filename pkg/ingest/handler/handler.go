@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+)
+
+const (
+	defaultMaxBodySize  = 1024 * 64 // 64 KB
+	defaultMaxBatchSize = 50
+)
+
+var (
+	// dropHeader are headers of the tracking request that are not passed to the Pipe.
+	dropHeader = []string{
+		"Authorization",
+		"Content-Length",
+		"Content-Type",
+		"Cookie",
+		"Origin",
+		"Referer",
+	}
+)
+
+// Options is the configuration for a Handler.
+type Options struct {
+	// Pipe is the Pipe hits are passed to (mandatory).
+	Pipe *ingest.Pipe
+
+	// SiteLookup resolves the site ID for a hit (mandatory).
+	SiteLookup SiteLookupFunc
+
+	// MaxBodySize is the maximum size of the request body in bytes.
+	// If set to <= 0, the default value of 64 KB will be used.
+	MaxBodySize int64
+
+	// MaxBatchSize is the maximum number of hits in a single request.
+	// If set to <= 0, the default value of 50 will be used.
+	MaxBatchSize int
+
+	// AllowOrigin sets the Access-Control-Allow-Origin header.
+	// If not set, all origins are allowed.
+	AllowOrigin string
+
+	// Logger is the logger for the Handler.
+	// If not set, the default slog.Logger will be used.
+	Logger *slog.Logger
+}
+
+func (options *Options) validate() {
+	if options.MaxBodySize <= 0 {
+		options.MaxBodySize = defaultMaxBodySize
+	}
+
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = defaultMaxBatchSize
+	}
+
+	if options.AllowOrigin == "" {
+		options.AllowOrigin = "*"
+	}
+
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
+}
+
+// Handler is a http.Handler accepting page views, events, and session keep-alives sent by a tracking script.
+// The body can either be a single Hit or a JSON array of hits,
+// sent as application/json or text/plain (for navigator.sendBeacon).
+// Hits are passed to the Pipe in order before the Handler responds with http.StatusAccepted.
+// This is done synchronously, so that the Pipe.Process overload policy applies to the client,
+// while the hits are stored asynchronously by the Pipe workers.
+// If the Pipe is overloaded or has been stopped, the Handler responds with http.StatusServiceUnavailable
+// and the remaining hits of a batch are dropped.
+// The response body then contains the number of hits that have been accepted, like {"accepted":2},
+// so that the client only retries the hits following the accepted ones.
+type Handler struct {
+	pipe         *ingest.Pipe
+	siteLookup   SiteLookupFunc
+	maxBodySize  int64
+	maxBatchSize int
+	allowOrigin  string
+	logger       *slog.Logger
+}
+
+// NewHandler creates a new Handler for given Options.
+func NewHandler(options Options) *Handler {
+	options.validate()
+	return &Handler{
+		pipe:         options.Pipe,
+		siteLookup:   options.SiteLookup,
+		maxBodySize:  options.MaxBodySize,
+		maxBatchSize: options.MaxBatchSize,
+		allowOrigin:  options.AllowOrigin,
+		logger:       options.Logger,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", h.allowOrigin)
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.isSupportedContentType(r.Header.Get("Content-Type")) {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	hits, err := decodeHits(body, h.maxBatchSize)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	requests := make([]*ingest.Request, 0, len(hits))
+
+	for i := range hits {
+		siteID, ok := h.siteLookup(r, hits[i].u.Hostname(), hits[i].Site)
+
+		if !ok {
+			http.Error(w, "site not found", http.StatusNotFound)
+			return
+		}
+
+		requests = append(requests, h.newRequest(r, siteID, &hits[i]))
+	}
+
+	// process in order, so that the session state is updated correctly for batches
+	for i, request := range requests {
+		if err := h.pipe.Process(request); err != nil {
+			if errors.Is(err, ingest.ErrPipeOverloaded) || errors.Is(err, context.Canceled) {
+				h.serviceUnavailable(w, i)
+				return
+			}
+
+			h.logger.Debug("Error processing hit", "err", err, "site_id", request.SiteID)
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) serviceUnavailable(w http.ResponseWriter, accepted int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	if err := json.NewEncoder(w).Encode(struct {
+		Accepted int `json:"accepted"`
+	}{accepted}); err != nil {
+		h.logger.Debug("Error writing response", "err", err)
+	}
+}
+
+func (h *Handler) isSupportedContentType(contentType string) bool {
+	// navigator.sendBeacon sends strings as text/plain
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || mediaType == "text/plain")
+}
+
+func (h *Handler) newRequest(r *http.Request, siteID uint64, hit *Hit) *ingest.Request {
+	// the request must not be canceled once the response has been sent
+	req := r.Clone(context.Background())
+	u := *hit.u
+	req.Method = http.MethodGet
+	req.URL = &u
+	req.Host = u.Host
+	req.RequestURI = ""
+	req.Body = http.NoBody
+	req.ContentLength = 0
+
+	for _, header := range dropHeader {
+		req.Header.Del(header)
+	}
+
+	return &ingest.Request{
+		SiteID:              siteID,
+		ClientID:            hit.ClientID,
+		User:                hit.User,
+		Request:             req,
+		Title:               hit.Title,
+		Referrer:            hit.Referrer,
+		ScreenWidth:         hit.ScreenWidth,
+		ScreenHeight:        hit.ScreenHeight,
+		Tags:                hit.Tags,
+		EventName:           hit.EventName,
+		EventMetaData:       hit.EventMetaData,
+		EventNonInteractive: hit.EventNonInteractive,
+		UpdateSession:       hit.UpdateSession,
+	}
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/synctest"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	storage := db.NewMock()
+	step := new(recordStep)
+	pipe := ingest.NewPipe(ingest.PipeOptions{
+		Storage: storage,
+	}).Use(step)
+	handler := NewHandler(Options{
+		Pipe:       pipe,
+		SiteLookup: NewHostnameLookup(map[string]uint64{"example.com": 42}).Lookup,
+	})
+
+	// send a page view
+	w := serve(handler, "application/json", `{"url": "https://example.com/foo?utm_source=test", "title": "Foo", "referrer": "https://google.com", "screen_width": 1920, "screen_height": 1080, "tags": {"key": "value"}}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// send a batch of events and a session keep-alive using sendBeacon
+	w = serve(handler, "text/plain;charset=UTF-8", `[
+		{"url": "https://example.com/foo", "event_name": "click", "event_meta": {"button": "buy"}},
+		{"url": "https://example.com/foo", "event_name": "scroll", "non_interactive": true},
+		{"url": "https://example.com/foo", "update_session": true, "client_id": "client", "user": "user"}
+	]`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	pipe.Stop()
+	assert.Len(t, step.requests, 4)
+	assert.Equal(t, "Mozilla/5.0", step.requests[0].Request.UserAgent())
+	assert.Equal(t, "example.com", step.requests[0].Request.Host)
+	assert.Empty(t, step.requests[0].Request.Referer())
+	assert.Empty(t, step.requests[0].Request.Header.Get("Content-Type"))
+	assert.Equal(t, uint16(1920), step.requests[0].ScreenWidth)
+	assert.Equal(t, uint16(1080), step.requests[0].ScreenHeight)
+	assert.False(t, step.requests[1].EventNonInteractive)
+	assert.True(t, step.requests[2].EventNonInteractive)
+	assert.True(t, step.requests[3].UpdateSession)
+	assert.Equal(t, "client", step.requests[3].ClientID)
+	assert.Equal(t, "user", step.requests[3].User)
+	pageViews := storage.PageViews()
+	assert.Len(t, pageViews, 1)
+	assert.Equal(t, uint64(42), pageViews[0].SiteID)
+	assert.Equal(t, "example.com", pageViews[0].Hostname)
+	assert.Equal(t, "/foo", pageViews[0].Path)
+	assert.Equal(t, "Foo", pageViews[0].Title)
+	assert.Equal(t, "https://google.com", pageViews[0].Referrer)
+	assert.Equal(t, "value", pageViews[0].Tags["key"])
+	events := storage.Events()
+	assert.Len(t, events, 2)
+	assert.Equal(t, "click", events[0].Name)
+	assert.Equal(t, "buy", events[0].MetaData["button"])
+	assert.Equal(t, "scroll", events[1].Name)
+	requests := storage.Requests()
+	assert.Len(t, requests, 4)
+	assert.Equal(t, "utm_source=test", requests[0].Query)
+	assert.Empty(t, requests[0].Headers["Content-Type"])
+}
+
+func TestHandlerInvalid(t *testing.T) {
+	pipe := ingest.NewPipe(ingest.PipeOptions{
+		Storage: db.NewMock(),
+	})
+	defer pipe.Stop()
+	handler := NewHandler(Options{
+		Pipe:         pipe,
+		SiteLookup:   NewHostnameLookup(map[string]uint64{"example.com": 42}).Lookup,
+		MaxBodySize:  200,
+		MaxBatchSize: 2,
+	})
+	input := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{"application/json", "", http.StatusBadRequest},
+		{"application/json", "[]", http.StatusBadRequest},
+		{"application/json", "{", http.StatusBadRequest},
+		{"application/json", `{"url": ""}`, http.StatusBadRequest},
+		{"application/json", `{"url": "/foo"}`, http.StatusBadRequest},
+		{"application/json", `{"url": "ftp://example.com/"}`, http.StatusBadRequest},
+		{"application/json", `{"url": "https://unknown.com/"}`, http.StatusNotFound},
+		{"application/json", `[{"url": "https://example.com/"}, {"url": "https://example.com/"}, {"url": "https://example.com/"}]`, http.StatusBadRequest},
+		{"application/json", `{"url": "https://example.com/", "title": "` + strings.Repeat("a", 200) + `"}`, http.StatusRequestEntityTooLarge},
+		{"application/xml", `{"url": "https://example.com/"}`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, in := range input {
+		w := serve(handler, in.contentType, in.body)
+		assert.Equal(t, in.status, w.Code, in.body)
+	}
+
+	// only POST is allowed
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/p", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/p", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+}
+
+func TestHandlerOverloaded(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		storage := &blockingStorage{Mock: db.NewMock(), block: make(chan struct{})}
+		pipe := ingest.NewPipe(ingest.PipeOptions{
+			Storage:                  storage,
+			RequestChannelBufferSize: 1,
+			Worker:                   1,
+			WorkerBufferSize:         1,
+			OverloadPolicy:           ingest.OverloadDropNewest,
+		})
+		handler := NewHandler(Options{
+			Pipe:       pipe,
+			SiteLookup: NewHostnameLookup(map[string]uint64{"example.com": 42}).Lookup,
+		})
+
+		// the first hit blocks the only worker while flushing
+		synctest.Wait()
+		w := serve(handler, "application/json", `{"url": "https://example.com/first"}`)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		synctest.Wait()
+
+		// the second hit of the batch is buffered and the third one must be rejected
+		w = serve(handler, "application/json", `[{"url": "https://example.com/second"}, {"url": "https://example.com/third"}]`)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"accepted": 1}`, w.Body.String())
+		close(storage.block)
+		pipe.Stop()
+		assert.Len(t, storage.PageViews(), 2)
+
+		// hits must be rejected once the pipe has been stopped
+		w = serve(handler, "application/json", `{"url": "https://example.com/fourth"}`)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"accepted": 0}`, w.Body.String())
+	})
+}
+
+func TestHostnameLookup(t *testing.T) {
+	lookup := NewHostnameLookup(map[string]uint64{"Example.com": 1})
+	id, ok := lookup.Lookup(nil, "example.com", "")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), id)
+	lookup.Update(map[string]uint64{"foo.com": 2})
+	_, ok = lookup.Lookup(nil, "example.com", "")
+	assert.False(t, ok)
+	id, ok = lookup.Lookup(nil, "FOO.com", "")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), id)
+}
+
+type blockingStorage struct {
+	*db.Mock
+	block chan struct{}
+}
+
+func (client *blockingStorage) SavePageViews(ctx context.Context, pageViews []model.PageView) error {
+	<-client.block
+	return client.Mock.SavePageViews(ctx, pageViews)
+}
+
+type recordStep struct {
+	requests []*ingest.Request
+	m        sync.Mutex
+}
+
+func (step *recordStep) Step(request *ingest.Request) (bool, error) {
+	step.m.Lock()
+	defer step.m.Unlock()
+	step.requests = append(step.requests, request)
+
+	// session keep-alives are cancelled by the session step
+	return request.UpdateSession, nil
+}
+
+func serve(handler http.Handler, contentType, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "https://analytics.example.com/p", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	r.Header.Set("Referer", "https://example.com/foo")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+)
+
+var (
+	// ErrBatchSize is returned if a batch contains too many hits.
+	ErrBatchSize = errors.New("batch too large")
+
+	// ErrEmptyBatch is returned if the body does not contain any hits.
+	ErrEmptyBatch = errors.New("batch empty")
+
+	// ErrURLInvalid is returned if the URL of a hit is missing or invalid.
+	ErrURLInvalid = errors.New("url invalid")
+)
+
+// Hit is the payload for a page view, event, or session keep-alive sent by the client.
+type Hit struct {
+	// Site is an optional identifier for the site passed to the SiteLookupFunc.
+	Site string `json:"site"`
+
+	// URL is the full URL of the page (mandatory).
+	URL string `json:"url"`
+
+	// Title is the page title.
+	Title string `json:"title"`
+
+	// Referrer is the document referrer.
+	Referrer string `json:"referrer"`
+
+	// ScreenWidth is the screen width in pixels.
+	ScreenWidth uint16 `json:"screen_width"`
+
+	// ScreenHeight is the screen height in pixels.
+	ScreenHeight uint16 `json:"screen_height"`
+
+	// Tags are optional tags for page views.
+	Tags map[string]string `json:"tags"`
+
+	// EventName turns the hit into an event if set.
+	EventName string `json:"event_name"`
+
+	// EventMetaData is optional metadata for events.
+	EventMetaData map[string]any `json:"event_meta"`
+
+	// EventNonInteractive marks the event as non-interactive, so that the session stays bounced.
+	EventNonInteractive bool `json:"non_interactive"`
+
+	// ClientID is an optional visitor identifier set by the client (see ingest.Request ClientID).
+	ClientID string `json:"client_id"`
+
+	// User is an optional identifier for the authenticated user (see ingest.Request User).
+	User string `json:"user"`
+
+	// UpdateSession turns the hit into a session keep-alive.
+	// It updates the session without storing a page view or event.
+	UpdateSession bool `json:"update_session"`
+
+	u *url.URL
+}
+
+func (hit *Hit) validate() error {
+	u, err := url.ParseRequestURI(hit.URL)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrURLInvalid
+	}
+
+	hit.u = u
+	return nil
+}
+
+// decodeHits decodes a single hit or a batch of hits (JSON array) from given body.
+func decodeHits(body []byte, maxBatchSize int) ([]Hit, error) {
+	body = bytes.TrimSpace(body)
+
+	if len(body) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	var hits []Hit
+
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &hits); err != nil {
+			return nil, err
+		}
+	} else {
+		var hit Hit
+
+		if err := json.Unmarshal(body, &hit); err != nil {
+			return nil, err
+		}
+
+		hits = append(hits, hit)
+	}
+
+	if len(hits) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	if len(hits) > maxBatchSize {
+		return nil, ErrBatchSize
+	}
+
+	for i := range hits {
+		if err := hits[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// SiteLookupFunc resolves the site ID for a hit.
+// The hostname is taken from the hit URL and the identifier is the optional Hit.Site sent by the client.
+// It must return false if the site is unknown.
+type SiteLookupFunc func(r *http.Request, hostname, identifier string) (uint64, bool)
+
+// HostnameLookup is a SiteLookupFunc resolving sites by hostname.
+// Sites can be updated at runtime.
+type HostnameLookup struct {
+	sites map[string]uint64
+	m     sync.RWMutex
+}
+
+// NewHostnameLookup returns a new HostnameLookup for given hostname to site ID mapping.
+func NewHostnameLookup(sites map[string]uint64) *HostnameLookup {
+	lookup := &HostnameLookup{
+		sites: make(map[string]uint64),
+	}
+	lookup.Update(sites)
+	return lookup
+}
+
+// Update replaces the hostname to site ID mapping.
+func (lookup *HostnameLookup) Update(sites map[string]uint64) {
+	m := make(map[string]uint64, len(sites))
+
+	for hostname, id := range sites {
+		m[strings.ToLower(hostname)] = id
+	}
+
+	lookup.m.Lock()
+	defer lookup.m.Unlock()
+	lookup.sites = m
+}
+
+// Lookup implements the SiteLookupFunc.
+func (lookup *HostnameLookup) Lookup(_ *http.Request, hostname, _ string) (uint64, bool) {
+	lookup.m.RLock()
+	defer lookup.m.RUnlock()
+	id, ok := lookup.sites[strings.ToLower(hostname)]
+	return id, ok
+}
@@ -0,0 +1,352 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/util"
+)
+
+// MiddlewareOptions is the configuration for a Middleware.
+type MiddlewareOptions struct {
+	// Pipe is the Pipe requests are passed to (mandatory).
+	Pipe *ingest.Pipe
+
+	// SiteID is the site ID used for all requests if SiteLookup is not set.
+	SiteID uint64
+
+	// SiteLookup optionally resolves the site ID by the hostname of the request.
+	// The identifier passed to the SiteLookupFunc is always empty.
+	SiteLookup SiteLookupFunc
+
+	// Include is a list of path patterns to track.
+	// Patterns are matched using path.Match. Patterns ending with "/**" match all paths below the prefix (like "/blog/**").
+	// If empty, all paths are tracked.
+	Include []string
+
+	// Exclude is a list of path patterns not to track. It takes precedence over Include.
+	Exclude []string
+
+	// StaticExtensions is the list of file extensions (including the dot) that won't be tracked.
+	// If nil, util.StaticExtensions will be used.
+	StaticExtensions []string
+
+	// DisableBotFilter is a list of path patterns for which the bot filters are disabled.
+	DisableBotFilter []string
+
+	// Title optionally returns the page title for a request.
+	Title func(r *http.Request) string
+
+	// Worker is the number of workers passing requests to the Pipe.
+	// If set to <= 0, the default value of 10 will be used.
+	Worker int
+
+	// QueueSize is the number of requests waiting to be passed to the Pipe.
+	// Requests are dropped if the queue is full.
+	// If set to <= 0, the default value of 1000 will be used.
+	QueueSize int
+
+	// Logger is the logger for the Middleware.
+	// If not set, the default slog.Logger will be used.
+	Logger *slog.Logger
+}
+
+func (options *MiddlewareOptions) validate() {
+	if options.StaticExtensions == nil {
+		options.StaticExtensions = util.StaticExtensions
+	}
+
+	if options.Worker <= 0 {
+		options.Worker = 10
+	}
+
+	if options.QueueSize <= 0 {
+		options.QueueSize = 1000
+	}
+
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
+}
+
+// Middleware tracks page views server-side for an http.Handler.
+// Only successful (2xx) HTML responses to GET requests are tracked.
+// Requests are queued after the response has been written and passed to the Pipe by a fixed number of workers,
+// so that the latency of the wrapped handler is unaffected.
+// Stop must be called before stopping the Pipe.
+type Middleware struct {
+	pipe             *ingest.Pipe
+	siteID           uint64
+	siteLookup       SiteLookupFunc
+	include          []string
+	exclude          []string
+	staticExtensions []string
+	disableBotFilter []string
+	title            func(r *http.Request) string
+	requests         chan *ingest.Request
+	stopped          bool
+	stopLock         sync.RWMutex
+	wg               sync.WaitGroup
+	dropped          atomic.Uint64
+	logger           *slog.Logger
+}
+
+// NewMiddleware creates a new Middleware for given MiddlewareOptions.
+func NewMiddleware(options MiddlewareOptions) *Middleware {
+	options.validate()
+	m := &Middleware{
+		pipe:             options.Pipe,
+		siteID:           options.SiteID,
+		siteLookup:       options.SiteLookup,
+		include:          options.Include,
+		exclude:          options.Exclude,
+		staticExtensions: options.StaticExtensions,
+		disableBotFilter: options.DisableBotFilter,
+		title:            options.Title,
+		requests:         make(chan *ingest.Request, options.QueueSize),
+		logger:           options.Logger,
+	}
+
+	for range options.Worker {
+		m.wg.Go(m.process)
+	}
+
+	return m
+}
+
+// Handler wraps given http.Handler to track the requests it serves.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.track(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		if !rw.isHTML() {
+			return
+		}
+
+		siteID := m.siteID
+
+		if m.siteLookup != nil {
+			var ok bool
+			siteID, ok = m.siteLookup(r, hostname(r), "")
+
+			if !ok {
+				return
+			}
+		}
+
+		request := &ingest.Request{
+			SiteID:           siteID,
+			Request:          m.newRequest(r),
+			DisableBotFilter: m.match(m.disableBotFilter, r.URL.Path),
+		}
+
+		if m.title != nil {
+			request.Title = m.title(r)
+		}
+
+		m.enqueue(request)
+	})
+}
+
+// Stop stops tracking requests and waits for the queued requests to be passed to the Pipe.
+// It must be called before stopping the Pipe.
+func (m *Middleware) Stop() {
+	m.stopLock.Lock()
+
+	if !m.stopped {
+		m.stopped = true
+		close(m.requests)
+	}
+
+	m.stopLock.Unlock()
+	m.wg.Wait()
+}
+
+// Dropped returns the number of requests dropped because the queue was full.
+func (m *Middleware) Dropped() uint64 {
+	return m.dropped.Load()
+}
+
+func (m *Middleware) enqueue(request *ingest.Request) {
+	m.stopLock.RLock()
+	defer m.stopLock.RUnlock()
+
+	if m.stopped {
+		return
+	}
+
+	select {
+	case m.requests <- request:
+	default:
+		m.dropped.Add(1)
+		m.logger.Debug("Middleware queue full, request has been dropped", "site_id", request.SiteID)
+	}
+}
+
+func (m *Middleware) process() {
+	for request := range m.requests {
+		if err := m.pipe.Process(request); err != nil {
+			m.logger.Debug("Error processing request", "err", err, "site_id", request.SiteID)
+		}
+	}
+}
+
+func (m *Middleware) track(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+
+	p := r.URL.Path
+
+	if slices.Contains(m.staticExtensions, strings.ToLower(path.Ext(p))) {
+		return false
+	}
+
+	if m.match(m.exclude, p) {
+		return false
+	}
+
+	return len(m.include) == 0 || m.match(m.include, p)
+}
+
+func (m *Middleware) match(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "**"); ok && strings.HasSuffix(prefix, "/") && strings.HasPrefix(p, prefix) {
+			return true
+		}
+
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Middleware) newRequest(r *http.Request) *http.Request {
+	// the request must not be canceled once the response has been sent
+	req := r.Clone(context.Background())
+	req.Body = http.NoBody
+	req.ContentLength = 0
+
+	if req.URL.Host == "" {
+		req.URL.Host = req.Host
+	}
+
+	return req
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status   int
+	sniff    []byte
+	hijacked bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	// keep the first bytes to detect the content type if it hasn't been set explicitly
+	if len(w.sniff) < 512 {
+		w.sniff = append(w.sniff, b[:min(len(b), 512-len(w.sniff))]...)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements the http.Flusher interface if the wrapped http.ResponseWriter supports it.
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface if the wrapped http.ResponseWriter supports it.
+// Hijacked connections are not tracked.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := hijacker.Hijack()
+
+	if err == nil {
+		w.hijacked = true
+	}
+
+	return conn, rw, err
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) isHTML() bool {
+	if w.hijacked {
+		return false
+	}
+
+	status := w.status
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if status < 200 || status > 299 {
+		return false
+	}
+
+	contentType := w.Header().Get("Content-Type")
+
+	if contentType == "" {
+		if len(w.sniff) == 0 {
+			return false
+		}
+
+		contentType = http.DetectContentType(w.sniff)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+func hostname(r *http.Request) string {
+	if r.URL.Host != "" {
+		return r.URL.Hostname()
+	}
+
+	return (&url.URL{Host: r.Host}).Hostname()
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/synctest"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	storage := db.NewMock()
+	step := new(recordStep)
+	pipe := ingest.NewPipe(ingest.PipeOptions{
+		Storage: storage,
+	}).Use(step)
+	middleware := NewMiddleware(MiddlewareOptions{
+		Pipe:             pipe,
+		SiteID:           42,
+		Worker:           1,
+		Include:          []string{"/", "/blog/**", "/about"},
+		Exclude:          []string{"/blog/draft-*"},
+		DisableBotFilter: []string{"/about"},
+		Title: func(r *http.Request) string {
+			return "Title " + r.URL.Path
+		},
+	})
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/not-found":
+			http.NotFound(w, r)
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		case "/redirect":
+			http.Redirect(w, r, "/", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte("<!DOCTYPE html><html><body>Hello</body></html>"))
+		}
+	}))
+	input := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/", http.StatusOK},
+		{http.MethodGet, "/blog/post?foo=bar", http.StatusOK},
+		{http.MethodGet, "/blog/draft-post", http.StatusOK},
+		{http.MethodGet, "/about", http.StatusOK},
+		{http.MethodGet, "/pricing", http.StatusOK},
+		{http.MethodGet, "/style.css", http.StatusOK},
+		{http.MethodGet, "/not-found", http.StatusNotFound},
+		{http.MethodGet, "/redirect", http.StatusFound},
+		{http.MethodPost, "/", http.StatusOK},
+	}
+
+	for _, in := range input {
+		r := httptest.NewRequest(in.method, "http://example.com"+in.path, nil)
+		r.Header.Set("User-Agent", "Mozilla/5.0")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, in.status, w.Code, in.path)
+	}
+
+	middleware.Stop()
+	pipe.Stop()
+	assert.Len(t, step.requests, 3)
+	assert.Equal(t, "/", step.requests[0].Path)
+	assert.Equal(t, "Title /", step.requests[0].Title)
+	assert.Equal(t, uint64(42), step.requests[0].SiteID)
+	assert.Equal(t, "example.com", step.requests[0].Hostname)
+	assert.False(t, step.requests[0].DisableBotFilter)
+	assert.Equal(t, "/blog/post", step.requests[1].Path)
+	assert.Equal(t, "foo=bar", step.requests[1].Query)
+	assert.Equal(t, "/about", step.requests[2].Path)
+	assert.True(t, step.requests[2].DisableBotFilter)
+	assert.Len(t, storage.PageViews(), 3)
+}
+
+func TestMiddlewareSiteLookup(t *testing.T) {
+	storage := db.NewMock()
+	pipe := ingest.NewPipe(ingest.PipeOptions{
+		Storage: storage,
+	})
+	middleware := NewMiddleware(MiddlewareOptions{
+		Pipe:       pipe,
+		SiteLookup: NewHostnameLookup(map[string]uint64{"example.com": 42}).Lookup,
+	})
+	handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Hello"))
+	}))
+
+	for _, host := range []string{"example.com:8080", "unknown.com"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	middleware.Stop()
+	pipe.Stop()
+	pageViews := storage.PageViews()
+	assert.Len(t, pageViews, 1)
+	assert.Equal(t, uint64(42), pageViews[0].SiteID)
+}
+
+func TestMiddlewareQueueFull(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		storage := db.NewMock()
+		step := &blockingStep{block: make(chan struct{})}
+		pipe := ingest.NewPipe(ingest.PipeOptions{
+			Storage: storage,
+		}).Use(step)
+		middleware := NewMiddleware(MiddlewareOptions{
+			Pipe:      pipe,
+			SiteID:    42,
+			Worker:    1,
+			QueueSize: 1,
+		})
+		handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("<!DOCTYPE html><html><body>Hello</body></html>"))
+		}))
+
+		// the first request blocks the only worker, the second one is queued, and the third one must be dropped
+		for i := range 3 {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+			if i == 0 {
+				synctest.Wait()
+			}
+		}
+
+		assert.Equal(t, uint64(1), middleware.Dropped())
+		close(step.block)
+		middleware.Stop()
+		pipe.Stop()
+		assert.Len(t, storage.PageViews(), 2)
+
+		// requests must be ignored once the middleware has been stopped
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		assert.Equal(t, uint64(1), middleware.Dropped())
+	})
+}
+
+func TestMiddlewareResponseWriter(t *testing.T) {
+	// flushing must be passed to the wrapped writer
+	recorder := httptest.NewRecorder()
+	var w http.ResponseWriter = &responseWriter{ResponseWriter: recorder}
+	flusher, ok := w.(http.Flusher)
+	assert.True(t, ok)
+	flusher.Flush()
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, http.StatusOK, w.(*responseWriter).status)
+
+	// hijacking must fail if the wrapped writer doesn't support it
+	hijacker, ok := w.(http.Hijacker)
+	assert.True(t, ok)
+	_, _, err := hijacker.Hijack()
+	assert.ErrorIs(t, err, http.ErrNotSupported)
+
+	// hijacked connections must not be tracked
+	w = &responseWriter{ResponseWriter: &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}}
+	w.Header().Set("Content-Type", "text/html")
+	conn, _, err := w.(http.Hijacker).Hijack()
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Close())
+	assert.False(t, w.(*responseWriter).isHTML())
+}
+
+type blockingStep struct {
+	block chan struct{}
+}
+
+func (step *blockingStep) Step(*ingest.Request) (bool, error) {
+	<-step.block
+	return false, nil
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	_ = client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
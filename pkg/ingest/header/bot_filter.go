@@ -1,6 +1,7 @@
 package header
 
 import (
+	"net/http"
 	"strings"
 
 	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
@@ -20,85 +21,104 @@ func (b *BotFilter) Step(request *ingest.Request) (bool, error) {
 		return false, nil
 	}
 
-	// ignore User-Agent missing
-	if request.Request.UserAgent() == "" {
-		request.BotReason = "ua-missing"
+	if reasons := b.botReasons(request, false); len(reasons) > 0 {
+		request.BotReason = reasons[0]
 		return true, nil
 	}
 
-	// ignore Accept-Language missing
-	if request.Request.Header.Get("Accept-Language") == "" {
-		request.BotReason = "al-missing"
-		return true, nil
+	return false, nil
+}
+
+// BotReasons implements the ingest.BotDetector interface.
+func (b *BotFilter) BotReasons(request *ingest.Request) []string {
+	return b.botReasons(request, true)
+}
+
+func (b *BotFilter) botReasons(request *ingest.Request, all bool) []string {
+	reasons := make([]string, 0)
+
+	for _, check := range botChecks {
+		if check.match(request.Request) {
+			reasons = append(reasons, check.reason)
+
+			if !all {
+				break
+			}
+		}
 	}
 
+	return reasons
+}
+
+type botCheck struct {
+	reason string
+	match  func(*http.Request) bool
+}
+
+// botChecks are the heuristics in the order they are checked.
+var botChecks = []botCheck{
+	// ignore User-Agent missing
+	{"ua-missing", func(r *http.Request) bool {
+		return r.UserAgent() == ""
+	}},
+
+	// ignore Accept-Language missing
+	{"al-missing", func(r *http.Request) bool {
+		return r.Header.Get("Accept-Language") == ""
+	}},
+
 	// ignore Accept-Encoding missing
-	if request.Request.Header.Get("Accept-Encoding") == "" {
-		request.BotReason = "ae-missing"
-		return true, nil
-	}
+	{"ae-missing", func(r *http.Request) bool {
+		return r.Header.Get("Accept-Encoding") == ""
+	}},
 
 	// ignore HTTP/2 requests with connection header set to close
-	connection := strings.ToLower(strings.TrimSpace(request.Request.Header.Get("Connection")))
-
-	if request.Request.ProtoMajor == 2 && connection == "close" {
-		request.BotReason = "http2-close"
-		return true, nil
-	}
+	{"http2-close", func(r *http.Request) bool {
+		return r.ProtoMajor == 2 && normalizedHeader(r, "Connection") == "close"
+	}},
 
 	// ignore HTTP/2 requests with connection header set to keep-alive
-	if request.Request.ProtoMajor == 2 && connection == "keep-alive" {
-		request.BotReason = "http2-alive"
-		return true, nil
-	}
+	{"http2-alive", func(r *http.Request) bool {
+		return r.ProtoMajor == 2 && normalizedHeader(r, "Connection") == "keep-alive"
+	}},
 
 	// ignore TE header set
-	if request.Request.Header.Get("TE") != "" {
-		request.BotReason = "te"
-		return true, nil
-	}
+	{"te", func(r *http.Request) bool {
+		return r.Header.Get("TE") != ""
+	}},
 
 	// ignore Pragma set without Cache-Control
-	pragma := request.Request.Header.Get("Pragma")
-	cacheControl := request.Request.Header.Get("Cache-Control")
-
-	if pragma != "" && cacheControl == "" {
-		request.BotReason = "pragma-cc"
-		return true, nil
-	}
+	{"pragma-cc", func(r *http.Request) bool {
+		return r.Header.Get("Pragma") != "" && r.Header.Get("Cache-Control") == ""
+	}},
 
 	// ignore Sec-Fetch-Site: none with referrer set
-	secFetchSite := strings.ToLower(strings.TrimSpace(request.Request.Header.Get("Sec-Fetch-Site")))
-
-	if secFetchSite == "none" && request.Request.Referer() != "" {
-		request.BotReason = "sfs-referrer"
-		return true, nil
-	}
+	{"sfs-referrer", func(r *http.Request) bool {
+		return normalizedHeader(r, "Sec-Fetch-Site") == "none" && r.Referer() != ""
+	}},
 
 	// ignore Upgrade-Insecure-Requests for CORS requests
-	upgradeInsecureRequests := strings.TrimSpace(request.Request.Header.Get("Upgrade-Insecure-Requests"))
-	secFetchMode := strings.ToLower(strings.TrimSpace(request.Request.Header.Get("Sec-Fetch-Mode")))
-
-	if upgradeInsecureRequests == "1" && secFetchMode == "cors" {
-		request.BotReason = "ui-cors"
-		return true, nil
-	}
+	{"ui-cors", func(r *http.Request) bool {
+		return strings.TrimSpace(r.Header.Get("Upgrade-Insecure-Requests")) == "1" &&
+			normalizedHeader(r, "Sec-Fetch-Mode") == "cors"
+	}},
 
 	// ignore Sec-Fetch-Dest: empty in combination with Upgrade-Insecure-Requests
-	secFetchDest := request.Request.Header.Get("Sec-Fetch-Dest")
-
-	if secFetchDest == "empty" && upgradeInsecureRequests == "1" {
-		request.BotReason = "sfd-ui"
-		return true, nil
-	}
+	{"sfd-ui", func(r *http.Request) bool {
+		return r.Header.Get("Sec-Fetch-Dest") == "empty" &&
+			strings.TrimSpace(r.Header.Get("Upgrade-Insecure-Requests")) == "1"
+	}},
 
 	// ignore modern header with HTTP/1.1
-	if request.Request.ProtoMajor == 1 &&
-		request.Request.ProtoMinor == 1 &&
-		(secFetchSite != "" || secFetchMode != "" || secFetchDest != "") {
-		request.BotReason = "http11-sf"
-		return true, nil
-	}
+	{"http11-sf", func(r *http.Request) bool {
+		return r.ProtoMajor == 1 &&
+			r.ProtoMinor == 1 &&
+			(normalizedHeader(r, "Sec-Fetch-Site") != "" ||
+				normalizedHeader(r, "Sec-Fetch-Mode") != "" ||
+				r.Header.Get("Sec-Fetch-Dest") != "")
+	}},
+}
 
-	return false, nil
+func normalizedHeader(r *http.Request, header string) string {
+	return strings.ToLower(strings.TrimSpace(r.Header.Get(header)))
 }
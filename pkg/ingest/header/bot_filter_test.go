@@ -86,3 +86,20 @@ func TestBotFilter(t *testing.T) {
 		assert.Equal(t, results[i], r.BotReason)
 	}
 }
+
+func TestBotFilterBotReasons(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/146.0.0.0 Safari/537.36")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("Sec-Fetch-Site", "none")
+	r := &ingest.Request{
+		Request: req,
+	}
+	assert.Equal(t, []string{"ae-missing", "te", "http11-sf"}, NewBotFilter().BotReasons(r))
+	assert.Empty(t, r.BotReason)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Del("TE")
+	req.Header.Del("Sec-Fetch-Site")
+	assert.Empty(t, NewBotFilter().BotReasons(r))
+}
@@ -0,0 +1,326 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/util"
+)
+
+const (
+	defaultMaxLineSize = 1024 * 1024 // 1 MB
+)
+
+// Options is the configuration for an Importer.
+type Options struct {
+	// Pipe is the Pipe the log lines are passed to (mandatory).
+	Pipe *ingest.Pipe
+
+	// SiteID is the site ID for all imported requests.
+	SiteID uint64
+
+	// Hostname is the hostname used if the log does not contain the Host header.
+	Hostname string
+
+	// Scheme is the URL scheme used to reconstruct the request URL.
+	// Defaults to https.
+	Scheme string
+
+	// Format is the log format.
+	Format Format
+
+	// JSONFields maps the fields for FormatJSON.
+	// If not set, DefaultJSONFields will be used.
+	JSONFields *JSONFields
+
+	// StaticExtensions is the list of file extensions (including the dot) that won't be imported.
+	// If nil, util.StaticExtensions will be used.
+	StaticExtensions []string
+
+	// ReorderWindow buffers lines for the given duration to process them in chronological order.
+	// Log files written by multiple workers are usually only roughly in order.
+	// Lines arriving later than the window are processed immediately.
+	ReorderWindow time.Duration
+
+	// MaxLineSize is the maximum size of a single line in bytes.
+	// If set to <= 0, the default value of 1 MB will be used.
+	MaxLineSize int
+
+	// Logger is the logger for the Importer.
+	// If not set, the default slog.Logger will be used.
+	Logger *slog.Logger
+}
+
+func (options *Options) validate() {
+	if options.Scheme == "" {
+		options.Scheme = "https"
+	}
+
+	if options.JSONFields == nil {
+		options.JSONFields = &DefaultJSONFields
+	}
+
+	if options.StaticExtensions == nil {
+		options.StaticExtensions = util.StaticExtensions
+	}
+
+	if options.ReorderWindow < 0 {
+		options.ReorderWindow = 0
+	}
+
+	if options.MaxLineSize <= 0 {
+		options.MaxLineSize = defaultMaxLineSize
+	}
+
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
+}
+
+// Result is the summary of an import.
+type Result struct {
+	// Lines is the total number of lines read.
+	Lines uint64
+
+	// Imported is the number of lines passed to the Pipe and not marked as bot.
+	Imported uint64
+
+	// Skipped is the number of lines that could not be parsed.
+	Skipped uint64
+
+	// Dropped is the number of lines dropped, because the Pipe was overloaded.
+	Dropped uint64
+
+	// Filtered is the number of lines ignored, because they are not page views (static files, non-GET requests, errors, ...).
+	Filtered uint64
+
+	// Bots is the number of lines marked as bot by the Pipe.
+	Bots uint64
+
+	// BotReasons is the number of bot lines by ingest.Request BotReason.
+	BotReasons map[string]uint64
+}
+
+// Importer imports access logs into a Pipe.
+// Lines are processed in order (within the ReorderWindow), so that sessions are reconstructed chronologically.
+// Note that access logs usually don't contain most headers, so header-based bot filters should not be used in the Pipe.
+type Importer struct {
+	pipe             *ingest.Pipe
+	siteID           uint64
+	hostname         string
+	scheme           string
+	parser           *Parser
+	staticExtensions []string
+	reorderWindow    time.Duration
+	maxLineSize      int
+	logger           *slog.Logger
+}
+
+// NewImporter creates a new Importer for given Options.
+func NewImporter(options Options) *Importer {
+	options.validate()
+	return &Importer{
+		pipe:             options.Pipe,
+		siteID:           options.SiteID,
+		hostname:         options.Hostname,
+		scheme:           options.Scheme,
+		parser:           NewParser(options.Format, *options.JSONFields),
+		staticExtensions: options.StaticExtensions,
+		reorderWindow:    options.ReorderWindow,
+		maxLineSize:      options.MaxLineSize,
+		logger:           options.Logger,
+	}
+}
+
+// Import reads the log from given reader line by line and passes page views to the Pipe.
+// Compressed logs must be decompressed by the caller (using gzip.NewReader for example).
+// It returns the Result so far on error.
+func (importer *Importer) Import(ctx context.Context, r io.Reader) (Result, error) {
+	result := Result{
+		BotReasons: make(map[string]uint64),
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), importer.maxLineSize)
+	buffer := make(entryHeap, 0)
+	var seq uint64
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		result.Lines++
+		line := scanner.Bytes()
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			result.Skipped++
+			continue
+		}
+
+		entry, err := importer.parser.Parse(line)
+
+		if err != nil {
+			result.Skipped++
+			importer.logger.Debug("Skipping invalid line", "line", result.Lines, "err", err)
+			continue
+		}
+
+		if !importer.isPageView(entry) {
+			result.Filtered++
+			continue
+		}
+
+		heap.Push(&buffer, bufferedEntry{entry, seq})
+		seq++
+		release := entry.Time.Add(-importer.reorderWindow)
+
+		for len(buffer) > 0 && !buffer[0].entry.Time.After(release) {
+			if err := importer.process(heap.Pop(&buffer).(bufferedEntry).entry, &result); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+
+	for len(buffer) > 0 {
+		if err := importer.process(heap.Pop(&buffer).(bufferedEntry).entry, &result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (importer *Importer) process(entry *Entry, result *Result) error {
+	request := importer.newRequest(entry)
+
+	if request == nil {
+		result.Skipped++
+		return nil
+	}
+
+	if err := importer.pipe.Process(request); err != nil {
+		if errors.Is(err, ingest.ErrPipeOverloaded) {
+			result.Dropped++
+			return nil
+		}
+
+		return err
+	}
+
+	if request.IsBot || request.BotReason != "" {
+		result.Bots++
+		result.BotReasons[request.BotReason]++
+	} else {
+		result.Imported++
+	}
+
+	return nil
+}
+
+func (importer *Importer) isPageView(entry *Entry) bool {
+	if entry.Method != http.MethodGet {
+		return false
+	}
+
+	if (entry.Status < 200 || entry.Status > 299) && entry.Status != http.StatusNotModified {
+		return false
+	}
+
+	p, _, _ := strings.Cut(entry.Path, "?")
+	return !slices.Contains(importer.staticExtensions, strings.ToLower(path.Ext(p)))
+}
+
+func (importer *Importer) newRequest(entry *Entry) *ingest.Request {
+	host := entry.Host
+
+	if host == "" {
+		host = importer.hostname
+	}
+
+	u, err := url.Parse(importer.scheme + "://" + host + entry.Path)
+
+	if err != nil {
+		return nil
+	}
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Host:       u.Host,
+	}
+
+	// the remote address is expected to include the port
+	if entry.IP != "" {
+		req.RemoteAddr = net.JoinHostPort(entry.IP, "0")
+	}
+
+	if entry.UserAgent != "" {
+		req.Header.Set("User-Agent", entry.UserAgent)
+	}
+
+	if entry.Referrer != "" {
+		req.Header.Set("Referer", entry.Referrer)
+	}
+
+	if entry.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", entry.AcceptLanguage)
+	}
+
+	return &ingest.Request{
+		SiteID:  importer.siteID,
+		Request: req,
+		Time:    entry.Time,
+	}
+}
+
+type bufferedEntry struct {
+	entry *Entry
+	seq   uint64
+}
+
+// entryHeap is a min heap ordering entries by time and line number.
+type entryHeap []bufferedEntry
+
+func (h entryHeap) Len() int { return len(h) }
+
+func (h entryHeap) Less(i, j int) bool {
+	if h[i].entry.Time.Equal(h[j].entry.Time) {
+		return h[i].seq < h[j].seq
+	}
+
+	return h[i].entry.Time.Before(h[j].entry.Time)
+}
+
+func (h entryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *entryHeap) Push(x any) { *h = append(*h, x.(bufferedEntry)) }
+
+func (h *entryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/ip"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/session"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/ua"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/146.0.0.0 Safari/537.36"
+)
+
+func TestImporter(t *testing.T) {
+	storage := db.NewMock()
+	pipe := ingest.NewPipe(ingest.PipeOptions{
+		Storage: storage,
+		Worker:  1,
+	}).Use(ip.NewIP(nil, nil),
+		ua.NewUserAgent(),
+		ua.NewBotFilter(),
+		session.NewSession(1, 2, "salt", session.NewMemCache(storage, 100), 0, nil))
+	importer := NewImporter(Options{
+		Pipe:          pipe,
+		SiteID:        42,
+		Hostname:      "example.com",
+		ReorderWindow: time.Minute,
+	})
+
+	// the third line is logged before the second one
+	log := strings.Join([]string{
+		`81.2.69.142 - - [10/Oct/2025:13:55:00 +0000] "GET / HTTP/1.1" 200 2326 "https://google.com/" "` + testUserAgent + `" "en-US"`,
+		`81.2.69.142 - - [10/Oct/2025:13:55:10 +0000] "GET /style.css HTTP/1.1" 200 2326 "https://example.com/" "` + testUserAgent + `"`,
+		`81.2.69.142 - - [10/Oct/2025:13:55:40 +0000] "GET /about HTTP/1.1" 200 2326 "https://example.com/" "` + testUserAgent + `"`,
+		`81.2.69.142 - - [10/Oct/2025:13:55:20 +0000] "GET /blog HTTP/1.1" 200 2326 "https://example.com/" "` + testUserAgent + `"`,
+		`81.2.69.142 - - [10/Oct/2025:13:55:30 +0000] "POST /contact HTTP/1.1" 200 2326 "https://example.com/" "` + testUserAgent + `"`,
+		`81.2.69.142 - - [10/Oct/2025:13:55:30 +0000] "GET /missing HTTP/1.1" 404 0 "https://example.com/" "` + testUserAgent + `"`,
+		``,
+		`66.249.66.1 - - [10/Oct/2025:13:55:50 +0000] "GET / HTTP/1.1" 200 2326 "-" "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"`,
+		`invalid`,
+	}, "\n")
+	result, err := importer.Import(context.Background(), strings.NewReader(log))
+	assert.NoError(t, err)
+	pipe.Stop()
+	assert.Equal(t, uint64(9), result.Lines)
+	assert.Equal(t, uint64(3), result.Imported)
+	assert.Equal(t, uint64(2), result.Skipped)
+	assert.Equal(t, uint64(3), result.Filtered)
+	assert.Equal(t, uint64(1), result.Bots)
+	assert.Len(t, result.BotReasons, 1)
+
+	// the page views must have been processed in chronological order within the same session
+	pageViews := storage.PageViews()
+	assert.Len(t, pageViews, 3)
+	assert.Equal(t, "/", pageViews[0].Path)
+	assert.Equal(t, "/blog", pageViews[1].Path)
+	assert.Equal(t, "/about", pageViews[2].Path)
+	assert.Equal(t, uint64(42), pageViews[0].SiteID)
+	assert.Equal(t, "example.com", pageViews[0].Hostname)
+	assert.Equal(t, time.Date(2025, 10, 10, 13, 55, 0, 0, time.UTC), pageViews[0].Time)
+	assert.Equal(t, pageViews[0].SessionID, pageViews[2].SessionID)
+	assert.Equal(t, pageViews[0].VisitorID, pageViews[2].VisitorID)
+	assert.Equal(t, uint32(20), pageViews[2].DurationSeconds)
+	sessions := storage.Sessions()
+	assert.Len(t, sessions, 5)
+	assert.Equal(t, uint16(3), sessions[4].PageViews)
+	assert.Equal(t, uint32(40), sessions[4].DurationSeconds)
+	assert.Equal(t, "/", sessions[4].EntryPath)
+	assert.Equal(t, "/about", sessions[4].ExitPath)
+	requests := storage.Requests()
+	assert.Len(t, requests, 4)
+	assert.Equal(t, "en-US", requests[0].Headers["Accept-Language"])
+}
+
+func TestImporterCanceled(t *testing.T) {
+	pipe := ingest.NewPipe(ingest.PipeOptions{
+		Storage: db.NewMock(),
+	})
+	defer pipe.Stop()
+	importer := NewImporter(Options{
+		Pipe:     pipe,
+		Hostname: "example.com",
+		Format:   FormatJSON,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := importer.Import(ctx, strings.NewReader(`{"time": "2025-10-10T13:55:36Z", "request": "GET / HTTP/1.1", "status": 200}`))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, result.Lines)
+}
+
+func TestImporterRemoteAddr(t *testing.T) {
+	importer := NewImporter(Options{Hostname: "example.com"})
+	request := importer.newRequest(&Entry{IP: "81.2.69.142", Path: "/"})
+	assert.Equal(t, "81.2.69.142:0", request.Request.RemoteAddr)
+	request = importer.newRequest(&Entry{IP: "2001:db8::1", Path: "/"})
+	assert.Equal(t, "[2001:db8::1]:0", request.Request.RemoteAddr)
+	request = importer.newRequest(&Entry{Path: "/"})
+	assert.Empty(t, request.Request.RemoteAddr)
+}
@@ -0,0 +1,278 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// FormatCombined is the NCSA combined log format used by nginx and Apache.
+	// An optional quoted field following the User-Agent is used as the Accept-Language header.
+	FormatCombined = Format(iota)
+
+	// FormatCommon is the NCSA common log format.
+	FormatCommon
+
+	// FormatJSON is a JSON object per line. The fields are configured using JSONFields.
+	FormatJSON
+
+	clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+var (
+	// ErrLineInvalid is returned if a line cannot be parsed.
+	ErrLineInvalid = errors.New("line invalid")
+
+	// ErrUnsupportedFormat is returned for an unknown Format.
+	ErrUnsupportedFormat = errors.New("unsupported format")
+
+	clfRegex = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^]]+)] "((?:[^"\\]|\\.)*)" (\d{3}) \S+(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"(?: "((?:[^"\\]|\\.)*)")?)?`)
+
+	// DefaultJSONFields are the JSON fields for the nginx variable names.
+	DefaultJSONFields = JSONFields{
+		Time:           "time",
+		IP:             "remote_addr",
+		Request:        "request",
+		Method:         "request_method",
+		Path:           "request_uri",
+		Status:         "status",
+		Referrer:       "http_referer",
+		UserAgent:      "http_user_agent",
+		AcceptLanguage: "http_accept_language",
+		Host:           "host",
+	}
+)
+
+// Format is the access log format.
+type Format int
+
+// JSONFields maps the fields of a JSON access log.
+// Empty fields are ignored.
+type JSONFields struct {
+	// Time is the time of the request as RFC 3339, common log format, or unix timestamp in seconds.
+	Time string
+
+	// IP is the client IP address.
+	IP string
+
+	// Request is the request line (like "GET /path HTTP/1.1").
+	// It's used if Method or Path are not set in the log line.
+	Request string
+
+	// Method is the request method.
+	Method string
+
+	// Path is the request URI including the query.
+	Path string
+
+	// Status is the response status code.
+	Status string
+
+	// Referrer is the Referer header.
+	Referrer string
+
+	// UserAgent is the User-Agent header.
+	UserAgent string
+
+	// AcceptLanguage is the Accept-Language header.
+	AcceptLanguage string
+
+	// Host is the Host header.
+	Host string
+}
+
+// Entry is a parsed access log line.
+type Entry struct {
+	Time           time.Time
+	IP             string
+	Method         string
+	Path           string
+	Status         int
+	Referrer       string
+	UserAgent      string
+	AcceptLanguage string
+	Host           string
+}
+
+// Parser parses access log lines.
+type Parser struct {
+	format Format
+	fields JSONFields
+}
+
+// NewParser returns a new Parser for given Format and JSONFields.
+// The fields are only used for FormatJSON.
+func NewParser(format Format, fields JSONFields) *Parser {
+	return &Parser{
+		format: format,
+		fields: fields,
+	}
+}
+
+// Parse parses a single line.
+func (parser *Parser) Parse(line []byte) (*Entry, error) {
+	switch parser.format {
+	case FormatCombined, FormatCommon:
+		return parser.parseCLF(line)
+	case FormatJSON:
+		return parser.parseJSON(line)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func (parser *Parser) parseCLF(line []byte) (*Entry, error) {
+	match := clfRegex.FindSubmatch(line)
+
+	if match == nil {
+		return nil, ErrLineInvalid
+	}
+
+	t, err := time.Parse(clfTimeLayout, string(match[2]))
+
+	if err != nil {
+		return nil, ErrLineInvalid
+	}
+
+	method, path, ok := parser.parseRequestLine(unescape(match[3]))
+
+	if !ok {
+		return nil, ErrLineInvalid
+	}
+
+	status, _ := strconv.Atoi(string(match[4]))
+	entry := &Entry{
+		Time:   t.UTC(),
+		IP:     string(match[1]),
+		Method: method,
+		Path:   path,
+		Status: status,
+	}
+
+	if parser.format == FormatCombined {
+		entry.Referrer = parser.emptyIfDash(unescape(match[5]))
+		entry.UserAgent = parser.emptyIfDash(unescape(match[6]))
+		entry.AcceptLanguage = parser.emptyIfDash(unescape(match[7]))
+	}
+
+	return entry, nil
+}
+
+func (parser *Parser) parseJSON(line []byte) (*Entry, error) {
+	var fields map[string]any
+
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, ErrLineInvalid
+	}
+
+	t, ok := parser.parseTime(fields[parser.fields.Time])
+
+	if !ok {
+		return nil, ErrLineInvalid
+	}
+
+	entry := &Entry{
+		Time:           t,
+		IP:             parser.string(fields, parser.fields.IP),
+		Method:         parser.string(fields, parser.fields.Method),
+		Path:           parser.string(fields, parser.fields.Path),
+		Referrer:       parser.emptyIfDash(parser.string(fields, parser.fields.Referrer)),
+		UserAgent:      parser.emptyIfDash(parser.string(fields, parser.fields.UserAgent)),
+		AcceptLanguage: parser.emptyIfDash(parser.string(fields, parser.fields.AcceptLanguage)),
+		Host:           parser.emptyIfDash(parser.string(fields, parser.fields.Host)),
+	}
+
+	if entry.Method == "" || entry.Path == "" {
+		method, path, ok := parser.parseRequestLine(parser.string(fields, parser.fields.Request))
+
+		if !ok {
+			return nil, ErrLineInvalid
+		}
+
+		entry.Method, entry.Path = method, path
+	}
+
+	entry.Status, _ = strconv.Atoi(parser.string(fields, parser.fields.Status))
+	return entry, nil
+}
+
+func (parser *Parser) parseRequestLine(line string) (string, string, bool) {
+	parts := strings.Fields(line)
+
+	if len(parts) < 2 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+func (parser *Parser) parseTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, clfTimeLayout} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), true
+			}
+		}
+
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return parser.unixTime(f), true
+		}
+	case float64:
+		return parser.unixTime(v), true
+	}
+
+	return time.Time{}, false
+}
+
+func (parser *Parser) unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second))).UTC()
+}
+
+func (parser *Parser) string(fields map[string]any, key string) string {
+	if key == "" {
+		return ""
+	}
+
+	switch v := fields[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (parser *Parser) emptyIfDash(value string) string {
+	if value == "-" {
+		return ""
+	}
+
+	return value
+}
+
+func unescape(value []byte) string {
+	if !strings.Contains(string(value), `\`) {
+		return string(value)
+	}
+
+	var sb strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+
+		sb.WriteByte(value[i])
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParserCombined(t *testing.T) {
+	parser := NewParser(FormatCombined, DefaultJSONFields)
+	entry, err := parser.Parse([]byte(`81.2.69.142 - - [10/Oct/2025:13:55:36 +0200] "GET /blog/post?foo=bar HTTP/1.1" 200 2326 "https://google.com/" "Mozilla/5.0 (Windows NT 10.0; \"Win64\"; x64)"`))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 10, 11, 55, 36, 0, time.UTC), entry.Time)
+	assert.Equal(t, "81.2.69.142", entry.IP)
+	assert.Equal(t, "GET", entry.Method)
+	assert.Equal(t, "/blog/post?foo=bar", entry.Path)
+	assert.Equal(t, 200, entry.Status)
+	assert.Equal(t, "https://google.com/", entry.Referrer)
+	assert.Equal(t, `Mozilla/5.0 (Windows NT 10.0; "Win64"; x64)`, entry.UserAgent)
+	assert.Empty(t, entry.AcceptLanguage)
+
+	// with Accept-Language
+	entry, err = parser.Parse([]byte(`::1 - user [10/Oct/2025:13:55:36 +0000] "GET / HTTP/2.0" 304 - "-" "Mozilla/5.0" "de-DE,de;q=0.9"`))
+	assert.NoError(t, err)
+	assert.Equal(t, "::1", entry.IP)
+	assert.Equal(t, 304, entry.Status)
+	assert.Empty(t, entry.Referrer)
+	assert.Equal(t, "de-DE,de;q=0.9", entry.AcceptLanguage)
+
+	// invalid lines
+	for _, line := range []string{
+		"",
+		"foo bar",
+		`81.2.69.142 - - [10/Oct/2025] "GET / HTTP/1.1" 200 2326 "-" "-"`,
+		`81.2.69.142 - - [10/Oct/2025:13:55:36 +0200] "-" 400 0 "-" "-"`,
+	} {
+		_, err = parser.Parse([]byte(line))
+		assert.ErrorIs(t, err, ErrLineInvalid, line)
+	}
+}
+
+func TestParserCommon(t *testing.T) {
+	parser := NewParser(FormatCommon, DefaultJSONFields)
+	entry, err := parser.Parse([]byte(`81.2.69.142 - - [10/Oct/2025:13:55:36 +0200] "GET /foo HTTP/1.1" 200 2326`))
+	assert.NoError(t, err)
+	assert.Equal(t, "/foo", entry.Path)
+	assert.Empty(t, entry.UserAgent)
+}
+
+func TestParserJSON(t *testing.T) {
+	parser := NewParser(FormatJSON, DefaultJSONFields)
+	entry, err := parser.Parse([]byte(`{"time": "2025-10-10T13:55:36+02:00", "remote_addr": "81.2.69.142", "request": "GET /foo?bar=baz HTTP/1.1", "status": "200", "http_referer": "-", "http_user_agent": "Mozilla/5.0", "http_accept_language": "en", "host": "example.com"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 10, 11, 55, 36, 0, time.UTC), entry.Time)
+	assert.Equal(t, "81.2.69.142", entry.IP)
+	assert.Equal(t, "GET", entry.Method)
+	assert.Equal(t, "/foo?bar=baz", entry.Path)
+	assert.Equal(t, 200, entry.Status)
+	assert.Empty(t, entry.Referrer)
+	assert.Equal(t, "Mozilla/5.0", entry.UserAgent)
+	assert.Equal(t, "en", entry.AcceptLanguage)
+	assert.Equal(t, "example.com", entry.Host)
+
+	// custom fields, unix timestamp, and numeric status
+	parser = NewParser(FormatJSON, JSONFields{
+		Time:   "ts",
+		Method: "method",
+		Path:   "uri",
+		Status: "code",
+	})
+	entry, err = parser.Parse([]byte(`{"ts": 1760097336.5, "method": "GET", "uri": "/", "code": 200}`))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 10, 11, 55, 36, 500_000_000, time.UTC), entry.Time)
+	assert.Equal(t, "/", entry.Path)
+	assert.Equal(t, 200, entry.Status)
+
+	// invalid lines
+	for _, line := range []string{
+		"{",
+		`{"time": "yesterday", "request": "GET / HTTP/1.1"}`,
+		`{"time": "2025-10-10T13:55:36Z"}`,
+	} {
+		_, err = NewParser(FormatJSON, DefaultJSONFields).Parse([]byte(line))
+		assert.ErrorIs(t, err, ErrLineInvalid, line)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand/v2"
 	"slices"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"testing/synctest"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/pirsch-analytics/pirsch/v7/pkg"
 	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/geo"
 	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -343,6 +345,32 @@ func TestIntegrationRequests(t *testing.T) {
 	})
 }
 
+func TestIntegrationGeoCIDR(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		// set up a pipeline using a static CIDR list instead of the GeoIP2 database
+		ranges, err := geo.LoadCIDR(strings.NewReader("81.2.69.0/24,de,Bavaria,Munich"))
+		assert.NoError(t, err)
+		p, s, _ := newPipeline(t, pipelineOptions{
+			geo: ranges,
+		})
+		assert.NoError(t, p.Process(&ingest.Request{
+			SiteID:  1,
+			Request: newRequest(requestOptions{}),
+		}))
+		p.Stop()
+		synctest.Wait()
+		sessions := s.Sessions()
+		pageViews := s.PageViews()
+		assert.Len(t, sessions, 1)
+		assert.Len(t, pageViews, 1)
+		assert.Equal(t, "de", sessions[0].CountryCode)
+		assert.Equal(t, "Bavaria", sessions[0].Region)
+		assert.Equal(t, "Munich", sessions[0].City)
+		assert.Equal(t, "de", pageViews[0].CountryCode)
+		assert.Equal(t, "Munich", pageViews[0].City)
+	})
+}
+
 func TestIntegrationConcurrency(t *testing.T) {
 	synctest.Test(t, func(t *testing.T) {
 		// set up a simple pipeline
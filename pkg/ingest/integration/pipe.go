@@ -20,15 +20,25 @@ import (
 
 type pipelineOptions struct {
 	worker int
+	geo    geo.Provider
 }
 
 func newPipeline(t *testing.T, options pipelineOptions) (*ingest.Pipe, *db.Mock, *session.MemCache) {
 	s := db.NewMock()
 	c := session.NewMemCache(s, 1000)
+	t.Cleanup(c.Close)
 	ipFilter := ip.NewList()
 	ipFilter.Update([]string{"89.123.21.128"}, nil, nil, nil, nil, nil)
-	geoDB, _ := geo.NewGeo("", "", "")
-	assert.NoError(t, geoDB.UpdateFromFile("../../../test/GeoIP2-City-Test.mmdb"))
+	var geoStep ingest.PipeStep
+
+	if options.geo != nil {
+		geoStep = geo.NewLocator(options.geo)
+	} else {
+		geoDB, _ := geo.NewGeo("", "", "")
+		assert.NoError(t, geoDB.UpdateFromFile("../../../test/GeoIP2-City-Test.mmdb"))
+		geoStep = geoDB
+	}
+
 	return ingest.NewPipe(ingest.PipeOptions{
 		Storage: s,
 		Worker:  options.worker,
@@ -39,10 +49,10 @@ func newPipeline(t *testing.T, options pipelineOptions) (*ingest.Pipe, *db.Mock,
 		referrer.NewReferrer(referrer.Groups),
 		ua.NewUserAgent(),
 		ua.NewBotFilter(),
-		geoDB,
+		geoStep,
 		channel.NewChannel(channel.List),
 		language.NewLanguage(),
 		screen.NewScreen(screen.Classes),
 		utm.NewUTM(),
-		session.NewSession(1, 2, "salt", c, 200)), s, c
+		session.NewSession(1, 2, "salt", c, 200, nil)), s, c
 }
@@ -27,12 +27,21 @@ func (f *BotFilter) Step(request *ingest.Request) (bool, error) {
 		return false, nil
 	}
 
+	if reasons := f.BotReasons(request); len(reasons) > 0 {
+		request.BotReason = reasons[0]
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// BotReasons implements the ingest.BotDetector interface.
+func (f *BotFilter) BotReasons(request *ingest.Request) []string {
 	for _, filter := range f.filter {
 		if filter.Ignore(request.IP) {
-			request.BotReason = "ip"
-			return true, nil
+			return []string{"ip"}
 		}
 	}
 
-	return false, nil
+	return nil
 }
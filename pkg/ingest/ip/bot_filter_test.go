@@ -18,6 +18,7 @@ func TestBotFilter(t *testing.T) {
 	req := &ingest.Request{Request: r, IP: "123.128.0.12"}
 
 	// ignore request
+	assert.Equal(t, []string{"ip"}, f.BotReasons(req))
 	cancel, err := f.Step(req)
 	assert.True(t, cancel)
 	assert.NoError(t, err)
@@ -40,6 +41,7 @@ func TestBotFilter(t *testing.T) {
 	// do not ignore request
 	r, _ = http.NewRequest("GET", "/", nil)
 	req = &ingest.Request{Request: r, IP: "123.128.0.13"}
+	assert.Empty(t, f.BotReasons(req))
 	cancel, err = f.Step(req)
 	assert.False(t, cancel)
 	assert.NoError(t, err)
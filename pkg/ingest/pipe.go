@@ -2,10 +2,12 @@ package ingest
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand/v2"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 
@@ -13,314 +15,633 @@ import (
 	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
 )
 
+var (
+	// ErrPipeOverloaded is returned by Pipe.Process if the request has been dropped because the Pipe is overloaded.
+	ErrPipeOverloaded = errors.New("pipe overloaded")
+)
+
 // Pipe ingests requests into the system.
 // Requests are processed through configurable steps before they are stored.
 type Pipe struct {
-	ctx      context.Context
-	cancel   context.CancelFunc
-	wg       sync.WaitGroup
-	steps    []PipeStep
-	requests chan *Request
-	storage  db.Storage
-	logIP    bool
-	logger   *slog.Logger
+	ctx             context.Context
+	cancel          context.CancelFunc
+	accept          context.Context
+	stopAccepting   context.CancelFunc
+	acceptLock      sync.RWMutex
+	wg              sync.WaitGroup
+	retries         sync.WaitGroup
+	steps           []PipeStep
+	siteSteps       map[uint64][]PipeStep
+	stepsLock       sync.RWMutex
+	requests        chan *Request
+	slots           chan struct{}
+	queueCapacity   int
+	storage         db.Storage
+	spool           *Spool
+	replay          chan struct{}
+	overloadPolicy  OverloadPolicy
+	overloadTimeout time.Duration
+	spillBuffer     spoolBatchData
+	spillCount      int
+	spillChunkSize  int
+	spillTimeout    time.Duration
+	spillTimer      *time.Timer
+	spillLock       sync.Mutex
+	stats           *pipeStats
+	logIP           bool
+	logger          *slog.Logger
 }
 
 // NewPipe creates a new Pipe for the given PipeOptions.
 func NewPipe(options PipeOptions) *Pipe {
 	options.validate()
 	ctx, cancel := context.WithCancel(context.Background())
+	accept, stopAccepting := context.WithCancel(context.Background())
+
+	// every worker can hold one request while it's busy, so the channel must be able to buffer one more per worker
+	slots := options.RequestChannelBufferSize + options.Worker
 	p := &Pipe{
-		ctx:      ctx,
-		cancel:   cancel,
-		steps:    make([]PipeStep, 0),
-		requests: make(chan *Request, options.RequestChannelBufferSize),
-		storage:  options.Storage,
-		logIP:    options.LogIP,
-		logger:   options.Logger,
+		ctx:             ctx,
+		cancel:          cancel,
+		accept:          accept,
+		stopAccepting:   stopAccepting,
+		steps:           make([]PipeStep, 0),
+		siteSteps:       make(map[uint64][]PipeStep),
+		requests:        make(chan *Request, slots),
+		slots:           make(chan struct{}, slots),
+		queueCapacity:   options.RequestChannelBufferSize,
+		storage:         options.Storage,
+		replay:          make(chan struct{}, 1),
+		overloadPolicy:  options.OverloadPolicy,
+		overloadTimeout: options.OverloadTimeout,
+		spillChunkSize:  options.SpillChunkSize,
+		spillTimeout:    options.WorkerTimeout,
+		stats:           newPipeStats(options.Worker, options.WorkerBufferSize),
+		logIP:           options.LogIP,
+		logger:          options.Logger,
 	}
 
-	for range options.Worker {
-		p.wg.Go(p.collect(options.WorkerBufferSize, options.WorkerTimeout))
+	if options.SpoolDir != "" {
+		spool, err := NewSpool(options.SpoolDir, options.SpoolMaxSize)
+
+		if err != nil {
+			p.logger.Error("Error creating spool, failed batches will be dropped", "err", err, "dir", options.SpoolDir)
+		} else {
+			p.spool = spool
+			p.replay <- struct{}{} // replay batches left over from the last run on start
+			p.wg.Go(p.replaySpool)
+		}
+	}
+
+	for i := range options.Worker {
+		p.wg.Go(p.collect(p.stats.workers[i], options.WorkerBufferSize, options.WorkerTimeout))
 	}
 
 	return p
 }
 
-// Use adds a processing step to the Pipe.
+// Use adds a processing step to the default step chain of the Pipe.
+// The default chain is used for all sites without a step chain of their own.
 func (p *Pipe) Use(f ...PipeStep) *Pipe {
-	p.steps = append(p.steps, f...)
+	p.stepsLock.Lock()
+	defer p.stepsLock.Unlock()
+	p.steps = slices.Concat(p.steps, f)
+	return p
+}
+
+// UseSite adds a processing step to the step chain for given site ID.
+// Requests for the site will be processed by this chain instead of the default chain.
+// Calling UseSite without steps registers an empty chain, so that requests for the site won't be processed by any step.
+func (p *Pipe) UseSite(siteID uint64, f ...PipeStep) *Pipe {
+	p.stepsLock.Lock()
+	defer p.stepsLock.Unlock()
+	p.siteSteps[siteID] = slices.Concat(p.siteSteps[siteID], f)
 	return p
 }
 
+// SetSteps replaces the default step chain.
+// It can be called while the Pipe is processing requests.
+func (p *Pipe) SetSteps(f ...PipeStep) {
+	p.stepsLock.Lock()
+	defer p.stepsLock.Unlock()
+	p.steps = slices.Clone(f)
+}
+
+// SetSiteSteps replaces the step chain for given site ID.
+// It can be called while the Pipe is processing requests.
+func (p *Pipe) SetSiteSteps(siteID uint64, f ...PipeStep) {
+	p.stepsLock.Lock()
+	defer p.stepsLock.Unlock()
+	p.siteSteps[siteID] = slices.Clone(f)
+}
+
+// RemoveSiteSteps removes the step chain for given site ID, so that the default chain will be used for the site.
+func (p *Pipe) RemoveSiteSteps(siteID uint64) {
+	p.stepsLock.Lock()
+	defer p.stepsLock.Unlock()
+	delete(p.siteSteps, siteID)
+}
+
 // Process processes the given request.
 // It can be run in its own Goroutine, so that the client won't have to wait for the request to be processed.
 // http.StatusAccepted can be returned in that case for example.
+// If the Pipe is overloaded, the behaviour depends on the configured OverloadPolicy.
+// The decision is made before the request is processed by the steps, so that dropped requests don't update the session.
+// ErrPipeOverloaded is returned if the request has been dropped.
 func (p *Pipe) Process(request *Request) error {
+	// requests accepted before the pipe is stopped must be stored
+	p.acceptLock.RLock()
+	defer p.acceptLock.RUnlock()
+
 	// return if the pipe has been halted
 	select {
-	case <-p.ctx.Done():
-		return p.ctx.Err()
+	case <-p.accept.Done():
+		return p.accept.Err()
 	default:
 	}
 
 	// check if the request should be ignored for any reason
-	if p.ignore(request) {
+	if ignore(request) {
+		p.stats.ignored.Add(1)
 		return nil
 	}
 
+	// reserve a slot in the request channel
+	reserved, err := p.reserve()
+
+	if err != nil {
+		return err
+	}
+
 	// process the request otherwise
 	request.validate()
 
-	for _, step := range p.steps {
+	for _, step := range p.stepsForSite(request.SiteID) {
 		cancel, err := step.Step(request)
 
 		if err != nil {
+			if reserved {
+				<-p.slots
+			}
+
 			return err
 		}
 
 		if cancel {
 			// mark the request as canceled, but keep storing it (for bot analysis)
 			request.cancelled = true
+			p.stats.cancel(step, request.BotReason)
 			break
 		}
 	}
 
 	// schedule request to be stored in batch
+	if !reserved {
+		p.spill(request)
+		return nil
+	}
+
 	p.requests <- request
 	return nil
 }
 
+// Prepare processes the given request through the steps without storing it.
+// It returns true if the request has been ignored (like pre-fetch requests) or has been cancelled by a step.
+// This can be used to process requests outside a Pipe, like for historical imports.
+func Prepare(request *Request, steps ...PipeStep) (bool, error) {
+	if ignore(request) {
+		return true, nil
+	}
+
+	request.validate()
+
+	for _, step := range steps {
+		cancel, err := step.Step(request)
+
+		if err != nil {
+			return false, err
+		}
+
+		if cancel {
+			request.cancelled = true
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 // Stop flushes all data currently within the pipe and stops processing new data.
+// Batches that are still being retried will be persisted to the Spool if configured.
 func (p *Pipe) Stop() {
+	// stop accepting requests and wait for the requests being processed before the workers are stopped
+	p.stopAccepting()
+	p.acceptLock.Lock()
 	p.cancel()
+	p.acceptLock.Unlock()
 	p.wg.Wait()
+	p.retries.Wait()
+	p.flushSpill()
+}
+
+// Spool returns the Spool used to persist failed batches or nil if not configured.
+func (p *Pipe) Spool() *Spool {
+	return p.spool
+}
+
+// Dropped returns the number of requests dropped because the Pipe was overloaded.
+func (p *Pipe) Dropped() uint64 {
+	return p.stats.dropped.Load()
+}
+
+// Spilled returns the number of requests written to the Spool because the Pipe was overloaded.
+func (p *Pipe) Spilled() uint64 {
+	return p.stats.spilled.Load()
+}
+
+func (p *Pipe) stepsForSite(siteID uint64) []PipeStep {
+	p.stepsLock.RLock()
+	defer p.stepsLock.RUnlock()
+
+	// chains are never modified in place, so they can be used after releasing the lock
+	if steps, ok := p.siteSteps[siteID]; ok {
+		return steps
+	}
+
+	return p.steps
+}
+
+// reserve reserves a slot in the request channel depending on the OverloadPolicy.
+// The slot is released by the worker once the request has been collected.
+// It returns false without an error if the request must be spilled to the Spool instead.
+func (p *Pipe) reserve() (bool, error) {
+	select {
+	case p.slots <- struct{}{}:
+		return true, nil
+	default:
+	}
+
+	switch p.overloadPolicy {
+	case OverloadBlockTimeout:
+		timer := time.NewTimer(p.overloadTimeout)
+		defer timer.Stop()
+
+		select {
+		case p.slots <- struct{}{}:
+			return true, nil
+		case <-p.accept.Done():
+			return false, p.accept.Err()
+		case <-timer.C:
+		}
+	case OverloadDropNewest:
+	case OverloadDropOldest:
+		// requests in the channel have already updated the session, so they are spilled instead of being dropped
+		if p.spool != nil {
+			select {
+			case request := <-p.requests:
+				// the slot of the oldest request is taken over
+				p.spill(request)
+				return true, nil
+			default:
+			}
+		}
+	case OverloadSpill:
+		if p.spool != nil {
+			return false, nil
+		}
+	default:
+		select {
+		case p.slots <- struct{}{}:
+			return true, nil
+		case <-p.accept.Done():
+			return false, p.accept.Err()
+		}
+	}
+
+	p.stats.dropped.Add(1)
+	return false, ErrPipeOverloaded
+}
+
+// spill buffers the request to be written to the Spool in chunks, as writing every request on its own is expensive.
+func (p *Pipe) spill(request *Request) {
+	if !p.logIP {
+		request.IP = ""
+	}
+
+	p.spillLock.Lock()
+	p.spillBuffer.Requests = append(p.spillBuffer.Requests, request.RequestLog())
+
+	if request.Crawler != "" {
+		p.spillBuffer.Crawlers = append(p.spillBuffer.Crawlers, request.CrawlerLog())
+	}
+
+	if !request.cancelled || request.RetractSession {
+		if request.CancelSession != nil {
+			p.spillBuffer.Sessions = append(p.spillBuffer.Sessions, *request.CancelSession)
+		}
+	}
+
+	if !request.cancelled {
+		if request.Session != nil {
+			p.spillBuffer.Sessions = append(p.spillBuffer.Sessions, *request.Session)
+		}
+
+		if request.EventName != "" {
+			p.spillBuffer.Events = append(p.spillBuffer.Events, request.Event())
+		} else {
+			p.spillBuffer.PageViews = append(p.spillBuffer.PageViews, request.PageView())
+		}
+	}
+
+	p.spillCount++
+	full := p.spillCount >= p.spillChunkSize
+
+	if !full && p.spillCount == 1 {
+		p.spillTimer = time.AfterFunc(p.spillTimeout, p.flushSpill)
+	}
+
+	p.spillLock.Unlock()
+
+	if full {
+		p.flushSpill()
+	}
+}
+
+// flushSpill writes the buffered requests to the Spool.
+// The requests are dropped if the Spool is full.
+func (p *Pipe) flushSpill() {
+	p.spillLock.Lock()
+	batch, n := p.spillBuffer, p.spillCount
+	p.spillBuffer = spoolBatchData{}
+	p.spillCount = 0
+
+	if p.spillTimer != nil {
+		p.spillTimer.Stop()
+		p.spillTimer = nil
+	}
+
+	p.spillLock.Unlock()
+
+	if n == 0 {
+		return
+	}
+
+	data, err := json.Marshal(batch)
+
+	if err == nil {
+		err = p.spool.write(spoolBatch, data)
+	}
+
+	if err != nil {
+		p.stats.dropped.Add(uint64(n))
+		p.logger.Error("Error spilling requests, requests have been dropped", "err", err, "requests", n)
+		return
+	}
+
+	p.stats.spilled.Add(uint64(n))
 }
 
-func (p *Pipe) collect(bufferSize int, timeout time.Duration) func() {
+func (p *Pipe) collect(stats *workerStats, bufferSize int, timeout time.Duration) func() {
 	return func() {
 		// double the session buffer size because we always update the session while cancelling the previous row
 		sessions := make([]model.Session, 0, bufferSize*2)
 		pageViews := make([]model.PageView, 0, bufferSize)
 		events := make([]model.Event, 0, bufferSize)
 		requests := make([]model.Request, 0, bufferSize)
+		crawlers := make([]model.Crawler, 0, bufferSize)
 		timer := time.NewTimer(timeout)
 		defer timer.Stop()
+		add := func(request *Request) {
+			if !p.logIP {
+				request.IP = ""
+			}
 
-		for {
-			select {
-			case request := <-p.requests:
-				if !p.logIP {
-					request.IP = ""
-				}
+			p.stats.processed.Add(1)
+			requests = append(requests, request.RequestLog())
 
-				requests = append(requests, model.Request{
-					SiteID:      request.SiteID,
-					VisitorID:   request.VisitorID,
-					Time:        request.Time,
-					Hostname:    request.Hostname,
-					Path:        request.Path,
-					Query:       request.Query,
-					IP:          request.IP,
-					UserAgent:   request.UserAgent,
-					Headers:     request.Headers,
-					EventName:   request.EventName,
-					Referrer:    request.Referrer,
-					UTMSource:   request.UTMSource,
-					UTMMedium:   request.UTMMedium,
-					UTMCampaign: request.UTMCampaign,
-					UTMContent:  request.UTMContent,
-					UTMTerm:     request.UTMTerm,
-					Bot:         request.IsBot,
-					BotReason:   request.BotReason,
-				})
-
-				if !request.cancelled {
-					if request.CancelSession != nil {
-						sessions = append(sessions, *request.CancelSession)
-					}
+			// crawlers are stored separately for analysis, there can only be one per request
+			if request.Crawler != "" {
+				crawlers = append(crawlers, request.CrawlerLog())
+			}
 
-					if request.Session != nil {
-						sessions = append(sessions, *request.Session)
-					}
+			// the previous session state is only stored for cancelled requests if a step removes the session retroactively
+			if !request.cancelled || request.RetractSession {
+				if request.CancelSession != nil {
+					sessions = append(sessions, *request.CancelSession)
+				}
+			}
 
-					if request.EventName != "" {
-						events = append(events, model.Event{
-							Data:     p.dataFromRequest(request),
-							Name:     request.EventName,
-							MetaData: request.EventMetaData,
-							Path:     request.Path,
-							Title:    request.Title,
-						})
-					} else {
-						pageViews = append(pageViews, model.PageView{
-							Data:            p.dataFromRequest(request),
-							DurationSeconds: request.DurationSeconds,
-							Path:            request.Path,
-							Title:           request.Title,
-							Tags:            request.Tags,
-						})
-					}
+			if !request.cancelled {
+				if request.Session != nil {
+					sessions = append(sessions, *request.Session)
 				}
 
+				if request.EventName != "" {
+					events = append(events, request.Event())
+				} else {
+					pageViews = append(pageViews, request.PageView())
+				}
+			}
+
+			stats.update(sessions, pageViews, events, requests)
+		}
+
+		for {
+			select {
+			case request := <-p.requests:
+				add(request)
+
 				if len(sessions) >= bufferSize*2 ||
 					len(pageViews) >= bufferSize ||
 					len(events) >= bufferSize ||
 					len(requests) >= bufferSize {
-					p.flush(sessions, pageViews, events, requests)
+					p.flush(sessions, pageViews, events, requests, crawlers)
 					sessions = sessions[:0]
 					pageViews = pageViews[:0]
 					events = events[:0]
 					requests = requests[:0]
+					crawlers = crawlers[:0]
+					stats.update(sessions, pageViews, events, requests)
 					timer.Reset(timeout)
 				}
+
+				// the slot is released after flushing, so that the channel doesn't fill up while all workers are busy
+				<-p.slots
 			case <-timer.C:
-				p.flush(sessions, pageViews, events, requests)
+				p.flush(sessions, pageViews, events, requests, crawlers)
 				sessions = sessions[:0]
 				pageViews = pageViews[:0]
 				events = events[:0]
 				requests = requests[:0]
+				crawlers = crawlers[:0]
+				stats.update(sessions, pageViews, events, requests)
 				timer.Reset(timeout)
 			case <-p.ctx.Done():
-				p.flush(sessions, pageViews, events, requests)
+				// requests still waiting in the channel buffer must not get lost
+				for len(p.requests) > 0 {
+					select {
+					case request := <-p.requests:
+						add(request)
+						<-p.slots
+					default:
+					}
+				}
+
+				p.flush(sessions, pageViews, events, requests, crawlers)
 				sessions = sessions[:0]
 				pageViews = pageViews[:0]
 				events = events[:0]
 				requests = requests[:0]
+				crawlers = crawlers[:0]
+				stats.update(sessions, pageViews, events, requests)
 				return
 			}
 		}
 	}
 }
 
-func (p *Pipe) dataFromRequest(request *Request) model.Data {
-	return model.Data{
-		SiteID:         request.SiteID,
-		VisitorID:      request.VisitorID,
-		SessionID:      request.SessionID,
-		Time:           request.Time,
-		Hostname:       request.Hostname,
-		Language:       request.Language,
-		CountryCode:    request.CountryCode,
-		Region:         request.Region,
-		City:           request.City,
-		Referrer:       request.Referrer,
-		ReferrerName:   request.ReferrerName,
-		ReferrerIcon:   request.ReferrerIcon,
-		OS:             request.OS,
-		OSVersion:      request.OSVersion,
-		Browser:        request.Browser,
-		BrowserVersion: request.BrowserVersion,
-		Platform:       request.Platform,
-		ScreenClass:    request.ScreenClass,
-		UTMSource:      request.UTMSource,
-		UTMMedium:      request.UTMMedium,
-		UTMCampaign:    request.UTMCampaign,
-		UTMContent:     request.UTMContent,
-		UTMTerm:        request.UTMTerm,
-		Channel:        request.Channel,
-	}
-}
-
-func (p *Pipe) flush(sessions []model.Session, pageViews []model.PageView, events []model.Event, requests []model.Request) {
+func (p *Pipe) flush(sessions []model.Session, pageViews []model.PageView, events []model.Event, requests []model.Request, crawlers []model.Crawler) {
 	// copy ingestion data
 	sessionsCopy := make([]model.Session, len(sessions))
 	pageViewsCopy := make([]model.PageView, len(pageViews))
 	eventsCopy := make([]model.Event, len(events))
 	requestsCopy := make([]model.Request, len(requests))
+	crawlersCopy := make([]model.Crawler, len(crawlers))
 	copy(sessionsCopy, sessions)
 	copy(pageViewsCopy, pageViews)
 	copy(eventsCopy, events)
 	copy(requestsCopy, requests)
+	copy(crawlersCopy, crawlers)
+
+	// saving must not be canceled when the pipe is stopped, so that the remaining data is flushed
+	ctx := context.WithoutCancel(p.ctx)
 
 	// retries run asynchronously, so that we won't block the main ingestion pipeline
+	start := time.Now()
 	var wg sync.WaitGroup
 	wg.Go(func() {
 		p.flushWithRetry(func() error {
-			return p.storage.SaveSessions(p.ctx, sessionsCopy)
-		}, "save sessions")
+			return p.storage.SaveSessions(ctx, sessionsCopy)
+		}, spoolFunc(p.spool, spoolSessions, sessionsCopy), "save sessions")
 	})
 	wg.Go(func() {
 		p.flushWithRetry(func() error {
-			return p.storage.SavePageViews(p.ctx, pageViewsCopy)
-		}, "save page views")
+			return p.storage.SavePageViews(ctx, pageViewsCopy)
+		}, spoolFunc(p.spool, spoolPageViews, pageViewsCopy), "save page views")
 	})
 	wg.Go(func() {
 		p.flushWithRetry(func() error {
-			return p.storage.SaveEvents(p.ctx, eventsCopy)
-		}, "save events")
+			return p.storage.SaveEvents(ctx, eventsCopy)
+		}, spoolFunc(p.spool, spoolEvents, eventsCopy), "save events")
 	})
 	wg.Go(func() {
 		p.flushWithRetry(func() error {
-			return p.storage.SaveRequests(p.ctx, requestsCopy)
-		}, "save requests")
+			return p.storage.SaveRequests(ctx, requestsCopy)
+		}, spoolFunc(p.spool, spoolRequests, requestsCopy), "save requests")
+	})
+	wg.Go(func() {
+		p.flushWithRetry(func() error {
+			return db.SaveCrawlers(ctx, p.storage, crawlersCopy)
+		}, spoolFunc(p.spool, spoolCrawlers, crawlersCopy), "save crawlers")
 	})
 	wg.Wait()
+	p.stats.flush(time.Since(start))
 }
 
-func (p *Pipe) flushWithRetry(save func() error, operation string) {
+func (p *Pipe) flushWithRetry(save, spool func() error, operation string) {
 	if err := save(); err == nil {
+		p.triggerReplay()
 		return
 	}
 
 	// run retries asynchronously
-	go func() {
+	p.stats.flushErrors.Add(1)
+	p.retries.Go(func() {
 		const maxRetries = 5
 		var err error
 
 		for attempt := range maxRetries {
-			select {
-			case <-p.ctx.Done():
-				p.logger.Error("Failed saving data",
-					"err", "context canceled",
-					"operation", operation)
+			p.stats.retries.Add(1)
+
+			if err = save(); err == nil {
+				p.triggerReplay()
 				return
-			default:
-				if err = save(); err == nil {
-					return
-				}
+			}
 
-				remaining := maxRetries - attempt - 1
+			remaining := maxRetries - attempt - 1
 
-				if remaining == 0 {
-					break
-				}
+			if remaining == 0 {
+				break
+			}
+
+			backoff := time.Duration(attempt+1) * 20 * time.Second
+			jitter := time.Duration(rand.N(5)) * time.Second
+			wait := backoff + jitter
+			p.logger.Error("Storage error, retrying", "err", err,
+				"operation", operation,
+				"retries_remaining", remaining,
+				"wait", wait)
+			timer := time.NewTimer(wait)
 
-				backoff := time.Duration(attempt+1) * 20 * time.Second
-				jitter := time.Duration(rand.N(5)) * time.Second
-				wait := backoff + jitter
-				p.logger.Error("Storage error, retrying", "err", err,
-					"operation", operation,
-					"retries_remaining", remaining,
-					"wait", wait)
-				time.Sleep(wait)
+			select {
+			case <-p.ctx.Done():
+				timer.Stop()
+				p.spoolOrDrop(spool, operation, errors.New("context canceled"))
+				return
+			case <-timer.C:
 			}
 		}
 
+		p.spoolOrDrop(spool, operation, fmt.Errorf("%s failed after %d attempts: %s", operation, maxRetries, err))
+	})
+}
+
+func (p *Pipe) spoolOrDrop(spool func() error, operation string, err error) {
+	p.stats.failedBatches.Add(1)
+
+	if spool == nil {
 		p.logger.Error("Failed saving data",
-			"err", fmt.Sprintf("%s failed after %d attempts: %s", operation, maxRetries, err),
+			"err", err,
 			"operation", operation)
-	}()
-}
+		return
+	}
 
-func (p *Pipe) ignore(request *Request) bool {
-	// ignore requests with missing http.Request attributes
-	if request.Request == nil {
-		return true
+	if spoolErr := spool(); spoolErr != nil {
+		p.logger.Error("Failed saving data, spooling failed",
+			"err", err,
+			"spool_err", spoolErr,
+			"operation", operation)
+		return
 	}
 
-	// ignore browsers pre-fetching data
-	xMoz := strings.ToLower(request.Request.Header.Get("X-Moz"))
-	xPurpose := strings.ToLower(request.Request.Header.Get("X-Purpose"))
-	purpose := strings.ToLower(request.Request.Header.Get("Purpose"))
-
-	if xMoz == "prefetch" ||
-		xPurpose == "prefetch" ||
-		xPurpose == "preview" ||
-		purpose == "prefetch" ||
-		purpose == "preview" {
-		return true
+	p.stats.spooledBatches.Add(1)
+	p.logger.Warn("Failed saving data, batch has been spooled",
+		"err", err,
+		"operation", operation)
+}
+
+func (p *Pipe) triggerReplay() {
+	if p.spool != nil && p.spool.Len() > 0 {
+		select {
+		case p.replay <- struct{}{}:
+		default:
+		}
 	}
+}
 
-	return false
+func (p *Pipe) replaySpool() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.replay:
+			n, err := p.spool.Replay(p.ctx, p.storage)
+
+			if err != nil && !errors.Is(err, context.Canceled) {
+				p.logger.Error("Error replaying spool", "err", err, "replayed", n)
+			} else if n > 0 {
+				p.logger.Info("Replayed spool", "replayed", n)
+			}
+		}
+	}
 }
@@ -7,6 +7,31 @@ import (
 	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
 )
 
+// OverloadPolicy defines how Pipe.Process behaves if the request channel is full, because all workers are busy.
+type OverloadPolicy int
+
+const (
+	// OverloadBlock blocks the caller until the request can be scheduled (default).
+	OverloadBlock = OverloadPolicy(iota)
+
+	// OverloadBlockTimeout blocks the caller until the request can be scheduled or the PipeOptions.OverloadTimeout is reached.
+	// The request is dropped on timeout.
+	OverloadBlockTimeout
+
+	// OverloadDropNewest drops the request immediately.
+	OverloadDropNewest
+
+	// OverloadDropOldest moves the oldest request in the channel to the Spool to make room for the new one.
+	// The oldest request has already updated the session, so it can't be dropped without breaking the session state.
+	// This requires the PipeOptions.RequestChannelBufferSize and PipeOptions.SpoolDir to be set, otherwise it behaves like OverloadDropNewest.
+	OverloadDropOldest
+
+	// OverloadSpill writes the request to the Spool, so that it can be replayed later.
+	// Requests are buffered and written in chunks of PipeOptions.SpillChunkSize.
+	// This requires the PipeOptions.SpoolDir to be set, otherwise it behaves like OverloadDropNewest.
+	OverloadSpill
+)
+
 // PipeOptions is the configuration for a Pipe.
 type PipeOptions struct {
 	// Storage is the data storage to be used for this Pipe.
@@ -25,9 +50,31 @@ type PipeOptions struct {
 	// WorkerTimeout sets the maximum waiting time before the worker buffers are flushed.
 	WorkerTimeout time.Duration
 
+	// OverloadPolicy defines what happens if all workers are busy and the request channel is full.
+	// Defaults to OverloadBlock.
+	OverloadPolicy OverloadPolicy
+
+	// OverloadTimeout is the maximum time to wait for OverloadBlockTimeout.
+	// If set to <= 0, the default value of one second will be used.
+	OverloadTimeout time.Duration
+
+	// SpillChunkSize is the number of requests buffered for OverloadSpill before they are written to the Spool.
+	// Buffered requests are written after the WorkerTimeout at the latest.
+	// If set to <= 0, the default value of 100 will be used.
+	SpillChunkSize int
+
 	// LogIP will log the request IP in the Storage if set to true.
 	LogIP bool
 
+	// SpoolDir is the directory used to persist batches that could not be saved to the Storage after retrying.
+	// Persisted batches are replayed on the next start and once the Storage has recovered.
+	// If not set, failed batches will be dropped.
+	SpoolDir string
+
+	// SpoolMaxSize is the maximum size of all batches in the SpoolDir in bytes.
+	// If set to <= 0, the default value of 512 MB will be used.
+	SpoolMaxSize int64
+
 	// Logger is the logger for the Pipe.
 	// If not set, the default slog.Logger will be used.
 	Logger *slog.Logger
@@ -50,6 +97,14 @@ func (options *PipeOptions) validate() {
 		options.WorkerTimeout = time.Second * 5
 	}
 
+	if options.OverloadTimeout <= 0 {
+		options.OverloadTimeout = time.Second
+	}
+
+	if options.SpillChunkSize <= 0 {
+		options.SpillChunkSize = 100
+	}
+
 	if options.Logger == nil {
 		options.Logger = slog.Default()
 	}
@@ -0,0 +1,301 @@
+package ingest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+)
+
+// PipeStats is a snapshot of the runtime statistics of a Pipe.
+type PipeStats struct {
+	// QueueLength is the number of requests waiting in the request channel.
+	QueueLength int
+
+	// QueueCapacity is the buffer size of the request channel.
+	QueueCapacity int
+
+	// Workers is the buffer fill per worker.
+	Workers []WorkerStats
+
+	// Processed is the number of requests scheduled to be stored.
+	Processed uint64
+
+	// Ignored is the number of requests ignored before processing (like pre-fetch requests).
+	Ignored uint64
+
+	// Cancelled is the number of requests cancelled by a PipeStep.
+	Cancelled uint64
+
+	// Dropped is the number of requests dropped because the Pipe was overloaded.
+	Dropped uint64
+
+	// Spilled is the number of requests written to the Spool because the Pipe was overloaded.
+	Spilled uint64
+
+	// Flushes is the number of times a worker flushed its buffers.
+	Flushes uint64
+
+	// FlushErrors is the number of batches that failed to save on the first attempt.
+	FlushErrors uint64
+
+	// Retries is the number of retries to save a batch.
+	Retries uint64
+
+	// FailedBatches is the number of batches that could not be saved after retrying.
+	FailedBatches uint64
+
+	// SpooledBatches is the number of failed batches written to the Spool.
+	SpooledBatches uint64
+
+	// SpoolLength is the number of batches currently in the Spool.
+	SpoolLength int
+
+	// SpoolSize is the size of all batches currently in the Spool in bytes.
+	SpoolSize int64
+
+	// LastFlushDuration is the time it took to flush the last batch.
+	LastFlushDuration time.Duration
+
+	// TotalFlushDuration is the total time spent flushing batches.
+	TotalFlushDuration time.Duration
+
+	// StepCancellations is the number of requests cancelled per PipeStep and bot reason.
+	StepCancellations []StepCancellation
+}
+
+// WorkerStats is the buffer fill of a single worker.
+type WorkerStats struct {
+	// BufferSize is the buffer size per type.
+	BufferSize int
+
+	// Sessions is the number of sessions in the buffer.
+	Sessions int
+
+	// PageViews is the number of page views in the buffer.
+	PageViews int
+
+	// Events is the number of events in the buffer.
+	Events int
+
+	// Requests is the number of requests in the buffer.
+	Requests int
+}
+
+// StepCancellation is the number of requests a PipeStep cancelled for a bot reason.
+type StepCancellation struct {
+	// Step is the type name of the PipeStep.
+	Step string
+
+	// BotReason is the reason set on the Request. It's empty if the request was not cancelled for being a bot.
+	BotReason string
+
+	// Count is the number of requests cancelled.
+	Count uint64
+}
+
+type workerStats struct {
+	sessions  atomic.Int64
+	pageViews atomic.Int64
+	events    atomic.Int64
+	requests  atomic.Int64
+}
+
+type stepCancellationKey struct {
+	step      string
+	botReason string
+}
+
+type pipeStats struct {
+	bufferSize         int
+	workers            []*workerStats
+	processed          atomic.Uint64
+	ignored            atomic.Uint64
+	cancelled          atomic.Uint64
+	dropped            atomic.Uint64
+	spilled            atomic.Uint64
+	flushes            atomic.Uint64
+	flushErrors        atomic.Uint64
+	retries            atomic.Uint64
+	failedBatches      atomic.Uint64
+	spooledBatches     atomic.Uint64
+	lastFlushDuration  atomic.Int64
+	totalFlushDuration atomic.Int64
+	stepCancellations  map[stepCancellationKey]uint64
+	m                  sync.Mutex
+}
+
+func newPipeStats(worker, bufferSize int) *pipeStats {
+	workers := make([]*workerStats, worker)
+
+	for i := range workers {
+		workers[i] = new(workerStats)
+	}
+
+	return &pipeStats{
+		bufferSize:        bufferSize,
+		workers:           workers,
+		stepCancellations: make(map[stepCancellationKey]uint64),
+	}
+}
+
+func (stats *workerStats) update(sessions []model.Session, pageViews []model.PageView, events []model.Event, requests []model.Request) {
+	stats.sessions.Store(int64(len(sessions)))
+	stats.pageViews.Store(int64(len(pageViews)))
+	stats.events.Store(int64(len(events)))
+	stats.requests.Store(int64(len(requests)))
+}
+
+func (stats *pipeStats) cancel(step PipeStep, botReason string) {
+	stats.cancelled.Add(1)
+	stats.m.Lock()
+	defer stats.m.Unlock()
+	stats.stepCancellations[stepCancellationKey{fmt.Sprintf("%T", step), botReason}]++
+}
+
+func (stats *pipeStats) flush(d time.Duration) {
+	stats.flushes.Add(1)
+	stats.lastFlushDuration.Store(int64(d))
+	stats.totalFlushDuration.Add(int64(d))
+}
+
+// Stats returns a snapshot of the runtime statistics for the Pipe.
+func (p *Pipe) Stats() PipeStats {
+	workers := make([]WorkerStats, len(p.stats.workers))
+
+	for i, w := range p.stats.workers {
+		workers[i] = WorkerStats{
+			BufferSize: p.stats.bufferSize,
+			Sessions:   int(w.sessions.Load()),
+			PageViews:  int(w.pageViews.Load()),
+			Events:     int(w.events.Load()),
+			Requests:   int(w.requests.Load()),
+		}
+	}
+
+	p.stats.m.Lock()
+	stepCancellations := make([]StepCancellation, 0, len(p.stats.stepCancellations))
+
+	for k, v := range p.stats.stepCancellations {
+		stepCancellations = append(stepCancellations, StepCancellation{
+			Step:      k.step,
+			BotReason: k.botReason,
+			Count:     v,
+		})
+	}
+
+	p.stats.m.Unlock()
+	slices.SortFunc(stepCancellations, func(a, b StepCancellation) int {
+		if c := strings.Compare(a.Step, b.Step); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.BotReason, b.BotReason)
+	})
+	stats := PipeStats{
+		QueueLength:        len(p.requests),
+		QueueCapacity:      p.queueCapacity,
+		Workers:            workers,
+		Processed:          p.stats.processed.Load(),
+		Ignored:            p.stats.ignored.Load(),
+		Cancelled:          p.stats.cancelled.Load(),
+		Dropped:            p.stats.dropped.Load(),
+		Spilled:            p.stats.spilled.Load(),
+		Flushes:            p.stats.flushes.Load(),
+		FlushErrors:        p.stats.flushErrors.Load(),
+		Retries:            p.stats.retries.Load(),
+		FailedBatches:      p.stats.failedBatches.Load(),
+		SpooledBatches:     p.stats.spooledBatches.Load(),
+		LastFlushDuration:  time.Duration(p.stats.lastFlushDuration.Load()),
+		TotalFlushDuration: time.Duration(p.stats.totalFlushDuration.Load()),
+		StepCancellations:  stepCancellations,
+	}
+
+	if p.spool != nil {
+		stats.SpoolLength = p.spool.Len()
+		stats.SpoolSize = p.spool.Size()
+	}
+
+	return stats
+}
+
+// MetricsHandler returns a http.Handler exposing the Pipe statistics in the Prometheus text format.
+func (p *Pipe) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		stats := p.Stats()
+		var out strings.Builder
+		writeMetric(&out, "pirsch_pipe_queue_length", "gauge", "Number of requests waiting in the request channel.", stats.QueueLength)
+		writeMetric(&out, "pirsch_pipe_queue_capacity", "gauge", "Buffer size of the request channel.", stats.QueueCapacity)
+		writeMetricHeader(&out, "pirsch_pipe_worker_buffer", "gauge", "Number of rows in the worker buffer by type.")
+
+		for i, worker := range stats.Workers {
+			writeMetricValue(&out, "pirsch_pipe_worker_buffer", worker.Sessions, "worker", fmt.Sprint(i), "type", "sessions")
+			writeMetricValue(&out, "pirsch_pipe_worker_buffer", worker.PageViews, "worker", fmt.Sprint(i), "type", "page_views")
+			writeMetricValue(&out, "pirsch_pipe_worker_buffer", worker.Events, "worker", fmt.Sprint(i), "type", "events")
+			writeMetricValue(&out, "pirsch_pipe_worker_buffer", worker.Requests, "worker", fmt.Sprint(i), "type", "requests")
+		}
+
+		if len(stats.Workers) > 0 {
+			writeMetric(&out, "pirsch_pipe_worker_buffer_size", "gauge", "Buffer size per type and worker.", stats.Workers[0].BufferSize)
+		}
+
+		writeMetric(&out, "pirsch_pipe_processed_total", "counter", "Number of requests scheduled to be stored.", stats.Processed)
+		writeMetric(&out, "pirsch_pipe_ignored_total", "counter", "Number of requests ignored before processing.", stats.Ignored)
+		writeMetric(&out, "pirsch_pipe_cancelled_total", "counter", "Number of requests cancelled by a step.", stats.Cancelled)
+		writeMetric(&out, "pirsch_pipe_dropped_total", "counter", "Number of requests dropped because the pipe was overloaded.", stats.Dropped)
+		writeMetric(&out, "pirsch_pipe_spilled_total", "counter", "Number of requests spilled to disk because the pipe was overloaded.", stats.Spilled)
+		writeMetric(&out, "pirsch_pipe_flushes_total", "counter", "Number of worker buffer flushes.", stats.Flushes)
+		writeMetric(&out, "pirsch_pipe_flush_errors_total", "counter", "Number of batches that failed to save on the first attempt.", stats.FlushErrors)
+		writeMetric(&out, "pirsch_pipe_retries_total", "counter", "Number of retries to save a batch.", stats.Retries)
+		writeMetric(&out, "pirsch_pipe_failed_batches_total", "counter", "Number of batches that could not be saved after retrying.", stats.FailedBatches)
+		writeMetric(&out, "pirsch_pipe_spooled_batches_total", "counter", "Number of failed batches written to the spool.", stats.SpooledBatches)
+		writeMetric(&out, "pirsch_pipe_spool_length", "gauge", "Number of batches in the spool.", stats.SpoolLength)
+		writeMetric(&out, "pirsch_pipe_spool_size_bytes", "gauge", "Size of all batches in the spool in bytes.", stats.SpoolSize)
+		writeMetric(&out, "pirsch_pipe_last_flush_duration_seconds", "gauge", "Time it took to flush the last batch.", stats.LastFlushDuration.Seconds())
+		writeMetric(&out, "pirsch_pipe_flush_duration_seconds_total", "counter", "Total time spent flushing batches.", stats.TotalFlushDuration.Seconds())
+		writeMetricHeader(&out, "pirsch_pipe_step_cancelled_total", "counter", "Number of requests cancelled by step and bot reason.")
+
+		for _, c := range stats.StepCancellations {
+			writeMetricValue(&out, "pirsch_pipe_step_cancelled_total", c.Count, "step", c.Step, "reason", c.BotReason)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write([]byte(out.String()))
+	})
+}
+
+func writeMetric(out *strings.Builder, name, kind, help string, value any) {
+	writeMetricHeader(out, name, kind, help)
+	writeMetricValue(out, name, value)
+}
+
+func writeMetricHeader(out *strings.Builder, name, kind, help string) {
+	out.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind))
+}
+
+func writeMetricValue(out *strings.Builder, name string, value any, labels ...string) {
+	out.WriteString(name)
+
+	if len(labels) > 1 {
+		pairs := make([]string, 0, len(labels)/2)
+
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], escapeMetricLabel(labels[i+1])))
+		}
+
+		out.WriteString(fmt.Sprintf("{%s}", strings.Join(pairs, ",")))
+	}
+
+	out.WriteString(fmt.Sprintf(" %v\n", value))
+}
+
+func escapeMetricLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
@@ -0,0 +1,107 @@
+package ingest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeStats(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		// create a pipeline with a step cancelling bot requests
+		storage := newStorageWithError(errors.New("error on save"))
+		pipe := NewPipe(PipeOptions{
+			Storage:          storage,
+			Worker:           2,
+			WorkerBufferSize: 10,
+			WorkerTimeout:    time.Hour,
+		}).Use(&botStep{}, &sessionStep{})
+		defer pipe.Stop()
+		assert.NoError(t, pipe.Process(newTestRequest("/")))
+		assert.NoError(t, pipe.Process(newTestRequest("/bot")))
+		assert.NoError(t, pipe.Process(newTestRequest("/bot")))
+		prefetch := newTestRequest("/")
+		prefetch.Request.Header.Set("Purpose", "prefetch")
+		assert.NoError(t, pipe.Process(prefetch))
+		synctest.Wait()
+
+		// the requests must be waiting in the worker buffers
+		stats := pipe.Stats()
+		assert.Zero(t, stats.QueueLength)
+		assert.Zero(t, stats.QueueCapacity)
+		assert.Len(t, stats.Workers, 2)
+		assert.Equal(t, 10, stats.Workers[0].BufferSize)
+		assert.Equal(t, 3, stats.Workers[0].Requests+stats.Workers[1].Requests)
+		assert.Equal(t, 1, stats.Workers[0].Sessions+stats.Workers[1].Sessions)
+		assert.Equal(t, 1, stats.Workers[0].PageViews+stats.Workers[1].PageViews)
+		assert.Equal(t, uint64(3), stats.Processed)
+		assert.Equal(t, uint64(1), stats.Ignored)
+		assert.Equal(t, uint64(2), stats.Cancelled)
+		assert.Len(t, stats.StepCancellations, 1)
+		assert.Equal(t, "*ingest.botStep", stats.StepCancellations[0].Step)
+		assert.Equal(t, "path", stats.StepCancellations[0].BotReason)
+		assert.Equal(t, uint64(2), stats.StepCancellations[0].Count)
+		assert.Zero(t, stats.Flushes)
+
+		// flushing must fail and be retried
+		time.Sleep(time.Hour + time.Second)
+		synctest.Wait()
+		stats = pipe.Stats()
+		assert.Zero(t, stats.Workers[0].Requests+stats.Workers[1].Requests)
+		assert.Equal(t, uint64(2), stats.Flushes)
+		assert.Equal(t, uint64(4), stats.FlushErrors)
+		assert.Equal(t, uint64(4), stats.Retries)
+		assert.Zero(t, stats.FailedBatches)
+
+		// the batches must be dropped after exhausting the maximum number of retries
+		time.Sleep(time.Second * 300)
+		synctest.Wait()
+		stats = pipe.Stats()
+		assert.Equal(t, uint64(20), stats.Retries)
+		assert.Equal(t, uint64(4), stats.FailedBatches)
+		assert.Zero(t, stats.SpooledBatches)
+	})
+}
+
+func TestPipeMetricsHandler(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		pipe := NewPipe(PipeOptions{
+			Storage: newStorageWithError(nil),
+			Worker:  1,
+		}).Use(&botStep{})
+		defer pipe.Stop()
+		assert.NoError(t, pipe.Process(newTestRequest("/bot")))
+		synctest.Wait()
+		w := httptest.NewRecorder()
+		pipe.MetricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"))
+		body := w.Body.String()
+		assert.Contains(t, body, "# TYPE pirsch_pipe_processed_total counter\npirsch_pipe_processed_total 1\n")
+		assert.Contains(t, body, "pirsch_pipe_queue_capacity 0\n")
+		assert.Contains(t, body, `pirsch_pipe_worker_buffer{worker="0",type="requests"} 1`)
+		assert.Contains(t, body, `pirsch_pipe_step_cancelled_total{step="*ingest.botStep",reason="path"} 1`)
+	})
+}
+
+func TestEscapeMetricLabel(t *testing.T) {
+	assert.Equal(t, `a\\b\"c\nd`, escapeMetricLabel("a\\b\"c\nd"))
+}
+
+type botStep struct{}
+
+func (s *botStep) Step(request *Request) (bool, error) {
+	if request.Request.URL.Path == "/bot" {
+		request.IsBot = true
+		request.BotReason = "path"
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -45,6 +45,29 @@ func TestPipeSimple(t *testing.T) {
 	assert.Equal(t, "en-US,en;q=0.5", requests[0].Headers["Accept-Language"])
 }
 
+func TestPipeCancelSession(t *testing.T) {
+	storage := db.NewMock()
+	pipe := NewPipe(PipeOptions{
+		Storage: storage,
+		Worker:  1,
+	}).Use(&cancelSessionStep{}, &botStep{})
+
+	// the previous session state must only be stored for cancelled requests if the session is retracted
+	assert.NoError(t, pipe.Process(newTestRequest("/")))
+	assert.NoError(t, pipe.Process(newTestRequest("/bot")))
+	request := newTestRequest("/bot")
+	request.RetractSession = true
+	assert.NoError(t, pipe.Process(request))
+	pipe.Stop()
+	sessions := storage.Sessions()
+	assert.Len(t, sessions, 3)
+	assert.Equal(t, int8(-1), sessions[0].Sign)
+	assert.Equal(t, int8(1), sessions[1].Sign)
+	assert.Equal(t, int8(-1), sessions[2].Sign)
+	assert.Len(t, storage.PageViews(), 1)
+	assert.Len(t, storage.Requests(), 3)
+}
+
 func TestPipeTimeout(t *testing.T) {
 	synctest.Test(t, func(t *testing.T) {
 		// create a pipeline with 5 seconds timeout
@@ -297,6 +320,347 @@ func TestPipePrefetch(t *testing.T) {
 	assert.Empty(t, storage.PageViews())
 }
 
+func TestPipeOverloadDropNewest(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		storage := newBlockingStorage()
+		pipe := NewPipe(PipeOptions{
+			Storage:          storage,
+			Worker:           1,
+			WorkerBufferSize: 1,
+			OverloadPolicy:   OverloadDropNewest,
+		})
+
+		// the first request blocks the only worker while flushing
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/first")))
+		synctest.Wait()
+
+		// the second request must be dropped immediately
+		assert.ErrorIs(t, pipe.Process(newTestRequest("/second")), ErrPipeOverloaded)
+		assert.Equal(t, uint64(1), pipe.Dropped())
+
+		// only the first request must have been stored
+		storage.release()
+		pipe.Stop()
+		pageViews := storage.PageViews()
+		assert.Len(t, pageViews, 1)
+		assert.Equal(t, "/first", pageViews[0].Path)
+	})
+}
+
+func TestPipeOverloadBlockTimeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		storage := newBlockingStorage()
+		pipe := NewPipe(PipeOptions{
+			Storage:          storage,
+			Worker:           1,
+			WorkerBufferSize: 1,
+			OverloadPolicy:   OverloadBlockTimeout,
+			OverloadTimeout:  time.Second * 3,
+		})
+
+		// the first request blocks the only worker while flushing
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/first")))
+		synctest.Wait()
+
+		// the second request must be dropped after the timeout
+		start := time.Now()
+		assert.ErrorIs(t, pipe.Process(newTestRequest("/second")), ErrPipeOverloaded)
+		assert.Equal(t, time.Second*3, time.Since(start))
+		assert.Equal(t, uint64(1), pipe.Dropped())
+		storage.release()
+		pipe.Stop()
+		assert.Len(t, storage.PageViews(), 1)
+	})
+}
+
+func TestPipeOverloadDropOldest(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		storage := newBlockingStorage()
+		pipe := NewPipe(PipeOptions{
+			Storage:                  storage,
+			RequestChannelBufferSize: 1,
+			Worker:                   1,
+			WorkerBufferSize:         1,
+			OverloadPolicy:           OverloadDropOldest,
+			SpillChunkSize:           1,
+			SpoolDir:                 t.TempDir(),
+		})
+
+		// the first request blocks the only worker while flushing and the second one is buffered
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/first")))
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/second")))
+
+		// the third request must replace the second one, which is moved to the spool
+		assert.NoError(t, pipe.Process(newTestRequest("/third")))
+		assert.Zero(t, pipe.Dropped())
+		assert.Equal(t, uint64(1), pipe.Spilled())
+		assert.Equal(t, 1, pipe.Spool().Len())
+
+		// and replayed once the storage is available again
+		storage.release()
+		synctest.Wait()
+		pipe.Stop()
+		paths := make([]string, 0, 3)
+
+		for _, pageView := range storage.PageViews() {
+			paths = append(paths, pageView.Path)
+		}
+
+		assert.ElementsMatch(t, []string{"/first", "/second", "/third"}, paths)
+		assert.Zero(t, pipe.Spool().Len())
+	})
+}
+
+func TestPipeOverloadDropOldestNoSpool(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		storage := newBlockingStorage()
+		pipe := NewPipe(PipeOptions{
+			Storage:                  storage,
+			RequestChannelBufferSize: 1,
+			Worker:                   1,
+			WorkerBufferSize:         1,
+			OverloadPolicy:           OverloadDropOldest,
+		})
+
+		// the third request must be dropped, as the second one can't be moved to the spool
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/first")))
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/second")))
+		assert.ErrorIs(t, pipe.Process(newTestRequest("/third")), ErrPipeOverloaded)
+		assert.Equal(t, uint64(1), pipe.Dropped())
+		storage.release()
+		pipe.Stop()
+		pageViews := storage.PageViews()
+		assert.Len(t, pageViews, 2)
+		assert.Equal(t, "/first", pageViews[0].Path)
+		assert.Equal(t, "/second", pageViews[1].Path)
+	})
+}
+
+func TestPipeOverloadSessionState(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		storage := newBlockingStorage()
+		pipe := NewPipe(PipeOptions{
+			Storage:          storage,
+			Worker:           1,
+			WorkerBufferSize: 1,
+			OverloadPolicy:   OverloadDropNewest,
+		}).Use(&versionedSessionStep{})
+
+		// the second request is dropped in the middle of the session
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/first")))
+		synctest.Wait()
+		assert.ErrorIs(t, pipe.Process(newTestRequest("/second")), ErrPipeOverloaded)
+		storage.release()
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/third")))
+		pipe.Stop()
+
+		// the dropped request must not have updated the session, so that the cancel row matches the stored state
+		sessions := storage.Sessions()
+		signs := make(map[uint16]int)
+		sum := 0
+
+		for _, session := range sessions {
+			signs[session.Version] += int(session.Sign)
+			sum += int(session.Sign)
+		}
+
+		assert.Len(t, sessions, 3)
+		assert.Equal(t, 1, sum)
+		assert.Equal(t, map[uint16]int{1: 0, 2: 1}, signs)
+		assert.Equal(t, uint16(2), sessions[2].PageViews)
+	})
+}
+
+func TestPipeOverloadSpill(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		storage := newBlockingStorage()
+		pipe := NewPipe(PipeOptions{
+			Storage:          storage,
+			Worker:           1,
+			WorkerBufferSize: 1,
+			OverloadPolicy:   OverloadSpill,
+			SpillChunkSize:   2,
+			SpoolDir:         t.TempDir(),
+		}).Use(&sessionStep{})
+
+		// the first request blocks the only worker while flushing
+		synctest.Wait()
+		assert.NoError(t, pipe.Process(newTestRequest("/first")))
+		synctest.Wait()
+
+		// the second request must be buffered
+		assert.NoError(t, pipe.Process(newTestRequest("/second")))
+		assert.Zero(t, pipe.Dropped())
+		assert.Zero(t, pipe.Spilled())
+		assert.Zero(t, pipe.Spool().Len())
+
+		// and written to the spool together with the third one
+		assert.NoError(t, pipe.Process(newTestRequest("/third")))
+		assert.Zero(t, pipe.Dropped())
+		assert.Equal(t, uint64(2), pipe.Spilled())
+		assert.Equal(t, 1, pipe.Spool().Len())
+
+		// incomplete chunks must be written after the worker timeout
+		assert.NoError(t, pipe.Process(newTestRequest("/fourth")))
+		assert.Equal(t, uint64(2), pipe.Spilled())
+		time.Sleep(time.Second * 5)
+		synctest.Wait()
+		assert.Equal(t, uint64(3), pipe.Spilled())
+		assert.Equal(t, 2, pipe.Spool().Len())
+
+		// and replayed once the storage is available again
+		storage.release()
+		synctest.Wait()
+		pipe.Stop()
+		assert.Len(t, storage.Sessions(), 4)
+		assert.Len(t, storage.PageViews(), 4)
+		assert.Len(t, storage.Requests(), 4)
+		assert.Zero(t, pipe.Spool().Len())
+	})
+}
+
+func TestPipeSiteSteps(t *testing.T) {
+	// create a pipeline with a default chain and a chain for site 1 without bot filtering
+	storage := db.NewMock()
+	pipe := NewPipe(PipeOptions{
+		Storage: storage,
+		Worker:  1,
+	}).Use(&botStep{}, &sessionStep{}).UseSite(1, &sessionStep{})
+	newSiteRequest := func(siteID uint64) *Request {
+		req := newTestRequest("/bot")
+		req.SiteID = siteID
+		return req
+	}
+
+	// the request must be cancelled for the default chain, but not for site 1
+	req := newSiteRequest(0)
+	assert.NoError(t, pipe.Process(req))
+	assert.True(t, req.IsBot)
+	req = newSiteRequest(1)
+	assert.NoError(t, pipe.Process(req))
+	assert.False(t, req.IsBot)
+
+	// swap the chains at runtime
+	pipe.SetSiteSteps(1, &botStep{})
+	pipe.SetSteps(&sessionStep{})
+	req = newSiteRequest(1)
+	assert.NoError(t, pipe.Process(req))
+	assert.True(t, req.IsBot)
+	req = newSiteRequest(0)
+	assert.NoError(t, pipe.Process(req))
+	assert.False(t, req.IsBot)
+
+	// removing the site chain must fall back to the default chain
+	pipe.RemoveSiteSteps(1)
+	req = newSiteRequest(1)
+	assert.NoError(t, pipe.Process(req))
+	assert.False(t, req.IsBot)
+
+	// an empty chain must skip all steps
+	pipe.UseSite(2)
+	req = newSiteRequest(2)
+	assert.NoError(t, pipe.Process(req))
+	assert.Nil(t, req.Session)
+	pipe.Stop()
+	assert.Len(t, storage.Requests(), 6)
+	assert.Len(t, storage.PageViews(), 4)
+	assert.Len(t, storage.Sessions(), 3)
+}
+
+func TestPipeSiteStepsConcurrency(t *testing.T) {
+	pipe := NewPipe(PipeOptions{
+		Storage: db.NewMock(),
+	}).Use(&sessionStep{})
+	var wg sync.WaitGroup
+
+	for i := range 100 {
+		wg.Go(func() {
+			req := newTestRequest("/")
+			req.SiteID = uint64(i % 5)
+			assert.NoError(t, pipe.Process(req))
+		})
+		wg.Go(func() {
+			pipe.SetSiteSteps(uint64(i%5), &sessionStep{}, &botStep{})
+		})
+	}
+
+	wg.Wait()
+	pipe.Stop()
+}
+
+func TestPipeCrawlers(t *testing.T) {
+	storage := db.NewMock()
+	pipe := NewPipe(PipeOptions{
+		Storage: storage,
+		Worker:  1,
+	}).Use(&crawlerStep{}, &sessionStep{})
+	assert.NoError(t, pipe.Process(newTestRequest("/")))
+	assert.NoError(t, pipe.Process(newTestRequest("/crawler")))
+	pipe.Stop()
+	assert.Len(t, storage.Requests(), 2)
+	assert.Len(t, storage.PageViews(), 1)
+	crawlers := storage.Crawlers()
+	assert.Len(t, crawlers, 1)
+	assert.Equal(t, "example.com", crawlers[0].Hostname)
+	assert.Equal(t, "/crawler", crawlers[0].Path)
+	assert.Equal(t, "GPTBot", crawlers[0].Crawler)
+	assert.Equal(t, "ai", crawlers[0].Category)
+	assert.Equal(t, "verified", crawlers[0].Status)
+	assert.False(t, crawlers[0].Time.IsZero())
+}
+
+func TestPipeCrawlersNotSupported(t *testing.T) {
+	// the crawler requests must be discarded if the storage doesn't implement db.CrawlerStorage
+	mock := db.NewMock()
+	pipe := NewPipe(PipeOptions{
+		Storage: struct{ db.Storage }{mock},
+		Worker:  1,
+	}).Use(&crawlerStep{}, &sessionStep{})
+	assert.NoError(t, pipe.Process(newTestRequest("/crawler")))
+	pipe.Stop()
+	assert.Len(t, mock.Requests(), 1)
+	assert.Empty(t, mock.Crawlers())
+	assert.Zero(t, pipe.Stats().FlushErrors)
+}
+
+func TestPrepare(t *testing.T) {
+	req := newTestRequest("/")
+	cancel, err := Prepare(req, &sessionStep{})
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	assert.False(t, req.Cancelled())
+	assert.NotNil(t, req.Session)
+	assert.Equal(t, "example.com", req.Hostname)
+	req = newTestRequest("/bot")
+	cancel, err = Prepare(req, &botStep{}, &sessionStep{})
+	assert.True(t, cancel)
+	assert.NoError(t, err)
+	assert.True(t, req.Cancelled())
+	assert.Nil(t, req.Session)
+	req = newTestRequest("/")
+	req.Request.Header.Set("Purpose", "prefetch")
+	cancel, err = Prepare(req, &sessionStep{})
+	assert.True(t, cancel)
+	assert.NoError(t, err)
+	assert.False(t, req.Cancelled())
+}
+
+func newTestRequest(path string) *Request {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com"+path, nil)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/146.0.0.0 Safari/537.36")
+	return &Request{
+		Request: req,
+	}
+}
+
 type sessionStep struct{}
 
 func (s *sessionStep) Step(request *Request) (bool, error) {
@@ -304,6 +668,48 @@ func (s *sessionStep) Step(request *Request) (bool, error) {
 	return false, nil
 }
 
+type versionedSessionStep struct {
+	session *model.Session
+}
+
+func (s *versionedSessionStep) Step(request *Request) (bool, error) {
+	if s.session == nil {
+		s.session = &model.Session{Sign: 1, Version: 1, PageViews: 1}
+	} else {
+		cancel := *s.session
+		cancel.Sign = -1
+		request.CancelSession = &cancel
+		s.session = &model.Session{Sign: 1, Version: s.session.Version + 1, PageViews: s.session.PageViews + 1}
+	}
+
+	session := *s.session
+	request.Session = &session
+	return false, nil
+}
+
+type cancelSessionStep struct{}
+
+func (s *cancelSessionStep) Step(request *Request) (bool, error) {
+	request.Session = &model.Session{Sign: 1}
+	request.CancelSession = &model.Session{Sign: -1}
+	return false, nil
+}
+
+type crawlerStep struct{}
+
+func (s *crawlerStep) Step(request *Request) (bool, error) {
+	if request.Path == "/crawler" {
+		request.Crawler = "GPTBot"
+		request.CrawlerCategory = "ai"
+		request.CrawlerVerified = true
+		request.IsBot = true
+		request.BotReason = "crawler"
+		return true, nil
+	}
+
+	return false, nil
+}
+
 type storageWithError struct {
 	db.Mock
 	errorOnSave error
@@ -344,3 +750,39 @@ func (client *storageWithError) setErrorOnSave(err error) {
 	defer client.m.Unlock()
 	client.errorOnSave = err
 }
+
+type blockingStorage struct {
+	db.Mock
+	block chan struct{}
+}
+
+func newBlockingStorage() *blockingStorage {
+	return &blockingStorage{
+		Mock:  *db.NewMock(),
+		block: make(chan struct{}),
+	}
+}
+
+func (client *blockingStorage) SavePageViews(ctx context.Context, pageViews []model.PageView) error {
+	<-client.block
+	return client.Mock.SavePageViews(ctx, pageViews)
+}
+
+func (client *blockingStorage) SaveSessions(ctx context.Context, sessions []model.Session) error {
+	<-client.block
+	return client.Mock.SaveSessions(ctx, sessions)
+}
+
+func (client *blockingStorage) SaveEvents(ctx context.Context, events []model.Event) error {
+	<-client.block
+	return client.Mock.SaveEvents(ctx, events)
+}
+
+func (client *blockingStorage) SaveRequests(ctx context.Context, requests []model.Request) error {
+	<-client.block
+	return client.Mock.SaveRequests(ctx, requests)
+}
+
+func (client *blockingStorage) release() {
+	close(client.block)
+}
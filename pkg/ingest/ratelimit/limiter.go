@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// Limit is the configuration for a token bucket.
+// The bucket holds up to Burst tokens and is refilled by Requests tokens per Interval.
+type Limit struct {
+	// Requests is the number of requests allowed per Interval.
+	Requests int
+
+	// Interval is the interval in which the bucket is refilled by Requests tokens.
+	// If set to <= 0, it defaults to one minute.
+	Interval time.Duration
+
+	// Burst is the maximum number of requests allowed at once.
+	// If set to <= 0, it defaults to Requests.
+	Burst int
+}
+
+func (limit *Limit) validate() {
+	if limit.Interval <= 0 {
+		limit.Interval = time.Minute
+	}
+
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Requests
+	}
+}
+
+// rate returns the number of tokens added per second.
+func (limit Limit) rate() float64 {
+	return float64(limit.Requests) / limit.Interval.Seconds()
+}
+
+// ttl returns the time it takes to refill an empty bucket.
+func (limit Limit) ttl() time.Duration {
+	return time.Duration(float64(limit.Burst) / limit.rate() * float64(time.Second))
+}
+
+// Limiter is a token bucket rate limiter.
+type Limiter interface {
+	// Allow takes a token from the bucket for given key and returns true if the request is allowed.
+	Allow(string, Limit, time.Time) bool
+}
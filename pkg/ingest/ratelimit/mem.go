@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	memCleanupInterval = time.Minute
+)
+
+type bucket struct {
+	tokens  float64
+	last    time.Time
+	expires time.Time
+}
+
+// MemLimiter implements the Limiter interface using in-memory token buckets.
+// This does only make sense for non-distributed systems (tracking on a single machine/app).
+type MemLimiter struct {
+	buckets     map[string]bucket
+	lastCleanup time.Time
+	m           sync.Mutex
+}
+
+// NewMemLimiter creates a new in-memory Limiter.
+func NewMemLimiter() *MemLimiter {
+	return &MemLimiter{
+		buckets: make(map[string]bucket),
+	}
+}
+
+// Allow implements the Limiter interface.
+func (limiter *MemLimiter) Allow(key string, limit Limit, now time.Time) bool {
+	limiter.m.Lock()
+	defer limiter.m.Unlock()
+
+	if now.Sub(limiter.lastCleanup) > memCleanupInterval {
+		limiter.removeExpired(now)
+	}
+
+	b, found := limiter.buckets[key]
+
+	if !found {
+		b = bucket{
+			tokens: float64(limit.Burst),
+			last:   now,
+		}
+	}
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(float64(limit.Burst), b.tokens+elapsed.Seconds()*limit.rate())
+		b.last = now
+	}
+
+	allow := b.tokens >= 1
+
+	if allow {
+		b.tokens--
+	}
+
+	// the bucket can be dropped once it has been refilled completely
+	b.expires = b.last.Add(limit.ttl())
+	limiter.buckets[key] = b
+	return allow
+}
+
+// Len returns the number of buckets kept in memory.
+func (limiter *MemLimiter) Len() int {
+	limiter.m.Lock()
+	defer limiter.m.Unlock()
+	return len(limiter.buckets)
+}
+
+func (limiter *MemLimiter) removeExpired(now time.Time) {
+	for key, b := range limiter.buckets {
+		if now.After(b.expires) {
+			delete(limiter.buckets, key)
+		}
+	}
+
+	limiter.lastCleanup = now
+}
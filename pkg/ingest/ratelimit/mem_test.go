@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemLimiter(t *testing.T) {
+	limiter := NewMemLimiter()
+	limit := Limit{Requests: 60, Burst: 3}
+	limit.validate()
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+	// the burst is allowed immediately
+	for range 3 {
+		assert.True(t, limiter.Allow("key", limit, now))
+	}
+
+	assert.False(t, limiter.Allow("key", limit, now))
+	assert.True(t, limiter.Allow("other", limit, now))
+
+	// one token is added per second
+	assert.False(t, limiter.Allow("key", limit, now.Add(time.Millisecond*500)))
+	assert.True(t, limiter.Allow("key", limit, now.Add(time.Second)))
+	assert.False(t, limiter.Allow("key", limit, now.Add(time.Second)))
+
+	// the bucket is never filled above the burst
+	now = now.Add(time.Hour)
+
+	for range 3 {
+		assert.True(t, limiter.Allow("key", limit, now))
+	}
+
+	assert.False(t, limiter.Allow("key", limit, now))
+}
+
+func TestMemLimiterRemoveExpired(t *testing.T) {
+	limiter := NewMemLimiter()
+	limit := Limit{Requests: 60}
+	limit.validate()
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+	assert.True(t, limiter.Allow("a", limit, now))
+	assert.True(t, limiter.Allow("b", limit, now))
+	assert.Equal(t, 2, limiter.Len())
+	assert.True(t, limiter.Allow("c", limit, now.Add(time.Minute*2)))
+	assert.Equal(t, 1, limiter.Len())
+}
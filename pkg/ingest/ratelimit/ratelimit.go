@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dchest/siphash"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/session"
+)
+
+const (
+	defaultPageViewsPerMinute = 60
+	defaultEventsPerMinute    = 120
+
+	// saltMaxAge is the maximum session max age, so that the salts are kept for at least as long as by the session.Session step.
+	saltMaxAge = time.Hour * 24
+)
+
+// Options is the configuration for the RateLimit step.
+type Options struct {
+	// Limiter is the Limiter used to store the token buckets.
+	// If not set, a new MemLimiter will be used.
+	Limiter Limiter
+
+	// PageViews is the limit for page views per site and visitor.
+	// If Requests is set to 0, the default of 60 page views per minute will be used. Set it to < 0 to disable the limit.
+	PageViews Limit
+
+	// Events is the limit for events per site and visitor.
+	// If Requests is set to 0, the default of 120 events per minute will be used. Set it to < 0 to disable the limit.
+	Events Limit
+
+	// IPPageViews is an optional limit for page views per site and IP.
+	// It should be higher than the PageViews limit, as visitors can share the same IP.
+	// If Requests is set to <= 0, page views won't be limited by IP.
+	IPPageViews Limit
+
+	// IPEvents is an optional limit for events per site and IP.
+	// It should be higher than the Events limit, as visitors can share the same IP.
+	// If Requests is set to <= 0, events won't be limited by IP.
+	IPEvents Limit
+
+	// FPKey0 and FPKey1 are the sipHash keys used to hash the fingerprint and IP for the keys of the token buckets,
+	// so that no IP is stored in the Limiter. They should be the same as for the session.Session step.
+	FPKey0, FPKey1 uint64
+
+	// FPSalt is the salt added to the hashes.
+	FPSalt string
+
+	// Salts optionally adds the daily rotating salt per site to the hashes (see session.Session RotateSalts).
+	Salts *session.SaltManager
+}
+
+func (options *Options) validate() {
+	if options.Limiter == nil {
+		options.Limiter = NewMemLimiter()
+	}
+
+	if options.PageViews.Requests == 0 {
+		options.PageViews.Requests = defaultPageViewsPerMinute
+	}
+
+	if options.Events.Requests == 0 {
+		options.Events.Requests = defaultEventsPerMinute
+	}
+
+	options.PageViews.validate()
+	options.Events.validate()
+	options.IPPageViews.validate()
+	options.IPEvents.validate()
+}
+
+// RateLimit limits the number of page views and events per site and visitor using token buckets.
+// The visitor is identified by a fingerprint of the User-Agent and IP and optionally by the IP alone.
+// Both are hashed using sipHash like the session.Session step.
+// Requests exceeding the limit are cancelled with the BotReason "rate-limit".
+// This step operates on the ingest.Request IP, which must be set before this step is run.
+// It must run before the session.Session step, as the session cache would otherwise be updated by cancelled requests.
+type RateLimit struct {
+	limiter     Limiter
+	pageViews   Limit
+	events      Limit
+	ipPageViews Limit
+	ipEvents    Limit
+	fpKey0      uint64
+	fpKey1      uint64
+	fpSalt      string
+	salts       *session.SaltManager
+}
+
+// NewRateLimit creates a new RateLimit for given options.
+func NewRateLimit(options Options) *RateLimit {
+	options.validate()
+	return &RateLimit{
+		limiter:     options.Limiter,
+		pageViews:   options.PageViews,
+		events:      options.Events,
+		ipPageViews: options.IPPageViews,
+		ipEvents:    options.IPEvents,
+		fpKey0:      options.FPKey0,
+		fpKey1:      options.FPKey1,
+		fpSalt:      options.FPSalt,
+		salts:       options.Salts,
+	}
+}
+
+// Step implements ingest.PipeStep to process a step.
+func (rl *RateLimit) Step(request *ingest.Request) (bool, error) {
+	if request.DisableBotFilter {
+		return false, nil
+	}
+
+	kind, limit, ipLimit := "pv", rl.pageViews, rl.ipPageViews
+
+	if request.EventName != "" {
+		kind, limit, ipLimit = "ev", rl.events, rl.ipEvents
+	}
+
+	var salt string
+
+	if rl.salts != nil && (limit.Requests > 0 || ipLimit.Requests > 0) {
+		var err error
+		salt, err = rl.salts.Salt(request.SiteID, request.Time, saltMaxAge)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if limit.Requests > 0 {
+		key := fmt.Sprintf("%s_%d_%d", kind, request.SiteID, rl.hash(request.Request.UserAgent(), request.IP, salt))
+
+		if !rl.limiter.Allow(key, limit, request.Time) {
+			request.BotReason = "rate-limit"
+			return true, nil
+		}
+	}
+
+	if ipLimit.Requests > 0 && request.IP != "" {
+		key := fmt.Sprintf("%s_ip_%d_%d", kind, request.SiteID, rl.hash(request.IP, salt))
+
+		if !rl.limiter.Allow(key, ipLimit, request.Time) {
+			request.BotReason = "rate-limit"
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (rl *RateLimit) hash(parts ...string) uint64 {
+	var sb strings.Builder
+
+	for _, part := range parts {
+		sb.WriteString(part)
+		sb.WriteByte(0)
+	}
+
+	sb.WriteString(rl.fpSalt)
+	return siphash.Hash(rl.fpKey0, rl.fpKey1, []byte(sb.String()))
+}
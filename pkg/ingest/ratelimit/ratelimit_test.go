@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/session"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	rl := NewRateLimit(Options{
+		PageViews: Limit{Requests: 2},
+		Events:    Limit{Requests: 3},
+	})
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+	// page views and events are limited separately
+	for i := range 3 {
+		req := newRequest("81.2.69.142", "", now)
+		cancel, err := rl.Step(req)
+		assert.NoError(t, err)
+		assert.Equal(t, i == 2, cancel)
+		assert.Equal(t, i == 2, req.BotReason == "rate-limit")
+	}
+
+	for i := range 4 {
+		cancel, err := rl.Step(newRequest("81.2.69.142", "event", now))
+		assert.NoError(t, err)
+		assert.Equal(t, i == 3, cancel)
+	}
+
+	// other visitors and sites are not affected
+	cancel, err := rl.Step(newRequest("81.2.69.143", "", now))
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	req := newRequest("81.2.69.142", "", now)
+	req.SiteID = 2
+	cancel, err = rl.Step(req)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+
+	// the limit resets over time
+	cancel, err = rl.Step(newRequest("81.2.69.142", "", now.Add(time.Minute)))
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+
+	// the bot filter can be disabled
+	req = newRequest("81.2.69.142", "", now.Add(time.Minute))
+	req.DisableBotFilter = true
+	cancel, err = rl.Step(req)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+}
+
+func TestRateLimitIP(t *testing.T) {
+	rl := NewRateLimit(Options{
+		PageViews:   Limit{Requests: 5},
+		Events:      Limit{Requests: -1},
+		IPPageViews: Limit{Requests: 3},
+	})
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+	// different User-Agents from the same IP
+	for i, userAgent := range []string{"a", "b", "c", "d"} {
+		req := newRequest("81.2.69.142", "", now)
+		req.Request.Header.Set("User-Agent", userAgent)
+		cancel, err := rl.Step(req)
+		assert.NoError(t, err)
+		assert.Equal(t, i == 3, cancel)
+	}
+
+	// events are not limited
+	for range 10 {
+		cancel, err := rl.Step(newRequest("81.2.69.142", "event", now))
+		assert.NoError(t, err)
+		assert.False(t, cancel)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	limiter := &keyLimiter{}
+	rl := NewRateLimit(Options{
+		Limiter:     limiter,
+		IPPageViews: Limit{Requests: 10},
+		FPKey0:      1,
+		FPKey1:      2,
+		Salts:       session.NewSaltManager(&saltStore{}),
+	})
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+	_, err := rl.Step(newRequest("81.2.69.142", "", now))
+	assert.NoError(t, err)
+	_, err = rl.Step(newRequest("81.2.69.142", "", now.Add(time.Hour*24)))
+	assert.NoError(t, err)
+
+	// the IP must not be stored and the keys must change with the salt
+	assert.Len(t, limiter.keys, 4)
+
+	for _, key := range limiter.keys {
+		assert.NotContains(t, key, "81.2.69.142")
+	}
+
+	assert.NotEqual(t, limiter.keys[0], limiter.keys[2])
+	assert.NotEqual(t, limiter.keys[1], limiter.keys[3])
+}
+
+func TestRateLimitDefaults(t *testing.T) {
+	rl := NewRateLimit(Options{})
+	assert.Equal(t, 60, rl.pageViews.Requests)
+	assert.Equal(t, 60, rl.pageViews.Burst)
+	assert.Equal(t, time.Minute, rl.pageViews.Interval)
+	assert.Equal(t, 120, rl.events.Requests)
+	assert.Zero(t, rl.ipPageViews.Requests)
+	assert.Zero(t, rl.ipEvents.Requests)
+	assert.IsType(t, new(MemLimiter), rl.limiter)
+}
+
+type keyLimiter struct {
+	keys []string
+}
+
+func (limiter *keyLimiter) Allow(key string, _ Limit, _ time.Time) bool {
+	limiter.keys = append(limiter.keys, key)
+	return true
+}
+
+type saltStore struct{}
+
+func (store *saltStore) Salt(_ uint64, _ time.Time, salt string, _ time.Time) (string, error) {
+	return salt, nil
+}
+
+func newRequest(ip, eventName string, now time.Time) *ingest.Request {
+	r, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/146.0.0.0 Safari/537.36")
+	return &ingest.Request{
+		SiteID:    1,
+		Request:   r,
+		IP:        ip,
+		EventName: eventName,
+		Time:      now,
+	}
+}
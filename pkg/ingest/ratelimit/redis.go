@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultRedisKeyPrefix = "ratelimit_"
+)
+
+// RedisLimiterOptions is the configuration for the RedisLimiter.
+type RedisLimiterOptions struct {
+	// Redis are the connection options.
+	// Depending on the options, this connects to a single node, a Redis Cluster, or uses Sentinel for failover.
+	// If not set, it will connect to localhost.
+	Redis *redis.UniversalOptions
+
+	// KeyPrefix is the prefix for all keys stored by the limiter.
+	// Clear only deletes keys with this prefix. The default is "ratelimit_".
+	KeyPrefix string
+
+	// Logger is the log/slog.Logger used to log errors.
+	Logger *slog.Logger
+}
+
+func (options *RedisLimiterOptions) validate() {
+	if options.Redis == nil {
+		options.Redis = new(redis.UniversalOptions)
+	}
+
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = defaultRedisKeyPrefix
+	}
+
+	if options.Logger == nil {
+		options.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+}
+
+// tokenBucket atomically refills and takes a token from the bucket stored as a hash.
+// KEYS[1] is the bucket key, ARGV is the rate per millisecond, burst, current time in milliseconds, and TTL in milliseconds.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(bucket[1])
+local last = tonumber(bucket[2])
+
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) * rate)
+	last = now
+end
+
+local allow = 0
+
+if tokens >= 1 then
+	tokens = tokens - 1
+	allow = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(last))
+redis.call("PEXPIRE", KEYS[1], ttl)
+return allow
+`)
+
+// RedisLimiter implements the Limiter interface using token buckets stored in Redis.
+// Requests are allowed if Redis cannot be reached, so that tracking won't stop working.
+type RedisLimiter struct {
+	rds    redis.UniversalClient
+	prefix string
+	logger *slog.Logger
+}
+
+// NewRedisLimiter creates a new Limiter for given options.
+// The options can be used for a single node, Redis Cluster, or Sentinel (see redis.NewUniversalClient).
+func NewRedisLimiter(options RedisLimiterOptions) *RedisLimiter {
+	options.validate()
+	return &RedisLimiter{
+		rds:    redis.NewUniversalClient(options.Redis),
+		prefix: options.KeyPrefix,
+		logger: options.Logger,
+	}
+}
+
+// Allow implements the Limiter interface.
+func (limiter *RedisLimiter) Allow(key string, limit Limit, now time.Time) bool {
+	ttl := limit.ttl() + time.Second
+	allow, err := tokenBucket.Run(context.Background(), limiter.rds, []string{limiter.prefix + key},
+		limit.rate()/1000,
+		limit.Burst,
+		now.UnixMilli(),
+		ttl.Milliseconds()).Int()
+
+	if err != nil {
+		limiter.logger.Error("error reading rate limit from cache", "err", err)
+		return true
+	}
+
+	return allow == 1
+}
+
+// Clear removes all token buckets.
+func (limiter *RedisLimiter) Clear() {
+	ctx := context.Background()
+	var err error
+
+	// each node of a cluster must be scanned separately
+	if cluster, ok := limiter.rds.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return limiter.clear(ctx, client)
+		})
+	} else {
+		err = limiter.clear(ctx, limiter.rds)
+	}
+
+	if err != nil {
+		limiter.logger.Error("error clearing rate limits", "err", err)
+	}
+}
+
+// Close closes the connection to Redis.
+func (limiter *RedisLimiter) Close() error {
+	return limiter.rds.Close()
+}
+
+func (limiter *RedisLimiter) clear(ctx context.Context, client redis.UniversalClient) error {
+	iter := client.Scan(ctx, 0, limiter.prefix+"*", 0).Iterator()
+
+	for iter.Next(ctx) {
+		if err := client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisLimiter(t *testing.T) {
+	limiter := NewRedisLimiter(RedisLimiterOptions{
+		Redis: &redis.UniversalOptions{
+			Addrs: []string{"localhost:6379"},
+		},
+	})
+	limiter.Clear()
+	limit := Limit{Requests: 60, Burst: 3}
+	limit.validate()
+	now := time.Now()
+
+	for range 3 {
+		assert.True(t, limiter.Allow("key", limit, now))
+	}
+
+	assert.False(t, limiter.Allow("key", limit, now))
+	assert.True(t, limiter.Allow("other", limit, now))
+	assert.True(t, limiter.Allow("key", limit, now.Add(time.Second)))
+	assert.False(t, limiter.Allow("key", limit, now.Add(time.Second)))
+	limiter.Clear()
+	assert.True(t, limiter.Allow("key", limit, now.Add(time.Second)))
+}
@@ -22,6 +22,21 @@ func (f *BotFilter) Step(request *ingest.Request) (bool, error) {
 		return false, nil
 	}
 
+	if reasons := f.botReasons(request, false); len(reasons) > 0 {
+		request.BotReason = reasons[0]
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// BotReasons implements the ingest.BotDetector interface.
+func (f *BotFilter) BotReasons(request *ingest.Request) []string {
+	return f.botReasons(request, true)
+}
+
+func (f *BotFilter) botReasons(request *ingest.Request, all bool) []string {
+	reasons := make([]string, 0)
 	referrer := ""
 
 	if request.Referrer != "" {
@@ -31,12 +46,15 @@ func (f *BotFilter) Step(request *ingest.Request) (bool, error) {
 	}
 
 	if referrer == "" {
-		return false, nil
+		return reasons
 	}
 
 	if _, err := uuid.Parse(referrer); err == nil {
-		request.BotReason = "ref-uuid"
-		return true, nil
+		reasons = append(reasons, "ref-uuid")
+
+		if !all {
+			return reasons
+		}
 	}
 
 	u, err := url.ParseRequestURI(referrer)
@@ -49,8 +67,11 @@ func (f *BotFilter) Step(request *ingest.Request) (bool, error) {
 	_, found := HostnameBlacklist[referrer]
 
 	if found {
-		request.BotReason = "ref-blacklist"
-		return true, nil
+		reasons = append(reasons, "ref-blacklist")
+
+		if !all {
+			return reasons
+		}
 	}
 
 	// filter for bot keywords
@@ -58,12 +79,12 @@ func (f *BotFilter) Step(request *ingest.Request) (bool, error) {
 
 	for _, botReferrer := range referrerBlacklist {
 		if strings.Contains(referrer, botReferrer) {
-			request.BotReason = "ref-keyword"
-			return true, nil
+			reasons = append(reasons, "ref-keyword")
+			break
 		}
 	}
 
-	return false, nil
+	return reasons
 }
 
 func (f *BotFilter) stripSubdomain(hostname string) string {
@@ -116,3 +116,17 @@ func TestBotFilterAcknowledge(t *testing.T) {
 
 	assert.Empty(t, ignored)
 }
+
+func TestBotFilterBotReasons(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	req.Header.Set("Referer", "https://semalt.com/")
+	r := &ingest.Request{
+		Request: req,
+	}
+	assert.Equal(t, []string{"ref-blacklist"}, NewBotFilter().BotReasons(r))
+	assert.Empty(t, r.BotReason)
+	req.Header.Set("Referer", "-1' OR 3*2>(0+5+94-94) --")
+	assert.Equal(t, []string{"ref-keyword"}, NewBotFilter().BotReasons(r))
+	req.Header.Set("Referer", "https://google.com/")
+	assert.Empty(t, NewBotFilter().BotReasons(r))
+}
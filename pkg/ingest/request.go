@@ -123,6 +123,24 @@ type Request struct {
 	// SessionID is the session ID for the request.
 	SessionID uint32
 
+	// ClientID is an optional, client-provided visitor identifier (like a first-party cookie or app install ID).
+	// If set, it replaces the daily fingerprint for the VisitorID, so that visitors can be recognized across days.
+	// This must only be set if the visitor has consented to it.
+	ClientID string
+
+	// IsReturning is set to true if the visitor has been seen before.
+	// This can only be determined if the ClientID is set.
+	IsReturning bool
+
+	// User is an optional identifier for the authenticated user (like an account ID).
+	// It is hashed to the UserID and used to connect sessions of the same user across devices.
+	// This must only be set if the user has consented to it.
+	User string
+
+	// UserID is the hashed User.
+	// This will be set by the session step.
+	UserID uint64
+
 	// Request is the http.Request for the visitor.
 	// This field is mandatory.
 	Request *http.Request
@@ -198,6 +216,26 @@ type Request struct {
 	// This should be set by a PipeStep.
 	City string
 
+	// ContinentCode is the continent code for the request (like "EU").
+	// This should be set by a PipeStep.
+	ContinentCode string
+
+	// TimeZone is the IANA time zone for the request (like "Europe/London").
+	// This should be set by a PipeStep.
+	TimeZone string
+
+	// Latitude is the coarse latitude for the request.
+	// This should be set by a PipeStep.
+	Latitude float32
+
+	// Longitude is the coarse longitude for the request.
+	// This should be set by a PipeStep.
+	Longitude float32
+
+	// InEU is set to true if the request is from a member state of the European Union.
+	// This should be set by a PipeStep.
+	InEU bool
+
 	// ReferrerName is the referrer name (group) for the request.
 	// This should be set by a PipeStep.
 	ReferrerName string
@@ -238,6 +276,14 @@ type Request struct {
 	// This should be set by a PipeStep.
 	IP string
 
+	// ASN is the autonomous system number for the request.
+	// This should be set by a PipeStep.
+	ASN uint32
+
+	// ASNOrganization is the autonomous system organization for the request.
+	// This should be set by a PipeStep.
+	ASNOrganization string
+
 	// UserAgent is the User-Agent for the request.
 	// This should be set by a PipeStep.
 	UserAgent string
@@ -281,6 +327,28 @@ type Request struct {
 	// This should be set by a PipeStep.
 	BotReason string
 
+	// Crawler is the name of the search engine or AI crawler for the request.
+	// This should be set by a PipeStep.
+	Crawler string
+
+	// CrawlerCategory is the category of the Crawler (like "search" or "ai").
+	// This should be set by a PipeStep.
+	CrawlerCategory string
+
+	// CrawlerVerified is set to true if the Crawler has been verified by its IP.
+	// This should be set by a PipeStep.
+	CrawlerVerified bool
+
+	// BotScore is the score calculated for the request by the BotScore step.
+	BotScore float64
+
+	// BotReasons is the list of all bot reasons triggered for the request by the BotScore step.
+	BotReasons []string
+
+	// ShadowBotReason is the reason why a request would have been blocked for a bot by a step running in shadow mode.
+	// This is set by the Shadow step.
+	ShadowBotReason string
+
 	// DurationSeconds is the session duration or time on page, usually set in a step.
 	DurationSeconds uint32
 
@@ -293,6 +361,11 @@ type Request struct {
 	// CancelSession is the previous session state, usually set in a step.
 	CancelSession *model.Session
 
+	// RetractSession stores the CancelSession even if the request has been cancelled,
+	// so that a step can remove the session retroactively (like the session.Behavior step).
+	// Otherwise, nothing session related is stored for cancelled requests.
+	RetractSession bool
+
 	cancelled bool
 }
 
@@ -352,3 +425,141 @@ func (request *Request) validate() {
 		}
 	}
 }
+
+// Cancelled returns true if the request has been cancelled by a PipeStep.
+func (request *Request) Cancelled() bool {
+	return request.cancelled
+}
+
+// RequestLog returns the model.Request to log the request.
+func (request *Request) RequestLog() model.Request {
+	return model.Request{
+		SiteID:          request.SiteID,
+		VisitorID:       request.VisitorID,
+		Time:            request.Time,
+		Hostname:        request.Hostname,
+		Path:            request.Path,
+		Query:           request.Query,
+		IP:              request.IP,
+		ASN:             request.ASN,
+		ASNOrganization: request.ASNOrganization,
+		UserAgent:       request.UserAgent,
+		Headers:         request.Headers,
+		EventName:       request.EventName,
+		Referrer:        request.Referrer,
+		UTMSource:       request.UTMSource,
+		UTMMedium:       request.UTMMedium,
+		UTMCampaign:     request.UTMCampaign,
+		UTMContent:      request.UTMContent,
+		UTMTerm:         request.UTMTerm,
+		Bot:             request.IsBot,
+		BotReason:       request.BotReason,
+		Crawler:         request.Crawler,
+		CrawlerVerified: request.CrawlerVerified,
+		BotScore:        request.BotScore,
+		BotReasons:      request.BotReasons,
+		ShadowBotReason: request.ShadowBotReason,
+	}
+}
+
+// CrawlerLog returns the model.Crawler to log a crawler request.
+// The status is either "verified", "spoofed", or "unverified".
+func (request *Request) CrawlerLog() model.Crawler {
+	status := "unverified"
+
+	if request.CrawlerVerified {
+		status = "verified"
+	} else if request.BotReason == "crawler-spoofed" {
+		status = "spoofed"
+	}
+
+	return model.Crawler{
+		SiteID:   request.SiteID,
+		Time:     request.Time,
+		Hostname: request.Hostname,
+		Path:     request.Path,
+		Crawler:  request.Crawler,
+		Category: request.CrawlerCategory,
+		Status:   status,
+	}
+}
+
+// PageView returns the model.PageView for the request.
+func (request *Request) PageView() model.PageView {
+	return model.PageView{
+		Data:            request.data(),
+		DurationSeconds: request.DurationSeconds,
+		Path:            request.Path,
+		Title:           request.Title,
+		Tags:            request.Tags,
+	}
+}
+
+// Event returns the model.Event for the request.
+func (request *Request) Event() model.Event {
+	return model.Event{
+		Data:     request.data(),
+		Name:     request.EventName,
+		MetaData: request.EventMetaData,
+		Path:     request.Path,
+		Title:    request.Title,
+	}
+}
+
+func (request *Request) data() model.Data {
+	return model.Data{
+		SiteID:         request.SiteID,
+		VisitorID:      request.VisitorID,
+		SessionID:      request.SessionID,
+		IsReturning:    request.IsReturning,
+		UserID:         request.UserID,
+		Time:           request.Time,
+		Hostname:       request.Hostname,
+		Language:       request.Language,
+		CountryCode:    request.CountryCode,
+		Region:         request.Region,
+		City:           request.City,
+		ContinentCode:  request.ContinentCode,
+		TimeZone:       request.TimeZone,
+		Latitude:       request.Latitude,
+		Longitude:      request.Longitude,
+		InEU:           request.InEU,
+		Referrer:       request.Referrer,
+		ReferrerName:   request.ReferrerName,
+		ReferrerIcon:   request.ReferrerIcon,
+		OS:             request.OS,
+		OSVersion:      request.OSVersion,
+		Browser:        request.Browser,
+		BrowserVersion: request.BrowserVersion,
+		Platform:       request.Platform,
+		ScreenClass:    request.ScreenClass,
+		UTMSource:      request.UTMSource,
+		UTMMedium:      request.UTMMedium,
+		UTMCampaign:    request.UTMCampaign,
+		UTMContent:     request.UTMContent,
+		UTMTerm:        request.UTMTerm,
+		Channel:        request.Channel,
+	}
+}
+
+func ignore(request *Request) bool {
+	// ignore requests with missing http.Request attributes
+	if request.Request == nil {
+		return true
+	}
+
+	// ignore browsers pre-fetching data
+	xMoz := strings.ToLower(request.Request.Header.Get("X-Moz"))
+	xPurpose := strings.ToLower(request.Request.Header.Get("X-Purpose"))
+	purpose := strings.ToLower(request.Request.Header.Get("Purpose"))
+
+	if xMoz == "prefetch" ||
+		xPurpose == "prefetch" ||
+		xPurpose == "preview" ||
+		purpose == "prefetch" ||
+		purpose == "preview" {
+		return true
+	}
+
+	return false
+}
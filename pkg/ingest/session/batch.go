@@ -0,0 +1,339 @@
+package session
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+)
+
+const (
+	defaultBatchSaveSize = 10_000
+)
+
+type batchVisitor struct {
+	siteID      uint64
+	fingerprint uint64
+}
+
+// batchSession is the state of a visitor carried across calls to Flush.
+type batchSession struct {
+	session   *model.Session
+	returning bool
+}
+
+// batchData is the data reconstructed by Flush that has not been saved yet.
+type batchData struct {
+	sessions  []model.Session
+	pageViews []model.PageView
+	events    []model.Event
+	requests  []model.Request
+}
+
+// Batch reconstructs sessions offline to import historical data.
+// In contrast to Session, which is used as the last step of an ingest.Pipe and relies on requests arriving in order,
+// Batch collects all requests first, sorts them per visitor, and writes the final sessions directly to the db.Storage.
+// This skips the Cache and the cancelled (sign -1) session rows, which makes imports correct and fast.
+// All requests are kept in memory until Flush is called, so large imports should be flushed regularly.
+// Sessions that might still continue are kept open across calls to Flush and are saved once they have ended,
+// so requests must be added in chronological order across calls to Flush. FlushAll must be called once all requests have been added.
+type Batch struct {
+	session   *Session
+	steps     []ingest.PipeStep
+	storage   db.Storage
+	saveSize  int
+	logIP     bool
+	visitors  map[batchVisitor][]*ingest.Request
+	open      map[batchVisitor]*batchSession
+	watermark time.Time
+	bots      []model.Request
+	pending   batchData
+	m         sync.Mutex
+	flushLock sync.Mutex
+}
+
+// BatchResult is the summary of a Batch Flush.
+type BatchResult struct {
+	// Sessions is the number of sessions saved.
+	Sessions int
+
+	// PageViews is the number of page views saved.
+	PageViews int
+
+	// Events is the number of events saved.
+	Events int
+
+	// Requests is the number of requests saved (including bots).
+	Requests int
+
+	// DroppedUpdates is the number of session updates (UpdateSession) dropped, because there was no session to update.
+	DroppedUpdates int
+}
+
+// NewBatch returns a new Batch for the given sipHash parameters, storage, and options.
+// The parameters must be the same as for the Session step to generate the same visitor IDs and sessions.
+func NewBatch(fpKey0, fpKey1 uint64, fpSalt string, storage db.Storage, maxPageViews uint16, rules RulesLookup) *Batch {
+	return &Batch{
+		session:  NewSession(fpKey0, fpKey1, fpSalt, nil, maxPageViews, rules),
+		storage:  storage,
+		saveSize: defaultBatchSaveSize,
+		visitors: make(map[batchVisitor][]*ingest.Request),
+		open:     make(map[batchVisitor]*batchSession),
+	}
+}
+
+// Use adds processing steps run for each request when it's added to the Batch.
+// The Session step must not be added, as the Batch replaces it.
+func (b *Batch) Use(f ...ingest.PipeStep) *Batch {
+	b.steps = append(b.steps, f...)
+	return b
+}
+
+// LogIP will log the request IP in the db.Storage if set to true.
+func (b *Batch) LogIP(logIP bool) *Batch {
+	b.logIP = logIP
+	return b
+}
+
+// Add processes the given request through the steps and adds it to the Batch.
+// It returns true if the request has been ignored or cancelled by a step (like a bot filter).
+func (b *Batch) Add(request *ingest.Request) (bool, error) {
+	cancel, err := ingest.Prepare(request, b.steps...)
+
+	if err != nil {
+		return false, err
+	}
+
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if cancel {
+		// keep cancelled requests for bot analysis, like the ingest.Pipe does
+		if request.Cancelled() {
+			b.bots = append(b.bots, b.requestLog(request))
+		}
+
+		return true, nil
+	}
+
+	// the fingerprint without date (or the client ID) groups requests of a visitor across days
+	visitor := batchVisitor{
+		siteID:      request.SiteID,
+		fingerprint: b.session.visitorID(request, "", time.Time{}),
+	}
+
+	// the http.Request is no longer required and would only consume memory
+	request.Request = nil
+	b.visitors[visitor] = append(b.visitors[visitor], request)
+
+	if request.Time.After(b.watermark) {
+		b.watermark = request.Time
+	}
+
+	return false, nil
+}
+
+// Len returns the number of requests in the Batch, including requests that could not be saved by Flush yet.
+func (b *Batch) Len() int {
+	b.m.Lock()
+	defer b.m.Unlock()
+	n := len(b.bots) + len(b.pending.requests)
+
+	for _, requests := range b.visitors {
+		n += len(requests)
+	}
+
+	return n
+}
+
+// Flush reconstructs the sessions for all requests in the Batch and saves them to the db.Storage.
+// Sessions are only saved once they have ended relative to the latest request added to the Batch,
+// open sessions are continued by the requests added before the next call to Flush.
+// If saving fails, the data that has not been saved yet is kept and saved first by the next call to Flush.
+// The BatchResult contains the number of rows saved by this call.
+func (b *Batch) Flush(ctx context.Context) (BatchResult, error) {
+	return b.flush(ctx, false)
+}
+
+// FlushAll works like Flush, but saves all open sessions.
+// It must be called once all requests have been added to the Batch.
+func (b *Batch) FlushAll(ctx context.Context) (BatchResult, error) {
+	return b.flush(ctx, true)
+}
+
+func (b *Batch) flush(ctx context.Context, all bool) (BatchResult, error) {
+	b.flushLock.Lock()
+	defer b.flushLock.Unlock()
+	b.m.Lock()
+	visitors, bots, watermark := b.visitors, b.bots, b.watermark
+	b.visitors = make(map[batchVisitor][]*ingest.Request)
+	b.bots = nil
+	b.m.Unlock()
+	data := batchData{requests: bots}
+	result := BatchResult{}
+
+	for visitor, visitorRequests := range visitors {
+		state := b.open[visitor]
+
+		if state == nil {
+			state = new(batchSession)
+			b.open[visitor] = state
+		}
+
+		result.DroppedUpdates += b.reconstruct(state, visitorRequests, &data)
+	}
+
+	for visitor, state := range b.open {
+		if state.session != nil && (all || b.sessionEnded(visitor.siteID, watermark, state.session)) {
+			data.sessions = append(data.sessions, *state.session)
+			state.session = nil
+		}
+
+		// visitors identified by a client ID must be remembered to mark them as returning
+		if state.session == nil && !state.returning {
+			delete(b.open, visitor)
+		}
+	}
+
+	b.m.Lock()
+	b.pending.sessions = append(b.pending.sessions, data.sessions...)
+	b.pending.pageViews = append(b.pending.pageViews, data.pageViews...)
+	b.pending.events = append(b.pending.events, data.events...)
+	b.pending.requests = append(b.pending.requests, data.requests...)
+	b.m.Unlock()
+	var err error
+
+	if result.Sessions, err = save(ctx, b, &b.pending.sessions, b.storage.SaveSessions); err != nil {
+		return result, err
+	}
+
+	if result.PageViews, err = save(ctx, b, &b.pending.pageViews, b.storage.SavePageViews); err != nil {
+		return result, err
+	}
+
+	if result.Events, err = save(ctx, b, &b.pending.events, b.storage.SaveEvents); err != nil {
+		return result, err
+	}
+
+	if result.Requests, err = save(ctx, b, &b.pending.requests, b.storage.SaveRequests); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// reconstruct reconstructs the sessions for the requests of a single visitor and adds the ended sessions to the data.
+// The last session is kept open in the state.
+// It returns the number of session updates dropped, because there was no session to update.
+func (b *Batch) reconstruct(state *batchSession, requests []*ingest.Request, data *batchData) int {
+	slices.SortStableFunc(requests, func(a, b *ingest.Request) int {
+		return a.Time.Compare(b.Time)
+	})
+	session := state.session
+	droppedUpdates := 0
+
+	for _, request := range requests {
+		if session != nil && b.requestEndsSession(request, session) {
+			data.sessions = append(data.sessions, *session)
+			session = nil
+		}
+
+		if request.UpdateSession {
+			if session != nil {
+				request.VisitorID = session.VisitorID
+				b.session.update(request, session)
+			} else {
+				droppedUpdates++
+			}
+
+			continue
+		}
+
+		if session == nil {
+			// visitors identified by a client ID return for every session after the first one
+			request.VisitorID = b.session.visitorID(request, "", request.Time)
+			request.IsReturning = state.returning
+			session = b.session.new(request)
+			state.returning = request.ClientID != ""
+		} else {
+			request.VisitorID = session.VisitorID
+
+			// cancel if the maximum number of page views has been reached
+			if b.session.maxPageViews > 0 && session.PageViews >= b.session.maxPageViews {
+				request.IsBot = true
+				request.BotReason = "max-page-views"
+				data.requests = append(data.requests, b.requestLog(request))
+				continue
+			}
+
+			b.session.update(request, session)
+		}
+
+		if request.EventName != "" {
+			data.events = append(data.events, request.Event())
+		} else {
+			data.pageViews = append(data.pageViews, request.PageView())
+		}
+
+		data.requests = append(data.requests, b.requestLog(request))
+	}
+
+	state.session = session
+	return droppedUpdates
+}
+
+func (b *Batch) requestEndsSession(request *ingest.Request, session *model.Session) bool {
+	rules := b.session.getRules(request.SiteID)
+	return b.sessionEnded(request.SiteID, request.Time, session) ||
+		!request.UpdateSession && b.session.referrerOrCampaignChanged(request, session, &rules)
+}
+
+func (b *Batch) sessionEnded(siteID uint64, now time.Time, session *model.Session) bool {
+	rules := b.session.getRules(siteID)
+	return !session.Time.After(now.Add(-rules.Timeout)) ||
+		session.Start.Before(now.Add(-rules.MaxAge))
+}
+
+func (b *Batch) requestLog(request *ingest.Request) model.Request {
+	log := request.RequestLog()
+
+	if !b.logIP {
+		log.IP = ""
+	}
+
+	return log
+}
+
+// save saves the pending data in chunks and removes each chunk once it has been saved.
+// It returns the number of rows saved.
+func save[T any](ctx context.Context, b *Batch, pending *[]T, f func(context.Context, []T) error) (int, error) {
+	b.m.Lock()
+	data := *pending
+	b.m.Unlock()
+	saved := 0
+
+	for chunk := range slices.Chunk(data, b.saveSize) {
+		if err := f(ctx, chunk); err != nil {
+			return saved, err
+		}
+
+		saved += len(chunk)
+		b.m.Lock()
+		*pending = (*pending)[len(chunk):]
+		b.m.Unlock()
+	}
+
+	b.m.Lock()
+
+	// release the memory of saved data
+	if len(*pending) == 0 {
+		*pending = nil
+	}
+
+	b.m.Unlock()
+	return saved, nil
+}
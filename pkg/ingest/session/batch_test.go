@@ -0,0 +1,362 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	storage := db.NewMock()
+	batch := NewBatch(1, 2, "salt", storage, 0, nil).Use(&batchBotStep{})
+	start := time.Date(2025, 10, 10, 23, 50, 0, 0, time.UTC)
+
+	// requests for the first visitor arrive out of order and the session crosses midnight
+	for _, req := range []*ingest.Request{
+		newBatchRequest("visitor", "/about", start.Add(time.Minute*15)),
+		newBatchRequest("visitor", "/", start),
+		newBatchRequest("visitor", "/blog", start.Add(time.Minute*5)),
+		newBatchRequest("bot", "/", start),
+	} {
+		_, err := batch.Add(req)
+		assert.NoError(t, err)
+	}
+
+	// an event, a keep-alive, and a new session after the timeout
+	event := newBatchRequest("visitor", "/about", start.Add(time.Minute*16))
+	event.EventName = "click"
+	keepAlive := newBatchRequest("visitor", "/about", start.Add(time.Minute*20))
+	keepAlive.UpdateSession = true
+	cancel, err := batch.Add(event)
+	assert.False(t, cancel)
+	assert.NoError(t, err)
+	_, err = batch.Add(keepAlive)
+	assert.NoError(t, err)
+	_, err = batch.Add(newBatchRequest("visitor", "/", start.Add(time.Hour)))
+	assert.NoError(t, err)
+
+	// a different visitor is split on campaign change
+	campaign := newBatchRequest("other", "/", start.Add(time.Minute))
+	campaign.UTMCampaign = "campaign"
+	_, err = batch.Add(newBatchRequest("other", "/", start))
+	assert.NoError(t, err)
+	_, err = batch.Add(campaign)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, batch.Len())
+	result, err := batch.FlushAll(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, batch.Len())
+	assert.Equal(t, 4, result.Sessions)
+	assert.Equal(t, 6, result.PageViews)
+	assert.Equal(t, 1, result.Events)
+	assert.Equal(t, 8, result.Requests)
+
+	// only the final session rows must have been saved
+	sessions := storage.Sessions()
+	assert.Len(t, sessions, 4)
+	slices.SortFunc(sessions, func(a, b model.Session) int {
+		return a.Start.Compare(b.Start)
+	})
+	visitorSessions := make([]model.Session, 0, 2)
+
+	for _, session := range sessions {
+		assert.Equal(t, int8(1), session.Sign)
+
+		if session.EntryPath == "/" && session.PageViews == 3 || session.Start.Equal(start.Add(time.Hour)) {
+			visitorSessions = append(visitorSessions, session)
+		}
+	}
+
+	assert.Len(t, visitorSessions, 2)
+	first := visitorSessions[0]
+	assert.Equal(t, start, first.Start)
+	assert.Equal(t, start.Add(time.Minute*20), first.Time)
+	assert.Equal(t, uint16(3), first.PageViews)
+	assert.Equal(t, uint32(20*60), first.DurationSeconds)
+	assert.Equal(t, "/", first.EntryPath)
+	assert.Equal(t, "/about", first.ExitPath)
+	assert.False(t, first.IsBounce)
+	assert.Equal(t, uint16(1), first.Extended)
+	second := visitorSessions[1]
+	assert.Equal(t, uint16(1), second.PageViews)
+	assert.True(t, second.IsBounce)
+	assert.NotEqual(t, first.SessionID, second.SessionID)
+
+	// the visitor ID of the start day must be kept after midnight and change for the next session
+	assert.NotEqual(t, first.VisitorID, second.VisitorID)
+	pageViews := storage.PageViews()
+	assert.Len(t, pageViews, 6)
+
+	for _, pageView := range pageViews {
+		if pageView.SessionID == first.SessionID {
+			assert.Equal(t, first.VisitorID, pageView.VisitorID)
+		}
+	}
+
+	// the bot must only be logged
+	requests := storage.Requests()
+	assert.Len(t, requests, 8)
+	assert.True(t, slices.ContainsFunc(requests, func(r model.Request) bool {
+		return r.BotReason == "bot"
+	}))
+}
+
+func TestBatchMaxPageViews(t *testing.T) {
+	storage := db.NewMock()
+	batch := NewBatch(1, 2, "salt", storage, 2, nil)
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := range 3 {
+		_, err := batch.Add(newBatchRequest("visitor", "/", start.Add(time.Minute*time.Duration(i))))
+		assert.NoError(t, err)
+	}
+
+	result, err := batch.FlushAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Sessions)
+	assert.Equal(t, 2, result.PageViews)
+	assert.Equal(t, 3, result.Requests)
+	assert.Equal(t, uint16(2), storage.Sessions()[0].PageViews)
+	requests := storage.Requests()
+	assert.Len(t, requests, 3)
+	assert.Equal(t, "max-page-views", requests[2].BotReason)
+	assert.True(t, requests[2].Bot)
+}
+
+func TestBatchFlushOpenSessions(t *testing.T) {
+	storage := db.NewMock()
+	batch := NewBatch(1, 2, "salt", storage, 0, nil)
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	for _, req := range []*ingest.Request{
+		newBatchRequest("visitor", "/", start),
+		newBatchRequest("visitor", "/about", start.Add(time.Minute)),
+	} {
+		_, err := batch.Add(req)
+		assert.NoError(t, err)
+	}
+
+	// the session might still continue and must be kept open
+	result, err := batch.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, result.Sessions)
+	assert.Equal(t, 2, result.PageViews)
+
+	// the session must be continued and saved once it has ended relative to the latest request
+	for _, req := range []*ingest.Request{
+		newBatchRequest("visitor", "/pricing", start.Add(time.Minute*10)),
+		newBatchRequest("other", "/", start.Add(time.Hour)),
+	} {
+		_, err := batch.Add(req)
+		assert.NoError(t, err)
+	}
+
+	result, err = batch.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Sessions)
+	assert.Equal(t, 2, result.PageViews)
+	sessions := storage.Sessions()
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, uint16(3), sessions[0].PageViews)
+	assert.Equal(t, start, sessions[0].Start)
+
+	// the remaining open session must be saved by FlushAll
+	result, err = batch.FlushAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Sessions)
+	assert.Zero(t, result.PageViews)
+	assert.Len(t, storage.Sessions(), 2)
+}
+
+func TestBatchFlushError(t *testing.T) {
+	storage := &batchStorageWithError{Mock: db.NewMock(), err: errors.New("error on save")}
+	batch := NewBatch(1, 2, "salt", storage, 0, nil)
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+	keepAlive := newBatchRequest("other", "/", start)
+	keepAlive.UpdateSession = true
+
+	for _, req := range []*ingest.Request{
+		newBatchRequest("visitor", "/", start),
+		newBatchRequest("visitor", "/about", start.Add(time.Minute)),
+		keepAlive,
+	} {
+		_, err := batch.Add(req)
+		assert.NoError(t, err)
+	}
+
+	// the session must be saved, but the page views must be kept if saving them fails
+	result, err := batch.FlushAll(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.Sessions)
+	assert.Zero(t, result.PageViews)
+	assert.Equal(t, 1, result.DroppedUpdates)
+	assert.Equal(t, 2, batch.Len())
+	assert.Len(t, storage.Sessions(), 1)
+	assert.Empty(t, storage.PageViews())
+
+	// and saved on the next flush without saving the session twice
+	storage.err = nil
+	result, err = batch.FlushAll(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, result.Sessions)
+	assert.Equal(t, 2, result.PageViews)
+	assert.Equal(t, 2, result.Requests)
+	assert.Zero(t, batch.Len())
+	assert.Len(t, storage.Sessions(), 1)
+	assert.Len(t, storage.PageViews(), 2)
+	assert.Len(t, storage.Requests(), 2)
+}
+
+func TestBatchClientID(t *testing.T) {
+	storage := db.NewMock()
+	batch := NewBatch(1, 2, "salt", storage, 0, nil)
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	// the visitor returns on the next day from a different device
+	first := newBatchRequest("visitor", "/", start)
+	first.ClientID = "client"
+	second := newBatchRequest("other", "/", start.Add(time.Hour*24))
+	second.ClientID = "client"
+	second.IP = "2001:9e8:d5d2:b00:ce0a:96e4:ae42:c935"
+	third := newBatchRequest("other", "/about", start.Add(time.Hour*24+time.Minute))
+	third.ClientID = "client"
+
+	// a visitor without client ID is never returning
+	fingerprint := newBatchRequest("visitor", "/", start.Add(time.Hour*2))
+
+	for _, req := range []*ingest.Request{third, second, first, fingerprint} {
+		_, err := batch.Add(req)
+		assert.NoError(t, err)
+	}
+
+	result, err := batch.FlushAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Sessions)
+	assert.Equal(t, 4, result.PageViews)
+	sessions := storage.Sessions()
+	slices.SortFunc(sessions, func(a, b model.Session) int {
+		return a.Start.Compare(b.Start)
+	})
+	assert.Len(t, sessions, 3)
+	assert.False(t, sessions[0].IsReturning)
+	assert.False(t, sessions[1].IsReturning)
+	assert.True(t, sessions[2].IsReturning)
+	assert.Equal(t, sessions[0].VisitorID, sessions[2].VisitorID)
+	assert.NotEqual(t, sessions[0].VisitorID, sessions[1].VisitorID)
+	assert.Equal(t, uint16(2), sessions[2].PageViews)
+
+	for _, pageView := range storage.PageViews() {
+		assert.Equal(t, pageView.SessionID == sessions[2].SessionID, pageView.IsReturning)
+	}
+}
+
+func TestBatchUser(t *testing.T) {
+	storage := db.NewMock()
+	batch := NewBatch(1, 2, "salt", storage, 0, nil)
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	// the visitor authenticates during the session
+	login := newBatchRequest("visitor", "/login", start.Add(time.Minute))
+	login.User = "user"
+	account := newBatchRequest("visitor", "/account", start.Add(time.Minute*2))
+
+	for _, req := range []*ingest.Request{newBatchRequest("visitor", "/", start), login, account} {
+		_, err := batch.Add(req)
+		assert.NoError(t, err)
+	}
+
+	_, err := batch.FlushAll(context.Background())
+	assert.NoError(t, err)
+	sessions := storage.Sessions()
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, batch.session.hash("user"), sessions[0].UserID)
+	assert.NotZero(t, sessions[0].UserID)
+	pageViews := storage.PageViews()
+	assert.Len(t, pageViews, 3)
+
+	for _, pageView := range pageViews {
+		if pageView.Path == "/" {
+			assert.Zero(t, pageView.UserID)
+		} else {
+			assert.Equal(t, sessions[0].UserID, pageView.UserID)
+		}
+	}
+}
+
+func TestBatchRules(t *testing.T) {
+	storage := db.NewMock()
+	batch := NewBatch(1, 2, "salt", storage, 0, func(uint64) Rules {
+		return Rules{
+			Timeout:              time.Hour,
+			MaxAge:               time.Hour * 2,
+			KeepOnReferrerChange: true,
+		}
+	})
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+	referrer := newBatchRequest("visitor", "/", start.Add(time.Minute*45))
+	referrer.Referrer = "https://bing.com"
+
+	// the session is continued after 45 minutes and a referrer change, but ends after the max age
+	for _, req := range []*ingest.Request{
+		newBatchRequest("visitor", "/", start),
+		referrer,
+		newBatchRequest("visitor", "/", start.Add(time.Minute*90)),
+		newBatchRequest("visitor", "/", start.Add(time.Minute*135)),
+	} {
+		_, err := batch.Add(req)
+		assert.NoError(t, err)
+	}
+
+	result, err := batch.FlushAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Sessions)
+	sessions := storage.Sessions()
+	slices.SortFunc(sessions, func(a, b model.Session) int {
+		return a.Start.Compare(b.Start)
+	})
+	assert.Equal(t, uint16(3), sessions[0].PageViews)
+	assert.Equal(t, uint16(1), sessions[1].PageViews)
+}
+
+func newBatchRequest(ua, path string, t time.Time) *ingest.Request {
+	r, _ := http.NewRequest(http.MethodGet, "https://example.com"+path, nil)
+	return &ingest.Request{
+		Request:   r,
+		SiteID:    1,
+		Time:      t,
+		UserAgent: ua,
+		IP:        "81.2.69.142",
+	}
+}
+
+type batchStorageWithError struct {
+	*db.Mock
+	err error
+}
+
+func (client *batchStorageWithError) SavePageViews(ctx context.Context, pageViews []model.PageView) error {
+	if client.err != nil {
+		return client.err
+	}
+
+	return client.Mock.SavePageViews(ctx, pageViews)
+}
+
+type batchBotStep struct{}
+
+func (s *batchBotStep) Step(request *ingest.Request) (bool, error) {
+	if request.UserAgent == "bot" {
+		request.BotReason = "bot"
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,279 @@
+package session
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+)
+
+const (
+	defaultBehaviorMinPageViews          = 10
+	defaultBehaviorMaxPageViewsPerMinute = 30
+	defaultBehaviorUniformInterval       = time.Millisecond * 250
+	defaultBehaviorMaxEnumeration        = 5
+	defaultBehaviorWindow                = 20
+	behaviorCleanupInterval              = time.Minute * 5
+)
+
+// BehaviorOptions is the configuration for the Behavior step.
+type BehaviorOptions struct {
+	// MinPageViews is the number of page views a session must have before it's checked.
+	// If set to 0, the default value of 10 will be used.
+	MinPageViews uint16
+
+	// MaxPageViewsPerMinute is the maximum average number of page views per minute for a session.
+	// If set to <= 0, the default value of 30 will be used.
+	MaxPageViewsPerMinute float64
+
+	// UniformInterval is the maximum standard deviation of the time between page views for them to be considered uniform.
+	// If set to 0, the default value of 250 milliseconds will be used. Set it to < 0 to disable the check.
+	UniformInterval time.Duration
+
+	// MaxEnumeration is the maximum number of consecutive page views with an incrementing number in the path (like /page/1, /page/2, ...).
+	// If set to 0, the default value of 5 will be used. Set it to < 0 to disable the check.
+	MaxEnumeration int
+
+	// Window is the number of page views per session used to check the intervals.
+	// If set to < MinPageViews, the default value of 20 or MinPageViews will be used.
+	Window int
+
+	// Rules looks up the Rules per site to remove the state of sessions that have timed out.
+	// It should be the same as for the Session step. If nil, the default Rules are used for all sites.
+	Rules RulesLookup
+}
+
+func (options *BehaviorOptions) validate() {
+	if options.MinPageViews == 0 {
+		options.MinPageViews = defaultBehaviorMinPageViews
+	}
+
+	if options.MaxPageViewsPerMinute <= 0 {
+		options.MaxPageViewsPerMinute = defaultBehaviorMaxPageViewsPerMinute
+	}
+
+	if options.UniformInterval == 0 {
+		options.UniformInterval = defaultBehaviorUniformInterval
+	}
+
+	if options.MaxEnumeration == 0 {
+		options.MaxEnumeration = defaultBehaviorMaxEnumeration
+	}
+
+	if options.Window < int(options.MinPageViews) {
+		options.Window = max(defaultBehaviorWindow, int(options.MinPageViews))
+	}
+}
+
+type behaviorKey struct {
+	siteID    uint64
+	visitorID uint64
+	sessionID uint32
+}
+
+type behaviorState struct {
+	times       []time.Time
+	path        string
+	enumeration int
+	botReason   string
+	seen        time.Time
+}
+
+// Behavior flags sessions with inhuman activity as bots.
+// In contrast to the other bot filters, which look at a single request, it uses the session state to detect inhuman
+// page view rates, uniform intervals between page views, and path enumeration.
+// Once a session has been flagged, the session is removed retroactively by cancelling the previous session state
+// (see ingest.Request RetractSession), so that it won't count in session reports (visitors, sessions, bounces, ...).
+// This only applies to sessions. The page views and events stored before the session has been flagged can't be cancelled
+// and still count in page and event reports. All following requests for the session are cancelled.
+// It must be added after the Session step, and the state is kept in memory (per process).
+// In a distributed setup, requests of a visitor should therefore be routed to the same process.
+type Behavior struct {
+	minPageViews          uint16
+	maxPageViewsPerMinute float64
+	uniformInterval       time.Duration
+	maxEnumeration        int
+	window                int
+	rules                 RulesLookup
+	sessions              map[behaviorKey]*behaviorState
+	cleanup               time.Time
+	m                     sync.Mutex
+}
+
+// NewBehavior returns a new Behavior step for the given options.
+func NewBehavior(options BehaviorOptions) *Behavior {
+	options.validate()
+	return &Behavior{
+		minPageViews:          options.MinPageViews,
+		maxPageViewsPerMinute: options.MaxPageViewsPerMinute,
+		uniformInterval:       options.UniformInterval,
+		maxEnumeration:        options.MaxEnumeration,
+		window:                options.Window,
+		rules:                 options.Rules,
+		sessions:              make(map[behaviorKey]*behaviorState),
+	}
+}
+
+// Step implements ingest.PipeStep to process a step.
+func (b *Behavior) Step(request *ingest.Request) (bool, error) {
+	if request.DisableBotFilter || request.Session == nil {
+		return false, nil
+	}
+
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.removeExpired(request.Time)
+	key := behaviorKey{
+		siteID:    request.SiteID,
+		visitorID: request.VisitorID,
+		sessionID: request.Session.SessionID,
+	}
+	state := b.sessions[key]
+
+	if state == nil {
+		state = &behaviorState{
+			times: make([]time.Time, 0, b.window),
+		}
+		b.sessions[key] = state
+	}
+
+	state.seen = request.Time
+
+	// the session has been removed already, so there is nothing left to cancel
+	if state.botReason != "" {
+		request.BotReason = state.botReason
+		request.Session = nil
+		request.CancelSession = nil
+		return true, nil
+	}
+
+	if request.EventName != "" {
+		return false, nil
+	}
+
+	b.track(state, request)
+	state.botReason = b.botReason(state, request)
+
+	if state.botReason != "" {
+		request.BotReason = state.botReason
+		request.Session = nil
+		request.RetractSession = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (b *Behavior) track(state *behaviorState, request *ingest.Request) {
+	if len(state.times) == b.window {
+		state.times = slices.Delete(state.times, 0, 1)
+	}
+
+	state.times = append(state.times, request.Time)
+
+	if state.path != "" && isEnumeration(state.path, request.Path) {
+		state.enumeration++
+	} else {
+		state.enumeration = 0
+	}
+
+	state.path = request.Path
+}
+
+func (b *Behavior) botReason(state *behaviorState, request *ingest.Request) string {
+	if b.maxEnumeration > 0 && state.enumeration >= b.maxEnumeration {
+		return "behavior-enumeration"
+	}
+
+	session := request.Session
+
+	if session.PageViews < b.minPageViews {
+		return ""
+	}
+
+	duration := session.Time.Sub(session.Start)
+
+	if duration <= 0 || float64(session.PageViews-1)/duration.Minutes() > b.maxPageViewsPerMinute {
+		return "behavior-rate"
+	}
+
+	if b.uniformInterval > 0 && len(state.times) >= int(b.minPageViews) && intervalDeviation(state.times) <= b.uniformInterval {
+		return "behavior-interval"
+	}
+
+	return ""
+}
+
+func (b *Behavior) removeExpired(now time.Time) {
+	if now.Sub(b.cleanup) < behaviorCleanupInterval {
+		return
+	}
+
+	timeouts := make(map[uint64]time.Duration)
+
+	for key, state := range b.sessions {
+		timeout, found := timeouts[key.siteID]
+
+		if !found {
+			timeout = b.rules.get(key.siteID).Timeout
+			timeouts[key.siteID] = timeout
+		}
+
+		if state.seen.Before(now.Add(-timeout)) {
+			delete(b.sessions, key)
+		}
+	}
+
+	b.cleanup = now
+}
+
+func intervalDeviation(times []time.Time) time.Duration {
+	intervals := make([]float64, 0, len(times)-1)
+	var sum float64
+
+	for i := 1; i < len(times); i++ {
+		interval := float64(times[i].Sub(times[i-1]))
+		intervals = append(intervals, interval)
+		sum += interval
+	}
+
+	mean := sum / float64(len(intervals))
+	var variance float64
+
+	for _, interval := range intervals {
+		variance += (interval - mean) * (interval - mean)
+	}
+
+	return time.Duration(math.Sqrt(variance / float64(len(intervals))))
+}
+
+func isEnumeration(previous, path string) bool {
+	a, b := strings.Split(previous, "/"), strings.Split(path, "/")
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	changed := false
+
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+
+		x, errA := strconv.Atoi(a[i])
+		y, errB := strconv.Atoi(b[i])
+
+		if changed || errA != nil || errB != nil || y-x != 1 {
+			return false
+		}
+
+		changed = true
+	}
+
+	return changed
+}
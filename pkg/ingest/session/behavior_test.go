@@ -0,0 +1,182 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBehaviorRate(t *testing.T) {
+	s := NewSession(1, 2, "salt", NewMemCache(db.NewMock(), 100), 0, nil)
+	b := NewBehavior(BehaviorOptions{})
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := range 9 {
+		cancel, err := runBehavior(s, b, newBehaviorRequest(fmt.Sprintf("/page-%d", i), start.Add(time.Second*time.Duration(i))))
+		assert.NoError(t, err)
+		assert.False(t, cancel)
+	}
+
+	// the tenth page view within 9 seconds must remove the session
+	req := newBehaviorRequest("/page-9", start.Add(time.Second*9))
+	cancel, err := runBehavior(s, b, req)
+	assert.NoError(t, err)
+	assert.True(t, cancel)
+	assert.Equal(t, "behavior-rate", req.BotReason)
+	assert.Nil(t, req.Session)
+	assert.NotNil(t, req.CancelSession)
+	assert.Equal(t, int8(-1), req.CancelSession.Sign)
+	assert.Equal(t, uint16(9), req.CancelSession.PageViews)
+	assert.True(t, req.RetractSession)
+
+	// following requests must be cancelled without cancelling the session again
+	req = newBehaviorRequest("/", start.Add(time.Minute*5))
+	cancel, err = runBehavior(s, b, req)
+	assert.NoError(t, err)
+	assert.True(t, cancel)
+	assert.Equal(t, "behavior-rate", req.BotReason)
+	assert.Nil(t, req.Session)
+	assert.Nil(t, req.CancelSession)
+	assert.False(t, req.RetractSession)
+}
+
+func TestBehaviorRules(t *testing.T) {
+	b := NewBehavior(BehaviorOptions{
+		Rules: func(siteID uint64) Rules {
+			if siteID == 2 {
+				return Rules{Timeout: time.Hour * 2}
+			}
+
+			return Rules{}
+		},
+	})
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	for _, siteID := range []uint64{1, 2} {
+		req := newBehaviorRequest("/", start)
+		req.SiteID = siteID
+		req.Session = new(model.Session)
+		_, err := b.Step(req)
+		assert.NoError(t, err)
+	}
+
+	// the state must be kept for the timeout of the site
+	b.removeExpired(start.Add(time.Hour))
+	assert.Len(t, b.sessions, 1)
+	b.removeExpired(start.Add(time.Hour * 3))
+	assert.Empty(t, b.sessions)
+}
+
+func TestBehaviorInterval(t *testing.T) {
+	s := NewSession(1, 2, "salt", NewMemCache(db.NewMock(), 100), 0, nil)
+	b := NewBehavior(BehaviorOptions{})
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+	var req *ingest.Request
+
+	for i := range 10 {
+		req = newBehaviorRequest(fmt.Sprintf("/page-%d", i), start.Add(time.Second*30*time.Duration(i)+time.Millisecond*time.Duration(i%2*100)))
+		_, err := runBehavior(s, b, req)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, "behavior-interval", req.BotReason)
+}
+
+func TestBehaviorEnumeration(t *testing.T) {
+	s := NewSession(1, 2, "salt", NewMemCache(db.NewMock(), 100), 0, nil)
+	b := NewBehavior(BehaviorOptions{})
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := range 5 {
+		cancel, err := runBehavior(s, b, newBehaviorRequest(fmt.Sprintf("/product/%d/details", i+1), start.Add(time.Second*time.Duration(i*i*20))))
+		assert.NoError(t, err)
+		assert.False(t, cancel)
+	}
+
+	req := newBehaviorRequest("/product/6/details", start.Add(time.Minute*10))
+	cancel, err := runBehavior(s, b, req)
+	assert.NoError(t, err)
+	assert.True(t, cancel)
+	assert.Equal(t, "behavior-enumeration", req.BotReason)
+}
+
+func TestBehaviorHuman(t *testing.T) {
+	s := NewSession(1, 2, "salt", NewMemCache(db.NewMock(), 100), 0, nil)
+	b := NewBehavior(BehaviorOptions{})
+	now := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := range 30 {
+		now = now.Add(time.Second * time.Duration(5+i*7%40))
+		req := newBehaviorRequest(fmt.Sprintf("/blog/%d", i%3), now)
+
+		if i%4 == 0 {
+			req.EventName = "click"
+		}
+
+		cancel, err := runBehavior(s, b, req)
+		assert.NoError(t, err)
+		assert.False(t, cancel)
+		assert.Empty(t, req.BotReason)
+	}
+}
+
+func TestBehaviorPipe(t *testing.T) {
+	storage := db.NewMock()
+	pipe := ingest.NewPipe(ingest.PipeOptions{
+		Storage: storage,
+		Worker:  1,
+	}).Use(NewSession(1, 2, "salt", NewMemCache(storage, 100), 0, nil), NewBehavior(BehaviorOptions{}))
+	start := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := range 12 {
+		assert.NoError(t, pipe.Process(newBehaviorRequest(fmt.Sprintf("/page-%d", i), start.Add(time.Second*time.Duration(i)))))
+	}
+
+	pipe.Stop()
+	assert.Len(t, storage.PageViews(), 9)
+	assert.Len(t, storage.Requests(), 12)
+	var sign int
+
+	for _, session := range storage.Sessions() {
+		sign += int(session.Sign)
+	}
+
+	assert.Zero(t, sign)
+}
+
+func TestIsEnumeration(t *testing.T) {
+	assert.True(t, isEnumeration("/page/1", "/page/2"))
+	assert.True(t, isEnumeration("/1/foo", "/2/foo"))
+	assert.False(t, isEnumeration("/page/2", "/page/1"))
+	assert.False(t, isEnumeration("/page/1", "/page/1"))
+	assert.False(t, isEnumeration("/page/1", "/page/3"))
+	assert.False(t, isEnumeration("/page/1", "/other/2"))
+	assert.False(t, isEnumeration("/page/1/1", "/page/2/2"))
+	assert.False(t, isEnumeration("/page/1", "/page/1/2"))
+}
+
+func runBehavior(s *Session, b *Behavior, req *ingest.Request) (bool, error) {
+	if cancel, err := s.Step(req); cancel || err != nil {
+		return cancel, err
+	}
+
+	return b.Step(req)
+}
+
+func newBehaviorRequest(path string, t time.Time) *ingest.Request {
+	r, _ := http.NewRequest(http.MethodGet, "https://example.com"+path, nil)
+	return &ingest.Request{
+		Request:   r,
+		SiteID:    1,
+		Time:      t,
+		Path:      path,
+		UserAgent: "Mozilla/5.0",
+		IP:        "81.2.69.142",
+	}
+}
@@ -23,6 +23,91 @@ type Cache interface {
 	NewMutex(uint64, uint64) sync.Locker
 }
 
+// VisitorCache is implemented by caches remembering when visitors identified by a client ID have been seen,
+// so that returning visitors can be detected without looking up previous sessions in the db.Storage.
+type VisitorCache interface {
+	// Seen marks the visitor for given site ID and client ID hash as seen at given time for the duration of the window.
+	// It returns true if the visitor has been seen within the window before.
+	Seen(uint64, uint64, time.Time, time.Duration) bool
+}
+
+// failingLocker is implemented by mutexes that might not be acquired, like the RedisMutex.
+// Err returns the error of the last call to Lock.
+type failingLocker interface {
+	Err() error
+}
+
+// maxAgeCache is implemented by caches removing sessions after a maximum age.
+// The Session extends the maximum age to the longest timeout of the site rules, so that sessions are kept long enough.
+type maxAgeCache interface {
+	extendMaxAge(time.Duration)
+}
+
 func getSessionKey(siteID, fingerprint uint64) string {
 	return fmt.Sprintf("%d_%d", siteID, fingerprint)
 }
+
+// KeyLocker provides mutexes per key, which are removed once they are no longer in use.
+// It can be used to implement Cache.NewMutex for caches on a single machine.
+type KeyLocker struct {
+	locks map[string]*keyLock
+	m     sync.Mutex
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+type keyMutex struct {
+	locker *KeyLocker
+	key    string
+	lock   *keyLock
+}
+
+// NewKeyLocker creates a new KeyLocker.
+func NewKeyLocker() *KeyLocker {
+	return &KeyLocker{
+		locks: make(map[string]*keyLock),
+	}
+}
+
+// NewMutex creates a new mutex for given key.
+// The mutex is shared by all callers for the same key.
+func (locker *KeyLocker) NewMutex(key string) sync.Locker {
+	return &keyMutex{
+		locker: locker,
+		key:    key,
+	}
+}
+
+// Lock locks the mutex for the key.
+func (m *keyMutex) Lock() {
+	m.locker.m.Lock()
+	lock, found := m.locker.locks[m.key]
+
+	if !found {
+		lock = new(keyLock)
+		m.locker.locks[m.key] = lock
+	}
+
+	lock.refs++
+	m.locker.m.Unlock()
+	lock.Lock()
+	m.lock = lock
+}
+
+// Unlock unlocks the mutex for the key.
+func (m *keyMutex) Unlock() {
+	lock := m.lock
+	m.lock = nil
+	m.locker.m.Lock()
+	lock.refs--
+
+	if lock.refs == 0 {
+		delete(m.locker.locks, m.key)
+	}
+
+	m.locker.m.Unlock()
+	lock.Unlock()
+}
@@ -0,0 +1,35 @@
+package session
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLocker(t *testing.T) {
+	locker := NewKeyLocker()
+	var wg sync.WaitGroup
+	counter := 0
+
+	for range 100 {
+		wg.Go(func() {
+			m := locker.NewMutex("key")
+			m.Lock()
+			defer m.Unlock()
+			counter++
+		})
+	}
+
+	wg.Wait()
+	assert.Equal(t, 100, counter)
+	assert.Empty(t, locker.locks)
+
+	// different keys must not block each other
+	a := locker.NewMutex("a")
+	a.Lock()
+	b := locker.NewMutex("b")
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+}
@@ -0,0 +1,230 @@
+// Package disk provides a session.Cache persisting sessions in an embedded SQLite database.
+// It's a separate package, because the SQLite driver requires cgo.
+package disk
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/session"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+)
+
+const (
+	defaultMaxAge = time.Minute * 30
+	sweepInterval = time.Minute
+	schema        = `CREATE TABLE IF NOT EXISTS "session" (
+		"key" TEXT PRIMARY KEY,
+		"session" BLOB NOT NULL,
+		"expires" INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS "session_expires" ON "session" ("expires");
+	CREATE TABLE IF NOT EXISTS "salt" (
+		"key" TEXT PRIMARY KEY,
+		"salt" TEXT NOT NULL,
+		"expires" INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS "visitor" (
+		"key" TEXT PRIMARY KEY,
+		"seen" INTEGER NOT NULL,
+		"expires" INTEGER NOT NULL
+	);`
+)
+
+// Cache caches sessions in an embedded SQLite database on disk, so that they survive restarts.
+// Like the session.MemCache, this does only make sense for non-distributed systems (tracking on a single machine/app).
+// Sessions expire after the maximum age and are removed periodically in the background until Close is called.
+// Visitors identified by a client ID are remembered for the returning window (see session.VisitorCache).
+type Cache struct {
+	db      *sql.DB
+	maxAge  time.Duration
+	client  db.Storage
+	locks   *session.KeyLocker
+	logger  *slog.Logger
+	sweeper *time.Timer
+	closed  bool
+	m       sync.Mutex
+}
+
+// NewCache opens or creates the cache database at given path.
+// The maximum age should be at least the session timeout. The default is 30 minutes.
+// If the client is set, sessions not found in the cache are looked up in the db.Storage.
+func NewCache(path string, maxAge time.Duration, client db.Storage, log *slog.Logger) (*Cache, error) {
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+
+	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL", path))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only supports a single writer
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.Exec(schema); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	cache := &Cache{
+		db:     conn,
+		maxAge: maxAge,
+		client: client,
+		locks:  session.NewKeyLocker(),
+		logger: log,
+	}
+	cache.removeExpired(time.Now())
+	cache.sweeper = time.AfterFunc(sweepInterval, cache.sweep)
+	return cache, nil
+}
+
+// Get implements the session.Cache interface.
+func (cache *Cache) Get(siteID, fingerprint uint64, maxAge time.Time) *model.Session {
+	var data []byte
+	err := cache.db.QueryRow(`SELECT "session" FROM "session" WHERE "key" = ? AND "expires" > ?`,
+		getSessionKey(siteID, fingerprint),
+		time.Now().UnixMilli()).Scan(&data)
+
+	if err == nil {
+		var s model.Session
+
+		if err := json.Unmarshal(data, &s); err != nil {
+			cache.logger.Error("error unmarshalling session from cache", "err", err)
+		} else if s.Time.After(maxAge) {
+			return &s
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		cache.logger.Error("error reading session from cache", "err", err)
+	}
+
+	if cache.client == nil {
+		return nil
+	}
+
+	s, _ := cache.client.Session(context.Background(), siteID, fingerprint, maxAge)
+	return s
+}
+
+// Put implements the session.Cache interface.
+func (cache *Cache) Put(siteID, fingerprint uint64, s *model.Session) {
+	data, err := json.Marshal(s)
+
+	if err != nil {
+		cache.logger.Error("error marshalling session for cache", "err", err)
+		return
+	}
+
+	if _, err := cache.db.Exec(`INSERT OR REPLACE INTO "session" ("key", "session", "expires") VALUES (?, ?, ?)`,
+		getSessionKey(siteID, fingerprint),
+		data,
+		time.Now().Add(cache.maxAge).UnixMilli()); err != nil {
+		cache.logger.Error("error storing session in cache", "err", err)
+	}
+}
+
+// Clear implements the session.Cache interface.
+// Salts are kept, so that fingerprints don't change.
+func (cache *Cache) Clear() {
+	if _, err := cache.db.Exec(`DELETE FROM "session"; DELETE FROM "visitor";`); err != nil {
+		cache.logger.Error("error clearing cache", "err", err)
+	}
+}
+
+// Salt implements the session.SaltStore interface.
+func (cache *Cache) Salt(siteID uint64, t time.Time, salt string, expires time.Time) (string, error) {
+	key := getSaltKey(siteID, t)
+
+	// only the first salt stored for the day is kept
+	if _, err := cache.db.Exec(`INSERT OR IGNORE INTO "salt" ("key", "salt", "expires") VALUES (?, ?, ?)`,
+		key,
+		salt,
+		expires.UnixMilli()); err != nil {
+		return "", err
+	}
+
+	var existing string
+
+	if err := cache.db.QueryRow(`SELECT "salt" FROM "salt" WHERE "key" = ?`, key).Scan(&existing); err != nil {
+		return "", err
+	}
+
+	return existing, nil
+}
+
+// Seen implements the session.VisitorCache interface.
+// Errors are logged and the visitor is treated as new.
+func (cache *Cache) Seen(siteID, visitorID uint64, now time.Time, window time.Duration) bool {
+	key := getSessionKey(siteID, visitorID)
+	var seen int64
+	err := cache.db.QueryRow(`SELECT "seen" FROM "visitor" WHERE "key" = ?`, key).Scan(&seen)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		cache.logger.Error("error reading visitor from cache", "err", err)
+	}
+
+	if _, err := cache.db.Exec(`INSERT OR REPLACE INTO "visitor" ("key", "seen", "expires") VALUES (?, ?, ?)`,
+		key,
+		now.UnixMilli(),
+		now.Add(window).UnixMilli()); err != nil {
+		cache.logger.Error("error storing visitor in cache", "err", err)
+	}
+
+	return err == nil && time.UnixMilli(seen).After(now.Add(-window))
+}
+
+// NewMutex implements the session.Cache interface.
+// The mutex is shared by all callers for the same site ID and fingerprint.
+func (cache *Cache) NewMutex(siteID, fingerprint uint64) sync.Locker {
+	return cache.locks.NewMutex(getSessionKey(siteID, fingerprint))
+}
+
+// Close stops removing expired sessions and closes the database.
+func (cache *Cache) Close() error {
+	cache.m.Lock()
+	defer cache.m.Unlock()
+	cache.closed = true
+	cache.sweeper.Stop()
+	return cache.db.Close()
+}
+
+func (cache *Cache) sweep() {
+	cache.m.Lock()
+	defer cache.m.Unlock()
+
+	if !cache.closed {
+		cache.removeExpired(time.Now())
+		cache.sweeper.Reset(sweepInterval)
+	}
+}
+
+func (cache *Cache) removeExpired(now time.Time) {
+	if _, err := cache.db.Exec(`DELETE FROM "session" WHERE "expires" <= ?; DELETE FROM "salt" WHERE "expires" <= ?; DELETE FROM "visitor" WHERE "expires" <= ?;`,
+		now.UnixMilli(),
+		now.UnixMilli(),
+		now.UnixMilli()); err != nil {
+		cache.logger.Error("error removing expired sessions from cache", "err", err)
+	}
+}
+
+func getSessionKey(siteID, fingerprint uint64) string {
+	return fmt.Sprintf("%d_%d", siteID, fingerprint)
+}
+
+func getSaltKey(siteID uint64, t time.Time) string {
+	return fmt.Sprintf("salt_%d_%s", siteID, t.UTC().Format("20060102"))
+}
@@ -0,0 +1,142 @@
+package disk
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/session"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	cache, err := NewCache(path, time.Minute, nil, nil)
+	assert.NoError(t, err)
+	now := time.Now().UTC()
+	assert.Nil(t, cache.Get(1, 1, now.Add(-time.Minute)))
+	cache.Put(1, 1, &model.Session{
+		Data: model.Data{
+			Time:      now,
+			SessionID: 42,
+		},
+		ExitPath:  "/test",
+		PageViews: 3,
+	})
+	session := cache.Get(1, 1, now.Add(-time.Minute))
+	assert.NotNil(t, session)
+	assert.Equal(t, uint32(42), session.SessionID)
+	assert.Equal(t, "/test", session.ExitPath)
+	assert.Equal(t, uint16(3), session.PageViews)
+	assert.Nil(t, cache.Get(1, 1, now.Add(time.Minute)))
+	assert.Nil(t, cache.Get(1, 2, now.Add(-time.Minute)))
+
+	// the session must survive a restart
+	assert.NoError(t, cache.Close())
+	cache, err = NewCache(path, time.Minute, nil, nil)
+	assert.NoError(t, err)
+	session = cache.Get(1, 1, now.Add(-time.Minute))
+	assert.NotNil(t, session)
+	assert.Equal(t, "/test", session.ExitPath)
+	cache.Clear()
+	assert.Nil(t, cache.Get(1, 1, now.Add(-time.Minute)))
+	assert.NoError(t, cache.Close())
+}
+
+func TestCacheExpired(t *testing.T) {
+	client := db.NewMock()
+	cache, err := NewCache(filepath.Join(t.TempDir(), "sessions.db"), time.Minute, client, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	now := time.Now()
+	cache.Put(1, 1, &model.Session{
+		Data: model.Data{
+			Time: now,
+		},
+	})
+	cache.removeExpired(now.Add(time.Minute * 2))
+	var n int
+	assert.NoError(t, cache.db.QueryRow(`SELECT count(*) FROM "session"`).Scan(&n))
+	assert.Zero(t, n)
+
+	// sessions not found must be looked up in the storage
+	client.ReturnSession = &model.Session{ExitPath: "/storage"}
+	session := cache.Get(1, 1, now.Add(-time.Minute))
+	assert.NotNil(t, session)
+	assert.Equal(t, "/storage", session.ExitPath)
+}
+
+func TestCacheSalt(t *testing.T) {
+	cache, err := NewCache(filepath.Join(t.TempDir(), "sessions.db"), time.Minute, nil, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	now := time.Now()
+	expires := now.Add(time.Hour * 24)
+	assertSalt(t, cache, 1, now, "salt", expires, "salt")
+	assertSalt(t, cache, 1, now, "other", expires, "salt")
+	assertSalt(t, cache, 2, now, "other", expires, "other")
+
+	// clearing the sessions must keep the salts
+	cache.Clear()
+	assertSalt(t, cache, 1, now, "other", expires, "salt")
+	cache.removeExpired(expires.Add(time.Minute))
+	assertSalt(t, cache, 1, now, "new", expires, "new")
+}
+
+func TestCacheMutex(t *testing.T) {
+	cache, err := NewCache(filepath.Join(t.TempDir(), "sessions.db"), time.Minute, nil, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	var wg sync.WaitGroup
+	counter := 0
+
+	for range 100 {
+		wg.Go(func() {
+			m := cache.NewMutex(1, 1)
+			m.Lock()
+			defer m.Unlock()
+			counter++
+		})
+	}
+
+	wg.Wait()
+	assert.Equal(t, 100, counter)
+
+	// different keys must not block each other
+	a := cache.NewMutex(1, 1)
+	a.Lock()
+	b := cache.NewMutex(1, 2)
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+}
+
+func TestCacheSeen(t *testing.T) {
+	cache, err := NewCache(filepath.Join(t.TempDir(), "sessions.db"), time.Minute, nil, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	now := time.Now()
+	assert.False(t, cache.Seen(1, 1, now, time.Hour))
+	assert.True(t, cache.Seen(1, 1, now.Add(time.Minute*30), time.Hour))
+	assert.False(t, cache.Seen(1, 2, now, time.Hour))
+	assert.False(t, cache.Seen(1, 1, now.Add(time.Hour*2), time.Hour))
+	cache.Clear()
+	assert.False(t, cache.Seen(1, 1, now.Add(time.Hour*2), time.Hour))
+}
+
+func assertSalt(t *testing.T, store session.SaltStore, siteID uint64, now time.Time, salt string, expires time.Time, expected string) {
+	s, err := store.Salt(siteID, now, salt, expires)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, s)
+}
@@ -0,0 +1,239 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+)
+
+const (
+	encodingVersion = 1
+)
+
+var errSessionEncoding = errors.New("session encoding invalid")
+
+// encodeSession encodes the session into a compact binary format using variable length integers.
+// The fields must be decoded in the same order by decodeSession.
+func encodeSession(session *model.Session) []byte {
+	e := sessionEncoder{buf: make([]byte, 0, 256)}
+	e.buf = append(e.buf, encodingVersion)
+	e.uint(session.SiteID)
+	e.uint(session.VisitorID)
+	e.uint(uint64(session.SessionID))
+	e.bool(session.IsReturning)
+	e.uint(session.UserID)
+	e.time(session.Time)
+	e.string(session.Hostname)
+	e.string(session.Language)
+	e.string(session.CountryCode)
+	e.string(session.Region)
+	e.string(session.City)
+	e.string(session.ContinentCode)
+	e.string(session.TimeZone)
+	e.uint(uint64(math.Float32bits(session.Latitude)))
+	e.uint(uint64(math.Float32bits(session.Longitude)))
+	e.bool(session.InEU)
+	e.string(session.Referrer)
+	e.string(session.ReferrerName)
+	e.string(session.ReferrerIcon)
+	e.string(session.OS)
+	e.string(session.OSVersion)
+	e.string(session.Browser)
+	e.string(session.BrowserVersion)
+	e.int(int64(session.Platform))
+	e.string(session.ScreenClass)
+	e.string(session.UTMSource)
+	e.string(session.UTMMedium)
+	e.string(session.UTMCampaign)
+	e.string(session.UTMContent)
+	e.string(session.UTMTerm)
+	e.string(session.Channel)
+	e.int(int64(session.Sign))
+	e.uint(uint64(session.Version))
+	e.time(session.Start)
+	e.uint(uint64(session.DurationSeconds))
+	e.uint(uint64(session.PageViews))
+	e.bool(session.IsBounce)
+	e.string(session.EntryPath)
+	e.string(session.ExitPath)
+	e.string(session.EntryTitle)
+	e.string(session.ExitTitle)
+	e.uint(uint64(session.Extended))
+	return e.buf
+}
+
+// decodeSession decodes a session encoded by encodeSession.
+func decodeSession(data []byte) (*model.Session, error) {
+	if len(data) == 0 || data[0] != encodingVersion {
+		return nil, fmt.Errorf("%w: unknown version", errSessionEncoding)
+	}
+
+	d := sessionDecoder{buf: data[1:]}
+	session := new(model.Session)
+	session.SiteID = d.uint()
+	session.VisitorID = d.uint()
+	session.SessionID = uint32(d.uint())
+	session.IsReturning = d.bool()
+	session.UserID = d.uint()
+	session.Time = d.time()
+	session.Hostname = d.string()
+	session.Language = d.string()
+	session.CountryCode = d.string()
+	session.Region = d.string()
+	session.City = d.string()
+	session.ContinentCode = d.string()
+	session.TimeZone = d.string()
+	session.Latitude = math.Float32frombits(uint32(d.uint()))
+	session.Longitude = math.Float32frombits(uint32(d.uint()))
+	session.InEU = d.bool()
+	session.Referrer = d.string()
+	session.ReferrerName = d.string()
+	session.ReferrerIcon = d.string()
+	session.OS = d.string()
+	session.OSVersion = d.string()
+	session.Browser = d.string()
+	session.BrowserVersion = d.string()
+	session.Platform = int8(d.int())
+	session.ScreenClass = d.string()
+	session.UTMSource = d.string()
+	session.UTMMedium = d.string()
+	session.UTMCampaign = d.string()
+	session.UTMContent = d.string()
+	session.UTMTerm = d.string()
+	session.Channel = d.string()
+	session.Sign = int8(d.int())
+	session.Version = uint16(d.uint())
+	session.Start = d.time()
+	session.DurationSeconds = uint32(d.uint())
+	session.PageViews = uint16(d.uint())
+	session.IsBounce = d.bool()
+	session.EntryPath = d.string()
+	session.ExitPath = d.string()
+	session.EntryTitle = d.string()
+	session.ExitTitle = d.string()
+	session.Extended = uint16(d.uint())
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if len(d.buf) != 0 {
+		return nil, fmt.Errorf("%w: trailing data", errSessionEncoding)
+	}
+
+	return session, nil
+}
+
+type sessionEncoder struct {
+	buf []byte
+}
+
+func (e *sessionEncoder) uint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *sessionEncoder) int(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *sessionEncoder) bool(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *sessionEncoder) string(v string) {
+	e.uint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *sessionEncoder) time(v time.Time) {
+	e.int(v.Unix())
+	e.uint(uint64(v.Nanosecond()))
+}
+
+type sessionDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *sessionDecoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.buf)
+
+	if n <= 0 {
+		d.err = errSessionEncoding
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *sessionDecoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(d.buf)
+
+	if n <= 0 {
+		d.err = errSessionEncoding
+		return 0
+	}
+
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *sessionDecoder) bool() bool {
+	if d.err != nil {
+		return false
+	}
+
+	if len(d.buf) == 0 {
+		d.err = errSessionEncoding
+		return false
+	}
+
+	v := d.buf[0] == 1
+	d.buf = d.buf[1:]
+	return v
+}
+
+func (d *sessionDecoder) string() string {
+	n := d.uint()
+
+	if d.err != nil {
+		return ""
+	}
+
+	if uint64(len(d.buf)) < n {
+		d.err = errSessionEncoding
+		return ""
+	}
+
+	v := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *sessionDecoder) time() time.Time {
+	sec := d.int()
+	nsec := d.uint()
+
+	if d.err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(sec, int64(nsec)).UTC()
+}
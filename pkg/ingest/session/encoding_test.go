@@ -0,0 +1,88 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeSession(t *testing.T) {
+	now := time.Date(2025, 10, 10, 12, 30, 15, 123456789, time.UTC)
+	session := &model.Session{
+		Data: model.Data{
+			SiteID:         42,
+			VisitorID:      1234567890123,
+			SessionID:      987654,
+			IsReturning:    true,
+			UserID:         99,
+			Time:           now,
+			Hostname:       "example.com",
+			Language:       "de",
+			CountryCode:    "de",
+			Region:         "Bavaria",
+			City:           "München",
+			ContinentCode:  "EU",
+			TimeZone:       "Europe/Berlin",
+			Latitude:       48.1,
+			Longitude:      -11.6,
+			InEU:           true,
+			Referrer:       "https://google.com",
+			ReferrerName:   "Google",
+			ReferrerIcon:   "https://google.com/favicon.ico",
+			OS:             pkg.OSWindows,
+			OSVersion:      "10",
+			Browser:        pkg.BrowserChrome,
+			BrowserVersion: "146",
+			Platform:       pkg.PlatformDesktop,
+			ScreenClass:    "XL",
+			UTMSource:      "source",
+			UTMMedium:      "medium",
+			UTMCampaign:    "campaign",
+			UTMContent:     "content",
+			UTMTerm:        "term",
+			Channel:        "Organic Search",
+		},
+		Sign:            -1,
+		Version:         7,
+		Start:           now.Add(-time.Minute * 5),
+		DurationSeconds: 300,
+		PageViews:       4,
+		IsBounce:        false,
+		EntryPath:       "/",
+		ExitPath:        "/about",
+		EntryTitle:      "Home",
+		ExitTitle:       "About",
+		Extended:        2,
+	}
+	data := encodeSession(session)
+	jsonData, err := json.Marshal(session)
+	assert.NoError(t, err)
+	assert.Less(t, len(data), len(jsonData)/2)
+	decoded, err := decodeSession(data)
+	assert.NoError(t, err)
+	assert.Equal(t, session, decoded)
+
+	// the zero value must be encoded too
+	decoded, err = decodeSession(encodeSession(new(model.Session)))
+	assert.NoError(t, err)
+	assert.True(t, decoded.Time.IsZero())
+	assert.True(t, decoded.Start.IsZero())
+	decoded.Time, decoded.Start = time.Time{}, time.Time{}
+	assert.Equal(t, new(model.Session), decoded)
+}
+
+func TestDecodeSessionInvalid(t *testing.T) {
+	data := encodeSession(&model.Session{ExitPath: "/test"})
+	_, err := decodeSession(nil)
+	assert.ErrorIs(t, err, errSessionEncoding)
+	_, err = decodeSession([]byte(`{"exit_path":"/test"}`))
+	assert.ErrorIs(t, err, errSessionEncoding)
+	_, err = decodeSession(data[:len(data)-3])
+	assert.ErrorIs(t, err, errSessionEncoding)
+	_, err = decodeSession(append(data, 0))
+	assert.ErrorIs(t, err, errSessionEncoding)
+}
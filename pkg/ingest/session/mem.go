@@ -1,9 +1,11 @@
 package session
 
 import (
+	"container/list"
 	"context"
 	"maps"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
@@ -12,41 +14,118 @@ import (
 
 const (
 	defaultMaxSessions = 10_000
+	memSweepInterval   = time.Minute
 )
 
+// MemCacheStats are the statistics for a MemCache.
+type MemCacheStats struct {
+	// Sessions is the number of sessions in the cache.
+	Sessions int
+
+	// Hits is the number of sessions found in the cache.
+	Hits uint64
+
+	// Misses is the number of sessions not found in the cache, which have been looked up in the db.Storage instead.
+	Misses uint64
+
+	// Evictions is the number of sessions removed because the cache was full or the session has timed out.
+	Evictions uint64
+}
+
+type memVisitor struct {
+	seen    time.Time
+	expires time.Time
+}
+
 // MemCache caches sessions in memory.
 // This does only make sense for non-distributed systems (tracking on a single machine/app).
+// Once the maximum size is reached, the least recently used session is evicted.
+// Visitors identified by a client ID are remembered for the returning window (see VisitorCache),
+// up to the maximum size as well, after which random visitors are forgotten.
+// Sessions that have timed out are removed periodically in the background, starting with the first session stored.
+// Close must be called to stop it once the cache is no longer used.
 type MemCache struct {
 	sessions    map[string]model.Session
+	lru         *list.List
+	elements    map[string]*list.Element
+	salts       map[saltKey]saltEntry
+	visitors    map[string]memVisitor
 	maxSessions int
+	ttl         time.Duration
 	client      db.Storage
-	m           sync.RWMutex
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	sweeper     *time.Timer
+	closed      bool
+	m           sync.Mutex
+}
+
+// MemCacheOptions is the configuration for the MemCache.
+type MemCacheOptions struct {
+	// Client is the db.Storage used to look up sessions that are not in the cache.
+	Client db.Storage
+
+	// MaxSessions is the maximum number of sessions kept in the cache.
+	// The default is 10,000.
+	MaxSessions int
+
+	// MaxAge is the time sessions are kept after they have been updated the last time.
+	// The maximum age is extended to the longest session timeout of the Rules used by the Session. The default is 30 minutes.
+	MaxAge time.Duration
+}
+
+func (options *MemCacheOptions) validate() {
+	if options.MaxSessions <= 0 {
+		options.MaxSessions = defaultMaxSessions
+	}
+
+	if options.MaxAge <= 0 {
+		options.MaxAge = sessionTimeout
+	}
 }
 
 // NewMemCache creates a new cache for a given client and maximum size.
 func NewMemCache(client db.Storage, maxSessions int) *MemCache {
-	if maxSessions <= 0 {
-		maxSessions = defaultMaxSessions
-	}
+	return NewMemCacheWithOptions(MemCacheOptions{
+		Client:      client,
+		MaxSessions: maxSessions,
+	})
+}
 
+// NewMemCacheWithOptions creates a new cache for given MemCacheOptions.
+func NewMemCacheWithOptions(options MemCacheOptions) *MemCache {
+	options.validate()
 	return &MemCache{
 		sessions:    make(map[string]model.Session),
-		maxSessions: maxSessions,
-		client:      client,
+		lru:         list.New(),
+		elements:    make(map[string]*list.Element),
+		salts:       make(map[saltKey]saltEntry),
+		visitors:    make(map[string]memVisitor),
+		maxSessions: options.MaxSessions,
+		ttl:         options.MaxAge,
+		client:      options.Client,
 	}
 }
 
 // Get implements the Cache interface.
 func (cache *MemCache) Get(siteID, fingerprint uint64, maxAge time.Time) *model.Session {
 	key := getSessionKey(siteID, fingerprint)
-	cache.m.RLock()
+	cache.m.Lock()
 	session, found := cache.sessions[key]
-	cache.m.RUnlock()
+
+	if found {
+		cache.lru.MoveToFront(cache.elements[key])
+	}
+
+	cache.m.Unlock()
 
 	if found && session.Time.After(maxAge) {
+		cache.hits.Add(1)
 		return &session
 	}
 
+	cache.misses.Add(1)
 	s, _ := cache.client.Session(context.Background(), siteID, fingerprint, maxAge)
 	return s
 }
@@ -57,8 +136,19 @@ func (cache *MemCache) Put(siteID, fingerprint uint64, session *model.Session) {
 	cache.m.Lock()
 	defer cache.m.Unlock()
 
-	if len(cache.sessions) >= cache.maxSessions {
-		cache.sessions = make(map[string]model.Session)
+	if element, found := cache.elements[key]; found {
+		cache.lru.MoveToFront(element)
+	} else {
+		for len(cache.sessions) >= cache.maxSessions {
+			cache.remove(cache.lru.Back().Value.(string))
+		}
+
+		cache.elements[key] = cache.lru.PushFront(key)
+	}
+
+	// start removing timed out sessions once there are any
+	if cache.sweeper == nil && !cache.closed {
+		cache.sweeper = time.AfterFunc(memSweepInterval, cache.sweep)
 	}
 
 	cache.sessions[key] = *session
@@ -69,6 +159,49 @@ func (cache *MemCache) Clear() {
 	cache.m.Lock()
 	defer cache.m.Unlock()
 	cache.sessions = make(map[string]model.Session)
+	cache.lru.Init()
+	cache.elements = make(map[string]*list.Element)
+	cache.visitors = make(map[string]memVisitor)
+}
+
+// Salt implements the SaltStore interface.
+func (cache *MemCache) Salt(siteID uint64, t time.Time, salt string, expires time.Time) (string, error) {
+	key := saltKey{siteID, saltDay(t)}
+	cache.m.Lock()
+	defer cache.m.Unlock()
+
+	if existing, found := cache.salts[key]; found {
+		return existing.salt, nil
+	}
+
+	now := time.Now()
+
+	for k, v := range cache.salts {
+		if v.expires.Before(now) {
+			delete(cache.salts, k)
+		}
+	}
+
+	cache.salts[key] = saltEntry{salt, expires}
+	return salt, nil
+}
+
+// Seen implements the VisitorCache interface.
+func (cache *MemCache) Seen(siteID, visitorID uint64, now time.Time, window time.Duration) bool {
+	key := getSessionKey(siteID, visitorID)
+	cache.m.Lock()
+	defer cache.m.Unlock()
+	visitor, found := cache.visitors[key]
+
+	if !found && len(cache.visitors) >= cache.maxSessions {
+		for k := range cache.visitors {
+			delete(cache.visitors, k)
+			break
+		}
+	}
+
+	cache.visitors[key] = memVisitor{now, now.Add(window)}
+	return found && visitor.seen.After(now.Add(-window))
 }
 
 // NewMutex implements the Cache interface.
@@ -76,11 +209,77 @@ func (cache *MemCache) NewMutex(uint64, uint64) sync.Locker {
 	return new(sync.Mutex)
 }
 
+// extendMaxAge implements the maxAgeCache interface.
+func (cache *MemCache) extendMaxAge(timeout time.Duration) {
+	cache.m.Lock()
+	defer cache.m.Unlock()
+	cache.ttl = max(cache.ttl, timeout)
+}
+
 // Sessions returns a copy of all sessions.
 func (cache *MemCache) Sessions() map[string]model.Session {
-	cache.m.RLock()
-	defer cache.m.RUnlock()
+	cache.m.Lock()
+	defer cache.m.Unlock()
 	sessions := make(map[string]model.Session, len(cache.sessions))
 	maps.Copy(sessions, cache.sessions)
 	return sessions
 }
+
+// Stats returns the MemCacheStats.
+func (cache *MemCache) Stats() MemCacheStats {
+	cache.m.Lock()
+	sessions := len(cache.sessions)
+	cache.m.Unlock()
+	return MemCacheStats{
+		Sessions:  sessions,
+		Hits:      cache.hits.Load(),
+		Misses:    cache.misses.Load(),
+		Evictions: cache.evictions.Load(),
+	}
+}
+
+// Close stops removing timed out sessions in the background.
+func (cache *MemCache) Close() {
+	cache.m.Lock()
+	defer cache.m.Unlock()
+	cache.closed = true
+
+	if cache.sweeper != nil {
+		cache.sweeper.Stop()
+	}
+}
+
+func (cache *MemCache) sweep() {
+	cache.removeExpired(time.Now())
+	cache.m.Lock()
+	defer cache.m.Unlock()
+
+	if !cache.closed {
+		cache.sweeper.Reset(memSweepInterval)
+	}
+}
+
+func (cache *MemCache) removeExpired(now time.Time) {
+	cache.m.Lock()
+	defer cache.m.Unlock()
+	maxAge := now.Add(-cache.ttl)
+
+	for key, session := range cache.sessions {
+		if !session.Time.After(maxAge) {
+			cache.remove(key)
+		}
+	}
+
+	for key, visitor := range cache.visitors {
+		if visitor.expires.Before(now) {
+			delete(cache.visitors, key)
+		}
+	}
+}
+
+func (cache *MemCache) remove(key string) {
+	cache.lru.Remove(cache.elements[key])
+	delete(cache.elements, key)
+	delete(cache.sessions, key)
+	cache.evictions.Add(1)
+}
@@ -3,6 +3,7 @@ package session
 import (
 	"math/rand/v2"
 	"testing"
+	"testing/synctest"
 	"time"
 
 	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
@@ -73,7 +74,7 @@ func TestMemCache(t *testing.T) {
 	session = cache.Get(1, 1, time.Now().Add(-time.Minute))
 	assert.NotNil(t, session)
 	assert.Equal(t, "/", session.ExitPath)
-	cache.Put(1, 10, &model.Session{
+	cache.Put(1, 11, &model.Session{
 		Data: model.Data{
 			Time:      time.Now(),
 			SessionID: rand.Uint32(),
@@ -82,12 +83,124 @@ func TestMemCache(t *testing.T) {
 		EntryPath: "/bar",
 		PageViews: 42,
 	})
-	assert.Len(t, cache.sessions, 1)
+
+	// the least recently used session must have been evicted
+	assert.Len(t, cache.sessions, 10)
+	assert.Len(t, cache.elements, 10)
+	assert.Equal(t, 10, cache.lru.Len())
 	session = cache.Get(1, 1, time.Now().Add(-time.Minute))
+	assert.NotNil(t, session)
+	session = cache.Get(1, 2, time.Now().Add(-time.Minute))
 	assert.Nil(t, session)
-	session = cache.Get(1, 10, time.Now().Add(-time.Minute))
+	session = cache.Get(1, 11, time.Now().Add(-time.Minute))
 	assert.NotNil(t, session)
 	assert.Equal(t, "/foo", session.ExitPath)
 	cache.Clear()
 	assert.Len(t, cache.sessions, 0)
+	assert.Len(t, cache.elements, 0)
+	assert.Zero(t, cache.lru.Len())
+	cache.Close()
+}
+
+func TestMemCacheExpired(t *testing.T) {
+	cache := NewMemCache(db.NewMock(), 10)
+	defer cache.Close()
+	now := time.Now()
+	cache.Put(1, 1, &model.Session{
+		Data: model.Data{
+			Time: now.Add(-sessionTimeout - time.Minute),
+		},
+	})
+	cache.Put(1, 2, &model.Session{
+		Data: model.Data{
+			Time: now.Add(-time.Minute),
+		},
+	})
+	cache.removeExpired(now)
+	sessions := cache.Sessions()
+	assert.Len(t, sessions, 1)
+	assert.Contains(t, sessions, getSessionKey(1, 2))
+	assert.Equal(t, 1, cache.lru.Len())
+	assert.Equal(t, uint64(1), cache.Stats().Evictions)
+}
+
+func TestMemCacheSweep(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		cache := NewMemCache(db.NewMock(), 10)
+		defer cache.Close()
+
+		// the sweeper is started with the first session
+		assert.Nil(t, cache.sweeper)
+		cache.Put(1, 1, &model.Session{
+			Data: model.Data{
+				Time: time.Now(),
+			},
+		})
+		assert.NotNil(t, cache.sweeper)
+		time.Sleep(sessionTimeout - time.Second)
+		synctest.Wait()
+		assert.Len(t, cache.Sessions(), 1)
+		time.Sleep(memSweepInterval)
+		synctest.Wait()
+		assert.Empty(t, cache.Sessions())
+		assert.Equal(t, uint64(1), cache.Stats().Evictions)
+	})
+}
+
+func TestMemCacheSweepMaxAge(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		cache := NewMemCacheWithOptions(MemCacheOptions{Client: db.NewMock(), MaxSessions: 10, MaxAge: time.Hour})
+		defer cache.Close()
+		cache.Put(1, 1, &model.Session{
+			Data: model.Data{
+				Time: time.Now(),
+			},
+		})
+		time.Sleep(sessionTimeout + memSweepInterval)
+		synctest.Wait()
+		assert.Len(t, cache.Sessions(), 1)
+		time.Sleep(time.Hour - sessionTimeout)
+		synctest.Wait()
+		assert.Empty(t, cache.Sessions())
+	})
+}
+
+func TestMemCacheStats(t *testing.T) {
+	cache := NewMemCache(db.NewMock(), 2)
+	defer cache.Close()
+	now := time.Now()
+
+	for i := range 3 {
+		cache.Put(1, uint64(i), &model.Session{
+			Data: model.Data{
+				Time: now,
+			},
+		})
+	}
+
+	assert.NotNil(t, cache.Get(1, 2, now.Add(-time.Minute)))
+	assert.NotNil(t, cache.Get(1, 1, now.Add(-time.Minute)))
+	assert.Nil(t, cache.Get(1, 0, now.Add(-time.Minute)))
+	assert.Nil(t, cache.Get(1, 1, now.Add(time.Minute)))
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Sessions)
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+}
+
+func TestMemCacheSeen(t *testing.T) {
+	cache := NewMemCache(db.NewMock(), 2)
+	defer cache.Close()
+	now := time.Now()
+	assert.False(t, cache.Seen(1, 1, now, time.Hour))
+	assert.True(t, cache.Seen(1, 1, now.Add(time.Minute*30), time.Hour))
+	assert.False(t, cache.Seen(1, 2, now, time.Hour))
+	assert.False(t, cache.Seen(1, 1, now.Add(time.Hour*2), time.Hour))
+
+	// visitors are forgotten once the cache is full or they expire
+	assert.False(t, cache.Seen(1, 3, now, time.Hour))
+	assert.Len(t, cache.visitors, 2)
+	cache.removeExpired(now.Add(time.Hour * 4))
+	assert.Empty(t, cache.visitors)
 }
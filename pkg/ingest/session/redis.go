@@ -2,98 +2,349 @@ package session
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"log/slog"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/go-redsync/redsync/v4"
 	"github.com/go-redsync/redsync/v4/redis/goredis/v8"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
 	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
 )
 
+const (
+	defaultRedisKeyPrefix = "session_"
+	defaultRedisTimeout   = time.Second
+)
+
+// RedisCacheOptions is the configuration for the RedisCache.
+type RedisCacheOptions struct {
+	// Client is the optional db.Storage used to look up sessions that are not in the cache.
+	// If not set, sessions are only read from Redis.
+	Client db.Storage
+
+	// Redis are the connection options.
+	// Depending on the options, this connects to a single node, a Redis Cluster, or uses Sentinel for failover.
+	// If not set, it will connect to localhost.
+	Redis *redis.UniversalOptions
+
+	// KeyPrefix is the prefix for all keys stored by the cache.
+	// Clear only deletes sessions with this prefix and keeps the salts. The default is "session_".
+	KeyPrefix string
+
+	// MaxAge is the time sessions are kept after they have been updated the last time.
+	// The default is the session timeout of 30 minutes.
+	MaxAge time.Duration
+
+	// Timeout is the timeout for each operation.
+	// Reading a session is treated as a cache miss after the timeout, so that a slow Redis won't stall the ingest.Pipe.
+	// The default is one second.
+	Timeout time.Duration
+
+	// LockTimeout is the maximum time to acquire a session lock, including all LockTries.
+	// Attempts that would exceed the timeout are not made, so it should be larger than LockTries times LockRetryDelay.
+	// The default is the Timeout.
+	LockTimeout time.Duration
+
+	// LockExpiry is the time after which a session lock expires if it hasn't been released.
+	// The default is 8 seconds.
+	LockExpiry time.Duration
+
+	// LockTries is the number of attempts to acquire a session lock.
+	// The default is 32.
+	LockTries int
+
+	// LockRetryDelay is the delay between attempts to acquire a session lock.
+	// The default is a random delay between 50 and 250 milliseconds.
+	LockRetryDelay time.Duration
+
+	// Logger is the log/slog.Logger used to log errors.
+	Logger *slog.Logger
+}
+
+func (options *RedisCacheOptions) validate() {
+	if options.Redis == nil {
+		options.Redis = new(redis.UniversalOptions)
+	}
+
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = defaultRedisKeyPrefix
+	}
+
+	if options.MaxAge <= 0 {
+		options.MaxAge = sessionTimeout
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = defaultRedisTimeout
+	}
+
+	if options.LockTimeout <= 0 {
+		options.LockTimeout = options.Timeout
+	}
+
+	if options.Logger == nil {
+		options.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+}
+
+func (options *RedisCacheOptions) mutexOptions() []redsync.Option {
+	mutexOptions := make([]redsync.Option, 0, 3)
+
+	if options.LockExpiry > 0 {
+		mutexOptions = append(mutexOptions, redsync.WithExpiry(options.LockExpiry))
+	}
+
+	if options.LockTries > 0 {
+		mutexOptions = append(mutexOptions, redsync.WithTries(options.LockTries))
+	}
+
+	if options.LockRetryDelay > 0 {
+		mutexOptions = append(mutexOptions, redsync.WithRetryDelay(options.LockRetryDelay))
+	}
+
+	return mutexOptions
+}
+
+// RedisCacheStats are the statistics for a RedisCache.
+type RedisCacheStats struct {
+	// LockFailures is the number of session locks that could not be acquired.
+	// The requests have failed with ErrSessionLock.
+	LockFailures uint64
+}
+
 // RedisCache caches sessions in Redis.
+// Sessions are stored in a compact binary encoding.
+// Errors are logged and handled like cache misses, so that tracking won't stop working if Redis cannot be reached.
+// Only requests for which the session lock cannot be acquired fail, as they could otherwise create duplicate sessions.
 type RedisCache struct {
-	maxAge time.Duration
-	rds    *redis.Client
-	rs     *redsync.Redsync
-	logger *slog.Logger
+	client       db.Storage
+	maxAge       time.Duration
+	timeout      time.Duration
+	lockTimeout  time.Duration
+	prefix       string
+	rds          redis.UniversalClient
+	rs           *redsync.Redsync
+	mutexOptions []redsync.Option
+	lockFailures atomic.Uint64
+	logger       *slog.Logger
 }
 
 // RedisMutex wraps a redis mutex.
+// If the lock cannot be acquired within the lock timeout, the error is logged, counted in the RedisCacheStats,
+// and returned by Err, so that the Session fails the request with ErrSessionLock.
 type RedisMutex struct {
-	m *redsync.Mutex
+	m           *redsync.Mutex
+	locked      bool
+	err         error
+	lockTimeout time.Duration
+	timeout     time.Duration
+	failures    *atomic.Uint64
+	logger      *slog.Logger
 }
 
+// Lock acquires the lock.
 func (m *RedisMutex) Lock() {
-	if err := m.m.Lock(); err != nil {
-		panic(err)
+	ctx, cancel := context.WithTimeout(context.Background(), m.lockTimeout)
+	defer cancel()
+	m.err = m.m.LockContext(ctx)
+
+	if m.err != nil {
+		m.failures.Add(1)
+		m.logger.Error("error acquiring session lock", "err", m.err)
+		return
 	}
+
+	m.locked = true
+}
+
+// Err returns the error of the last call to Lock.
+func (m *RedisMutex) Err() error {
+	return m.err
 }
 
+// Unlock releases the lock if it has been acquired.
 func (m *RedisMutex) Unlock() {
-	if _, err := m.m.Unlock(); err != nil {
-		panic(err)
+	if !m.locked {
+		return
 	}
-}
 
-// NewRedisCache creates a new cache for a given maximum age and redis connection.
-func NewRedisCache(maxAge time.Duration, log *slog.Logger, redisOptions *redis.Options) *RedisCache {
-	if log == nil {
-		log = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	m.locked = false
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	if _, err := m.m.UnlockContext(ctx); err != nil {
+		m.logger.Error("error releasing session lock", "err", err)
 	}
+}
 
-	client := redis.NewClient(redisOptions)
+// NewRedisCache creates a new cache for given options.
+func NewRedisCache(options RedisCacheOptions) *RedisCache {
+	options.validate()
+	client := redis.NewUniversalClient(options.Redis)
 	return &RedisCache{
-		maxAge: maxAge,
-		rds:    client,
-		rs:     redsync.New(goredis.NewPool(client)),
-		logger: log,
+		client:       options.Client,
+		maxAge:       options.MaxAge,
+		timeout:      options.Timeout,
+		lockTimeout:  options.LockTimeout,
+		prefix:       options.KeyPrefix,
+		rds:          client,
+		rs:           redsync.New(goredis.NewPool(client)),
+		mutexOptions: options.mutexOptions(),
+		logger:       options.Logger,
 	}
 }
 
 // Get implements the Cache interface.
-func (cache *RedisCache) Get(siteID, fingerprint uint64, _ time.Time) *model.Session {
-	r, err := cache.rds.Get(context.Background(), getSessionKey(siteID, fingerprint)).Result()
-
-	if err != nil {
-		if !errors.Is(err, redis.Nil) {
-			cache.logger.Error("error reading session from cache", "err", err)
-		}
+// Sessions older than the maximum age or not found in Redis are looked up in the db.Storage if configured.
+func (cache *RedisCache) Get(siteID, fingerprint uint64, maxAge time.Time) *model.Session {
+	if session := cache.get(siteID, fingerprint); session != nil && session.Time.After(maxAge) {
+		return session
+	}
 
+	if cache.client == nil {
 		return nil
 	}
 
-	var session model.Session
+	ctx, cancel := context.WithTimeout(context.Background(), cache.timeout)
+	defer cancel()
+	session, err := cache.client.Session(ctx, siteID, fingerprint, maxAge)
 
-	if err := json.Unmarshal([]byte(r), &session); err != nil {
-		cache.logger.Error("error unmarshalling session from cache", "err", err)
+	if err != nil {
+		cache.logger.Error("error reading session from storage", "err", err)
 		return nil
 	}
 
-	return &session
+	return session
 }
 
 // Put implements the Cache interface.
 func (cache *RedisCache) Put(siteID, fingerprint uint64, session *model.Session) {
-	v, err := json.Marshal(session)
+	ctx, cancel := context.WithTimeout(context.Background(), cache.timeout)
+	defer cancel()
 
-	if err == nil {
-		cache.rds.SetEX(context.Background(), getSessionKey(siteID, fingerprint), v, cache.maxAge)
-	} else {
+	if err := cache.rds.SetEX(ctx, cache.prefix+getSessionKey(siteID, fingerprint), encodeSession(session), cache.maxAge).Err(); err != nil {
 		cache.logger.Error("error storing session in cache", "err", err)
 	}
 }
 
 // Clear implements the Cache interface.
+// It only deletes sessions with the configured prefix. Salts are kept, so that fingerprints don't change.
 func (cache *RedisCache) Clear() {
-	cache.rds.FlushDB(context.Background())
+	ctx := context.Background()
+	var err error
+
+	// each node of a cluster must be scanned separately
+	if cluster, ok := cache.rds.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return cache.clear(ctx, client)
+		})
+	} else {
+		err = cache.clear(ctx, cache.rds)
+	}
+
+	if err != nil {
+		cache.logger.Error("error clearing cache", "err", err)
+	}
+}
+
+// Salt implements the SaltStore interface.
+func (cache *RedisCache) Salt(siteID uint64, t time.Time, salt string, expires time.Time) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cache.timeout)
+	defer cancel()
+	key := cache.prefix + getSaltKey(siteID, t)
+
+	// only the first node storing the salt for the day wins
+	if err := cache.rds.SetNX(ctx, key, salt, max(time.Until(expires), time.Minute)).Err(); err != nil {
+		return "", err
+	}
+
+	return cache.rds.Get(ctx, key).Result()
+}
+
+// Seen implements the VisitorCache interface.
+// Errors are logged and the visitor is treated as new.
+func (cache *RedisCache) Seen(siteID, visitorID uint64, now time.Time, window time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), cache.timeout)
+	defer cancel()
+	key := cache.prefix + getSessionKey(siteID, visitorID) + "_seen"
+	pipe := cache.rds.TxPipeline()
+	previous := pipe.GetSet(ctx, key, now.UnixMilli())
+	pipe.PExpire(ctx, key, window)
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		cache.logger.Error("error storing visitor in cache", "err", err)
+		return false
+	}
+
+	seen, err := previous.Int64()
+	return err == nil && time.UnixMilli(seen).After(now.Add(-window))
 }
 
 // NewMutex implements the Cache interface.
 func (cache *RedisCache) NewMutex(siteID, fingerprint uint64) sync.Locker {
-	return &RedisMutex{cache.rs.NewMutex(getSessionKey(siteID, fingerprint) + "_lock")}
+	return &RedisMutex{
+		m:           cache.rs.NewMutex(cache.prefix+getSessionKey(siteID, fingerprint)+"_lock", cache.mutexOptions...),
+		lockTimeout: cache.lockTimeout,
+		timeout:     cache.timeout,
+		failures:    &cache.lockFailures,
+		logger:      cache.logger,
+	}
+}
+
+// Stats returns the RedisCacheStats.
+func (cache *RedisCache) Stats() RedisCacheStats {
+	return RedisCacheStats{
+		LockFailures: cache.lockFailures.Load(),
+	}
+}
+
+func (cache *RedisCache) get(siteID, fingerprint uint64) *model.Session {
+	ctx, cancel := context.WithTimeout(context.Background(), cache.timeout)
+	defer cancel()
+	r, err := cache.rds.Get(ctx, cache.prefix+getSessionKey(siteID, fingerprint)).Bytes()
+
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			cache.logger.Error("error reading session from cache", "err", err)
+		}
+
+		return nil
+	}
+
+	session, err := decodeSession(r)
+
+	if err != nil {
+		cache.logger.Error("error decoding session from cache", "err", err)
+		return nil
+	}
+
+	return session
+}
+
+// Close closes the connection to Redis.
+func (cache *RedisCache) Close() error {
+	return cache.rds.Close()
+}
+
+func (cache *RedisCache) clear(ctx context.Context, client redis.UniversalClient) error {
+	// session keys start with the site ID, salt keys and locks held by other callers are kept
+	iter := client.Scan(ctx, 0, cache.prefix+"[0-9]*", 0).Iterator()
+
+	for iter.Next(ctx) {
+		if strings.HasSuffix(iter.Val(), "_lock") {
+			continue
+		}
+
+		if err := client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
 }
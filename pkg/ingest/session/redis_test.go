@@ -1,30 +1,160 @@
 package session
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
 	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRedisCache(t *testing.T) {
-	cache := NewRedisCache(time.Second, nil, &redis.Options{
-		Addr: "localhost:6379",
+	cache := NewRedisCache(RedisCacheOptions{
+		Redis: &redis.UniversalOptions{
+			Addrs: []string{"localhost:6379"},
+		},
+		MaxAge: time.Second,
 	})
 	cache.Clear()
 	session := cache.Get(1, 1, time.Time{})
 	assert.Nil(t, session)
-	cache.Put(1, 1, &model.Session{ExitPath: "/test"})
+	cache.Put(1, 1, &model.Session{Data: model.Data{Time: time.Now()}, ExitPath: "/test"})
 	session = cache.Get(1, 1, time.Time{})
 	assert.NotNil(t, session)
 	assert.Equal(t, "/test", session.ExitPath)
 	cache.Clear()
 	session = cache.Get(1, 1, time.Time{})
 	assert.Nil(t, session)
-	cache.Put(1, 1, &model.Session{ExitPath: "/test"})
+	cache.Put(1, 1, &model.Session{Data: model.Data{Time: time.Now()}, ExitPath: "/test"})
+	assert.Nil(t, cache.Get(1, 1, time.Now().Add(time.Minute)))
 	time.Sleep(time.Second * 2)
 	session = cache.Get(1, 1, time.Time{})
 	assert.Nil(t, session)
 }
+
+func TestRedisCacheStorage(t *testing.T) {
+	// sessions that cannot be read from Redis must be looked up in the storage
+	storage := db.NewMock()
+	cache := NewRedisCache(RedisCacheOptions{
+		Client: storage,
+		Redis: &redis.UniversalOptions{
+			Addrs: []string{"localhost:1"},
+		},
+		Timeout: time.Millisecond * 100,
+	})
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	assert.Nil(t, cache.Get(1, 1, time.Time{}))
+	storage.ReturnSession = &model.Session{ExitPath: "/storage"}
+	session := cache.Get(1, 1, time.Time{})
+	assert.NotNil(t, session)
+	assert.Equal(t, "/storage", session.ExitPath)
+}
+
+func TestRedisCacheSalt(t *testing.T) {
+	cache := NewRedisCache(RedisCacheOptions{
+		Redis: &redis.UniversalOptions{
+			Addrs: []string{"localhost:6379"},
+		},
+		MaxAge: time.Second,
+	})
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	ctx := context.Background()
+	now := time.Now()
+	expires := saltExpiry(saltDay(now), sessionMaxAge)
+	assert.NoError(t, cache.rds.Del(ctx, cache.prefix+getSaltKey(1, now), cache.prefix+getSaltKey(2, now), cache.prefix+getSaltKey(1, now.Add(-day))).Err())
+	assertSalt(t, cache, 1, now, "salt", expires, "salt")
+	assertSalt(t, cache, 1, now, "other", expires, "salt")
+	assertSalt(t, cache, 2, now, "other", expires, "other")
+	assertSalt(t, cache, 1, now.Add(-day), "other", saltExpiry(saltDay(now.Add(-day)), sessionMaxAge), "other")
+	ttl, err := cache.rds.TTL(ctx, cache.prefix+getSaltKey(1, now)).Result()
+	assert.NoError(t, err)
+	assert.True(t, ttl > sessionMaxAge)
+	assert.True(t, ttl <= day+sessionMaxAge)
+
+	// clearing the sessions must keep the salts
+	cache.Clear()
+	assertSalt(t, cache, 1, now, "new", expires, "salt")
+}
+
+func TestRedisCacheClear(t *testing.T) {
+	cache := NewRedisCache(RedisCacheOptions{
+		Redis: &redis.UniversalOptions{
+			Addrs: []string{"localhost:6379"},
+		},
+		KeyPrefix: "test_",
+	})
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	ctx := context.Background()
+	assert.NoError(t, cache.rds.Set(ctx, "other", "value", time.Minute).Err())
+	cache.Put(1, 1, &model.Session{Data: model.Data{Time: time.Now()}, ExitPath: "/test"})
+	assert.NotNil(t, cache.Get(1, 1, time.Time{}))
+	m := cache.NewMutex(1, 1)
+	m.Lock()
+	cache.Clear()
+	assert.Nil(t, cache.Get(1, 1, time.Time{}))
+
+	// locks must be kept, so that they can be released
+	keys, err := cache.rds.Keys(ctx, "test_*_lock").Result()
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	m.Unlock()
+	assert.NoError(t, m.(*RedisMutex).Err())
+
+	// keys without the prefix must be kept
+	value, err := cache.rds.Get(ctx, "other").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.NoError(t, cache.rds.Del(ctx, "other").Err())
+}
+
+func TestRedisCacheLockTimeout(t *testing.T) {
+	// acquiring the lock must be canceled after the timeout, even if it would be retried
+	cache := NewRedisCache(RedisCacheOptions{
+		Redis: &redis.UniversalOptions{
+			Addrs: []string{"localhost:1"},
+		},
+		LockTimeout:    time.Millisecond * 100,
+		LockTries:      1000,
+		LockRetryDelay: time.Millisecond * 50,
+	})
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	start := time.Now()
+	m := cache.NewMutex(1, 1)
+	assert.ErrorIs(t, lock(m), ErrSessionLock)
+	assert.Error(t, m.(*RedisMutex).Err())
+	m.Unlock()
+	assert.Less(t, time.Since(start), time.Second*5)
+	assert.Equal(t, uint64(1), cache.Stats().LockFailures)
+}
+
+func TestRedisCacheUnavailable(t *testing.T) {
+	cache := NewRedisCache(RedisCacheOptions{
+		Redis: &redis.UniversalOptions{
+			Addrs: []string{"localhost:1"},
+		},
+		Timeout:        time.Millisecond * 100,
+		LockTries:      1,
+		LockRetryDelay: time.Millisecond,
+	})
+	start := time.Now()
+	m := cache.NewMutex(1, 1)
+	m.Lock()
+	assert.Nil(t, cache.Get(1, 1, time.Time{}))
+	cache.Put(1, 1, &model.Session{Data: model.Data{Time: time.Now()}, ExitPath: "/test"})
+	m.Unlock()
+	assert.Equal(t, uint64(1), cache.Stats().LockFailures)
+	_, err := cache.Salt(1, time.Now(), "salt", time.Now().Add(day))
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second*5)
+}
@@ -0,0 +1,94 @@
+package session
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Rules configures how sessions are created for a site.
+type Rules struct {
+	// Timeout is the time of inactivity after which a new session is started.
+	// The MemCache keeps sessions at least this long, other caches must be configured accordingly,
+	// or sessions will be looked up in the db.Storage.
+	// Fingerprints change daily, so timeouts above 24 hours are rejected and the default is used instead.
+	// The default is 30 minutes.
+	Timeout time.Duration
+
+	// MaxAge is the maximum duration of a session, after which a new session is started.
+	// It also defines how long the daily fingerprint salts are kept.
+	// Like the Timeout, values above 24 hours are rejected and the default is used instead.
+	// The default is 24 hours.
+	MaxAge time.Duration
+
+	// KeepOnReferrerChange continues the session if the referrer changes.
+	// By default, a new session is started.
+	KeepOnReferrerChange bool
+
+	// KeepOnCampaignChange continues the session if a UTM parameter changes.
+	// By default, a new session is started.
+	KeepOnCampaignChange bool
+
+	// IgnoreReferrers is a list of referrer hostnames that never start a new session (like payment providers).
+	// Subdomains are matched as well.
+	IgnoreReferrers []string
+
+	// ReturningWindow is how far back previous sessions are looked up to mark a visitor identified by a client ID as returning.
+	// The default is 30 days.
+	ReturningWindow time.Duration
+}
+
+// RulesLookup returns the Rules for a site ID.
+// It's called for every request, so the rules should be cached if they are loaded from a database.
+type RulesLookup func(uint64) Rules
+
+// get returns the validated Rules for given site ID or the default Rules if the lookup is nil.
+func (lookup RulesLookup) get(siteID uint64) Rules {
+	var rules Rules
+
+	if lookup != nil {
+		rules = lookup(siteID)
+	}
+
+	rules.validate()
+	return rules
+}
+
+func (rules *Rules) validate() {
+	// sessions are only looked up for the previous day's fingerprint
+	if rules.Timeout <= 0 || rules.Timeout > day {
+		rules.Timeout = sessionTimeout
+	}
+
+	if rules.MaxAge <= 0 || rules.MaxAge > day {
+		rules.MaxAge = sessionMaxAge
+	}
+
+	if rules.ReturningWindow <= 0 {
+		rules.ReturningWindow = returningWindow
+	}
+}
+
+func (rules *Rules) ignoreReferrer(referrer string) bool {
+	if len(rules.IgnoreReferrers) == 0 || referrer == "" {
+		return false
+	}
+
+	hostname := referrer
+
+	if u, err := url.Parse(referrer); err == nil && u.Hostname() != "" {
+		hostname = u.Hostname()
+	}
+
+	hostname = strings.ToLower(hostname)
+
+	for _, ignore := range rules.IgnoreReferrers {
+		ignore = strings.ToLower(ignore)
+
+		if hostname == ignore || strings.HasSuffix(hostname, "."+ignore) {
+			return true
+		}
+	}
+
+	return false
+}
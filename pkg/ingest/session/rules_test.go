@@ -0,0 +1,43 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRulesValidate(t *testing.T) {
+	rules := Rules{}
+	rules.validate()
+	assert.Equal(t, sessionTimeout, rules.Timeout)
+	assert.Equal(t, sessionMaxAge, rules.MaxAge)
+	assert.Equal(t, returningWindow, rules.ReturningWindow)
+	rules = Rules{Timeout: time.Hour, MaxAge: time.Hour * 12, ReturningWindow: time.Hour * 24 * 7}
+	rules.validate()
+	assert.Equal(t, time.Hour, rules.Timeout)
+	assert.Equal(t, time.Hour*12, rules.MaxAge)
+	assert.Equal(t, time.Hour*24*7, rules.ReturningWindow)
+
+	// sessions cannot be continued for more than a day, as the fingerprint changes daily
+	rules = Rules{Timeout: day + time.Minute, MaxAge: day * 2}
+	rules.validate()
+	assert.Equal(t, sessionTimeout, rules.Timeout)
+	assert.Equal(t, sessionMaxAge, rules.MaxAge)
+	rules = Rules{Timeout: day, MaxAge: day}
+	rules.validate()
+	assert.Equal(t, day, rules.Timeout)
+	assert.Equal(t, day, rules.MaxAge)
+}
+
+func TestRulesIgnoreReferrer(t *testing.T) {
+	rules := Rules{IgnoreReferrers: []string{"stripe.com", "PayPal.com"}}
+	assert.True(t, rules.ignoreReferrer("https://stripe.com"))
+	assert.True(t, rules.ignoreReferrer("https://checkout.stripe.com/pay?id=1"))
+	assert.True(t, rules.ignoreReferrer("https://www.paypal.com/"))
+	assert.True(t, rules.ignoreReferrer("stripe.com"))
+	assert.False(t, rules.ignoreReferrer("https://notstripe.com"))
+	assert.False(t, rules.ignoreReferrer("https://google.com"))
+	assert.False(t, rules.ignoreReferrer(""))
+	assert.False(t, new(Rules).ignoreReferrer("https://stripe.com"))
+}
@@ -0,0 +1,100 @@
+package session
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	day = time.Hour * 24
+)
+
+// SaltStore stores the daily fingerprint salts, so that they can be shared across nodes.
+type SaltStore interface {
+	// Salt returns the salt for given site ID and day.
+	// If there is no salt for the day yet, the given salt is stored until it expires and returned.
+	// Salts must not be removed when the sessions are cleared.
+	Salt(uint64, time.Time, string, time.Time) (string, error)
+}
+
+type saltKey struct {
+	siteID uint64
+	day    int64
+}
+
+type saltEntry struct {
+	salt    string
+	expires time.Time
+}
+
+// SaltManager generates a random fingerprint salt per site and day.
+// The salts are stored in the SaltStore (like the MemCache or RedisCache) and discarded after the session max age
+// of the site, so that fingerprints cannot be recreated afterward.
+type SaltManager struct {
+	store SaltStore
+	salts map[saltKey]saltEntry
+	m     sync.RWMutex
+}
+
+// NewSaltManager creates a new SaltManager for given store.
+func NewSaltManager(store SaltStore) *SaltManager {
+	return &SaltManager{
+		store: store,
+		salts: make(map[saltKey]saltEntry),
+	}
+}
+
+// Salt returns the salt for given site ID and day, kept for given session max age.
+// If the salt cannot be stored, an error is returned instead of a salt that might differ from other nodes.
+func (manager *SaltManager) Salt(siteID uint64, t time.Time, maxAge time.Duration) (string, error) {
+	key := saltKey{siteID, saltDay(t)}
+	manager.m.RLock()
+	entry, found := manager.salts[key]
+	manager.m.RUnlock()
+
+	if found {
+		return entry.salt, nil
+	}
+
+	manager.m.Lock()
+	defer manager.m.Unlock()
+
+	// another request might have fetched the salt in the meantime
+	if entry, found := manager.salts[key]; found {
+		return entry.salt, nil
+	}
+
+	expires := saltExpiry(key.day, maxAge)
+	salt, err := manager.store.Salt(siteID, t, rand.Text(), expires)
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	for k, v := range manager.salts {
+		if v.expires.Before(now) {
+			delete(manager.salts, k)
+		}
+	}
+
+	manager.salts[key] = saltEntry{salt, expires}
+	return salt, nil
+}
+
+// saltDay returns the number of days since the Unix epoch for given time at UTC.
+func saltDay(t time.Time) int64 {
+	return t.Unix() / int64(day.Seconds())
+}
+
+func getSaltKey(siteID uint64, t time.Time) string {
+	return fmt.Sprintf("salt_%d_%s", siteID, t.UTC().Format("20060102"))
+}
+
+// saltExpiry returns the time a salt for given day is no longer required, as sessions cannot be continued afterward.
+func saltExpiry(d int64, maxAge time.Duration) time.Time {
+	return time.Unix(d*int64(day.Seconds()), 0).Add(day + maxAge)
+}
@@ -0,0 +1,88 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/stretchr/testify/assert"
+)
+
+var errSaltStore = errors.New("salt store unavailable")
+
+type saltStoreErr struct{}
+
+func (store *saltStoreErr) Salt(uint64, time.Time, string, time.Time) (string, error) {
+	return "", errSaltStore
+}
+
+func TestSaltManager(t *testing.T) {
+	cache := NewMemCache(db.NewMock(), 10)
+	manager := NewSaltManager(cache)
+	now := time.Now().UTC()
+	today := salt(t, manager, 1, now, sessionMaxAge)
+	assert.Len(t, today, 26)
+	assert.Equal(t, today, salt(t, manager, 1, now, sessionMaxAge))
+	assert.NotEqual(t, today, salt(t, manager, 2, now, sessionMaxAge))
+	assert.NotEqual(t, today, salt(t, manager, 1, now.Add(-day), sessionMaxAge))
+
+	// a different node sharing the cache must use the same salts
+	other := NewSaltManager(cache)
+	assert.Equal(t, today, salt(t, other, 1, now, sessionMaxAge))
+	assert.Equal(t, salt(t, manager, 1, now.Add(-day), sessionMaxAge), salt(t, other, 1, now.Add(-day), sessionMaxAge))
+
+	// clearing the sessions must keep the salts
+	cache.Clear()
+	assert.Equal(t, today, salt(t, NewSaltManager(cache), 1, now, sessionMaxAge))
+}
+
+func TestSaltManagerExpired(t *testing.T) {
+	cache := NewMemCache(db.NewMock(), 10)
+	manager := NewSaltManager(cache)
+	now := time.Now().UTC()
+	old := salt(t, manager, 1, now.Add(-day*3), sessionMaxAge)
+	salt(t, manager, 1, now.Add(-day), sessionMaxAge)
+	today := salt(t, manager, 1, now, sessionMaxAge)
+
+	// salts older than the session max age must have been discarded
+	assert.Len(t, cache.salts, 2)
+	assert.Len(t, manager.salts, 2)
+	assert.NotEqual(t, old, salt(t, manager, 1, now.Add(-day*3), sessionMaxAge))
+	assert.Equal(t, today, salt(t, manager, 1, now, sessionMaxAge))
+
+	// salts of sites with a longer max age are kept longer
+	cache = NewMemCache(db.NewMock(), 10)
+	manager = NewSaltManager(cache)
+	salt(t, manager, 1, now.Add(-day*3), day*3)
+	salt(t, manager, 2, now.Add(-day*3), sessionMaxAge)
+	salt(t, manager, 1, now, sessionMaxAge)
+	assert.Len(t, cache.salts, 2)
+	assert.Len(t, manager.salts, 2)
+}
+
+func TestSaltManagerStoreError(t *testing.T) {
+	manager := NewSaltManager(&saltStoreErr{})
+	s, err := manager.Salt(1, time.Now(), sessionMaxAge)
+	assert.ErrorIs(t, err, errSaltStore)
+	assert.Empty(t, s)
+	assert.Empty(t, manager.salts)
+}
+
+func TestSaltExpiry(t *testing.T) {
+	now := time.Date(2025, 10, 10, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 10, 12, 0, 0, 0, 0, time.UTC), saltExpiry(saltDay(now), sessionMaxAge).UTC())
+	assert.Equal(t, time.Date(2025, 10, 11, 1, 0, 0, 0, time.UTC), saltExpiry(saltDay(now), time.Hour).UTC())
+}
+
+func salt(t *testing.T, manager *SaltManager, siteID uint64, now time.Time, maxAge time.Duration) string {
+	s, err := manager.Salt(siteID, now, maxAge)
+	assert.NoError(t, err)
+	return s
+}
+
+func assertSalt(t *testing.T, store SaltStore, siteID uint64, now time.Time, salt string, expires time.Time, expected string) {
+	s, err := store.Salt(siteID, now, salt, expires)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, s)
+}
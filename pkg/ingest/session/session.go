@@ -1,9 +1,11 @@
 package session
 
 import (
+	"errors"
 	"math"
 	"math/rand/v2"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dchest/siphash"
@@ -12,53 +14,95 @@ import (
 )
 
 const (
-	sessionTimeout = time.Minute * 30
-	sessionMaxAge  = time.Hour * 24
+	sessionTimeout  = time.Minute * 30
+	sessionMaxAge   = time.Hour * 24
+	returningWindow = time.Hour * 24 * 30
 )
 
+// ErrSessionLock is returned by the Session step if the lock for a session could not be acquired.
+var ErrSessionLock = errors.New("session lock could not be acquired")
+
 // Session manages visitor sessions and sets all relevant fields.
 // Therefore, this should be the last step in the pipeline.
 type Session struct {
 	fpKey0, fpKey1 uint64
 	fpSalt         string
+	salts          *SaltManager
 	cache          Cache
 	maxPageViews   uint16
+	rules          RulesLookup
 }
 
 // NewSession returns a new Session for the given sipHash parameters, cache, and options.
-func NewSession(fpKey0, fpKey1 uint64, fpSalt string, cache Cache, maxPageViews uint16) *Session {
+// The rules are looked up per site. If nil, the default Rules are used for all sites.
+func NewSession(fpKey0, fpKey1 uint64, fpSalt string, cache Cache, maxPageViews uint16, rules RulesLookup) *Session {
 	return &Session{
 		fpKey0:       fpKey0,
 		fpKey1:       fpKey1,
 		fpSalt:       fpSalt,
 		cache:        cache,
 		maxPageViews: maxPageViews,
+		rules:        rules,
 	}
 }
 
+// RotateSalts adds a random salt per site and day from the SaltManager to the fingerprint.
+// This makes it impossible to recreate fingerprints once the salts have been discarded.
+func (s *Session) RotateSalts(salts *SaltManager) *Session {
+	s.salts = salts
+	return s
+}
+
 // Step implements ingest.PipeStep to process a step.
 // It sets the sessions for the visitor and updates it if required.
 func (s *Session) Step(request *ingest.Request) (bool, error) {
-	// set the visitor ID (fingerprint) first
-	request.VisitorID = s.fingerprint(request.UserAgent, request.IP, request.Time)
+	// set the visitor ID (fingerprint or client ID) first
+	rules := s.getRules(request.SiteID)
+
+	if cache, ok := s.cache.(maxAgeCache); ok {
+		cache.extendMaxAge(rules.Timeout)
+	}
+
+	salt, err := s.salt(request, request.Time, &rules)
+
+	if err != nil {
+		return false, err
+	}
+
+	request.VisitorID = s.visitorID(request, salt, request.Time)
 
 	// get a lock and data for the session
 	m := s.cache.NewMutex(request.SiteID, request.VisitorID)
-	m.Lock()
-	maxAge := request.Time.Add(-sessionTimeout)
+
+	if err := lock(m); err != nil {
+		return false, err
+	}
+
+	maxAge := request.Time.Add(-rules.Timeout)
 	session := s.cache.Get(request.SiteID, request.VisitorID, maxAge)
 
 	// if the maximum session age reaches yesterday, we also need to check for the previous day (different fingerprint)
-	if session == nil && maxAge.Day() != request.Time.Day() {
+	// the client ID does not depend on the date, so this is only required for the fingerprint
+	if session == nil && request.ClientID == "" && maxAge.Day() != request.Time.Day() {
 		// unlock and try again
 		m.Unlock()
-		fingerprintYesterday := s.fingerprint(request.UserAgent, request.IP, maxAge)
+		saltYesterday, err := s.salt(request, maxAge, &rules)
+
+		if err != nil {
+			return false, err
+		}
+
+		fingerprintYesterday := s.fingerprint(request.UserAgent, request.IP, saltYesterday, maxAge)
 		m = s.cache.NewMutex(request.SiteID, fingerprintYesterday)
-		m.Lock()
+
+		if err := lock(m); err != nil {
+			return false, err
+		}
+
 		session = s.cache.Get(request.SiteID, fingerprintYesterday, maxAge)
 
 		if session != nil {
-			if session.Start.Before(request.Time.Add(-sessionMaxAge)) {
+			if session.Start.Before(request.Time.Add(-rules.MaxAge)) {
 				session = nil
 			} else {
 				request.VisitorID = fingerprintYesterday
@@ -80,7 +124,8 @@ func (s *Session) Step(request *ingest.Request) (bool, error) {
 
 	var cancelSession *model.Session
 
-	if session == nil || s.referrerOrCampaignChanged(request, session) {
+	if session == nil || s.referrerOrCampaignChanged(request, session, &rules) {
+		request.IsReturning = s.returning(request, session, &rules)
 		session = s.new(request)
 		s.cache.Put(request.SiteID, request.VisitorID, session)
 	} else {
@@ -104,17 +149,30 @@ func (s *Session) Step(request *ingest.Request) (bool, error) {
 
 func (s *Session) new(request *ingest.Request) *model.Session {
 	request.SessionID = rand.Uint32()
+	request.UserID = 0
+
+	if request.User != "" {
+		request.UserID = s.hash(request.User)
+	}
+
 	return &model.Session{
 		Data: model.Data{
 			SiteID:         request.SiteID,
 			VisitorID:      request.VisitorID,
 			SessionID:      request.SessionID,
+			IsReturning:    request.IsReturning,
+			UserID:         request.UserID,
 			Time:           request.Time,
 			Hostname:       request.Hostname,
 			Language:       request.Language,
 			CountryCode:    request.CountryCode,
 			Region:         request.Region,
 			City:           request.City,
+			ContinentCode:  request.ContinentCode,
+			TimeZone:       request.TimeZone,
+			Latitude:       request.Latitude,
+			Longitude:      request.Longitude,
+			InEU:           request.InEU,
 			Referrer:       request.Referrer,
 			ReferrerName:   request.ReferrerName,
 			ReferrerIcon:   request.ReferrerIcon,
@@ -186,13 +244,25 @@ func (s *Session) update(request *ingest.Request, session *model.Session) {
 	session.ExitPath = request.Path
 	session.ExitTitle = request.Title
 
+	// The user might authenticate during the session and is kept afterward.
+	if request.User != "" {
+		session.UserID = s.hash(request.User)
+	}
+
 	// Update the page view/event using the session data, so that it stays consistent across requests.
 	request.SessionID = session.SessionID
 	request.DurationSeconds = uint32(top)
+	request.IsReturning = session.IsReturning
+	request.UserID = session.UserID
 	request.Language = session.Language
 	request.CountryCode = session.CountryCode
 	request.Region = session.Region
 	request.City = session.City
+	request.ContinentCode = session.ContinentCode
+	request.TimeZone = session.TimeZone
+	request.Latitude = session.Latitude
+	request.Longitude = session.Longitude
+	request.InEU = session.InEU
 	request.Referrer = session.Referrer
 	request.ReferrerName = session.ReferrerName
 	request.ReferrerIcon = session.ReferrerIcon
@@ -210,12 +280,21 @@ func (s *Session) update(request *ingest.Request, session *model.Session) {
 	request.Channel = session.Channel
 }
 
-func (s *Session) referrerOrCampaignChanged(request *ingest.Request, session *model.Session) bool {
-	if request.Referrer != "" && request.Referrer != session.Referrer ||
-		request.ReferrerName != "" && request.ReferrerName != session.ReferrerName {
+func (s *Session) getRules(siteID uint64) Rules {
+	return s.rules.get(siteID)
+}
+
+func (s *Session) referrerOrCampaignChanged(request *ingest.Request, session *model.Session, rules *Rules) bool {
+	if !rules.KeepOnReferrerChange && !rules.ignoreReferrer(request.Referrer) &&
+		(request.Referrer != "" && request.Referrer != session.Referrer ||
+			request.ReferrerName != "" && request.ReferrerName != session.ReferrerName) {
 		return true
 	}
 
+	if rules.KeepOnCampaignChange {
+		return false
+	}
+
 	return (request.UTMSource != "" && request.UTMSource != session.UTMSource) ||
 		(request.UTMMedium != "" && request.UTMMedium != session.UTMMedium) ||
 		(request.UTMCampaign != "" && request.UTMCampaign != session.UTMCampaign) ||
@@ -223,11 +302,67 @@ func (s *Session) referrerOrCampaignChanged(request *ingest.Request, session *mo
 		(request.UTMTerm != "" && request.UTMTerm != session.UTMTerm)
 }
 
-func (s *Session) fingerprint(ua, ip string, now time.Time) uint64 {
+// returning checks whether a visitor identified by the ClientID has been seen within the Rules ReturningWindow.
+// The previous session is passed if it has been found, but the referrer or campaign changed.
+// Caches implementing the VisitorCache remember visitors, so that previous sessions don't need to be looked up.
+func (s *Session) returning(request *ingest.Request, previous *model.Session, rules *Rules) bool {
+	if request.ClientID == "" {
+		return false
+	}
+
+	if cache, ok := s.cache.(VisitorCache); ok {
+		return cache.Seen(request.SiteID, request.VisitorID, request.Time, rules.ReturningWindow) || previous != nil
+	}
+
+	if previous != nil {
+		return true
+	}
+
+	return s.cache.Get(request.SiteID, request.VisitorID, request.Time.Add(-rules.ReturningWindow)) != nil
+}
+
+func (s *Session) visitorID(request *ingest.Request, salt string, now time.Time) uint64 {
+	if request.ClientID != "" {
+		return s.hash(request.ClientID)
+	}
+
+	return s.fingerprint(request.UserAgent, request.IP, salt, now)
+}
+
+// salt returns the rotating salt for the site and day of the fingerprint, if salts are rotated.
+// The client ID does not depend on the date, so no salt is required.
+func (s *Session) salt(request *ingest.Request, now time.Time, rules *Rules) (string, error) {
+	if s.salts == nil || request.ClientID != "" {
+		return "", nil
+	}
+
+	return s.salts.Salt(request.SiteID, now, rules.MaxAge)
+}
+
+func (s *Session) hash(id string) uint64 {
+	var sb strings.Builder
+	sb.WriteString(id)
+	sb.WriteString(s.fpSalt)
+	return siphash.Hash(s.fpKey0, s.fpKey1, []byte(sb.String()))
+}
+
+func (s *Session) fingerprint(ua, ip, salt string, now time.Time) uint64 {
 	var sb strings.Builder
 	sb.WriteString(ua)
 	sb.WriteString(ip)
 	sb.WriteString(s.fpSalt)
+	sb.WriteString(salt)
 	sb.WriteString(now.Format("20060102"))
 	return siphash.Hash(s.fpKey0, s.fpKey1, []byte(sb.String()))
 }
+
+// lock locks the mutex and returns ErrSessionLock if a failingLocker could not be locked.
+func lock(m sync.Locker) error {
+	m.Lock()
+
+	if l, ok := m.(failingLocker); ok && l.Err() != nil {
+		return ErrSessionLock
+	}
+
+	return nil
+}
@@ -8,7 +8,9 @@ import (
 	"testing/synctest"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/pirsch-analytics/pirsch/v7/pkg"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
 	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest"
 	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
 	"github.com/stretchr/testify/assert"
@@ -17,7 +19,7 @@ import (
 func TestSession(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request
@@ -170,7 +172,7 @@ func TestSession(t *testing.T) {
 func TestSessionBounced(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request
@@ -208,7 +210,7 @@ func TestSessionBounced(t *testing.T) {
 func TestSessionEventNonInteractive(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request
@@ -266,7 +268,7 @@ func TestSessionEventNonInteractive(t *testing.T) {
 func TestSessionReferrerReset(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request
@@ -328,7 +330,7 @@ func TestSessionReferrerReset(t *testing.T) {
 func TestSessionUTMReset(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request
@@ -400,7 +402,7 @@ func TestSessionUTMReset(t *testing.T) {
 func TestSessionReferrerHostname(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request
@@ -462,7 +464,7 @@ func TestSessionReferrerHostname(t *testing.T) {
 func TestSessionTimeout(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request
@@ -492,7 +494,7 @@ func TestSessionTimeout(t *testing.T) {
 func TestSessionMaxAge(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request at 23:45 UTC
@@ -530,7 +532,7 @@ func TestSessionMaxAge(t *testing.T) {
 func TestSessionUpdateSession(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request
@@ -564,7 +566,7 @@ func TestSessionUpdateSession(t *testing.T) {
 func TestSessionYesterday(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make the first request at 23:45 UTC
@@ -595,7 +597,7 @@ func TestSessionYesterday(t *testing.T) {
 func TestSessionMaxPageViews(t *testing.T) {
 	// create an in-memory cache and session step with a maximum of 10 page views
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 10)
+	s := NewSession(1, 2, "salt", cache, 10, nil)
 
 	synctest.Test(t, func(t *testing.T) {
 		// make exactly 10 requests
@@ -629,7 +631,7 @@ func TestSessionMaxPageViews(t *testing.T) {
 func TestSessionOverwriteTime(t *testing.T) {
 	// create an in-memory cache and session step
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 
 	// create a new session five minutes ago
 	fiveMinAgo := time.Now().UTC().Add(-time.Minute * 5)
@@ -662,17 +664,259 @@ func TestSessionOverwriteTime(t *testing.T) {
 
 func TestSessionFingerprint(t *testing.T) {
 	cache := NewMemCache(client, 100)
-	s := NewSession(1, 2, "salt", cache, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
 	now := time.Now().UTC()
-	fp1 := s.fingerprint("ua", "81.2.69.142", now)
-	fp2 := s.fingerprint("ua", "81.2.69.142", now)
-	fp3 := s.fingerprint("ua", "2001:9e8:d5d2:b00:ce0a:96e4:ae42:c935", now)
-	fp4 := s.fingerprint("ua2", "81.2.69.142", now)
-	fp5 := s.fingerprint("ua", "81.2.69.142", now.Add(time.Hour*25))
+	fp1 := s.fingerprint("ua", "81.2.69.142", "", now)
+	fp2 := s.fingerprint("ua", "81.2.69.142", "", now)
+	fp3 := s.fingerprint("ua", "2001:9e8:d5d2:b00:ce0a:96e4:ae42:c935", "", now)
+	fp4 := s.fingerprint("ua2", "81.2.69.142", "", now)
+	fp5 := s.fingerprint("ua", "81.2.69.142", "", now.Add(time.Hour*25))
 	assert.Equal(t, fp1, fp2)
 	assert.NotEqual(t, fp1, fp3)
 	assert.NotEqual(t, fp1, fp4)
 	assert.NotEqual(t, fp1, fp5)
+
+	// the daily salt must change the fingerprint per day and site
+	s.RotateSalts(NewSaltManager(NewMemCache(client, 100)))
+	rules := s.getRules(1)
+	salt1, err := s.salt(&ingest.Request{SiteID: 1}, now, &rules)
+	assert.NoError(t, err)
+	salt2, err := s.salt(&ingest.Request{SiteID: 2}, now, &rules)
+	assert.NoError(t, err)
+	salt3, err := s.salt(&ingest.Request{SiteID: 1, ClientID: "client"}, now, &rules)
+	assert.NoError(t, err)
+	fp6 := s.fingerprint("ua", "81.2.69.142", salt1, now)
+	fp7 := s.fingerprint("ua", "81.2.69.142", salt1, now)
+	fp8 := s.fingerprint("ua", "81.2.69.142", salt2, now)
+	assert.Equal(t, fp6, fp7)
+	assert.NotEqual(t, fp1, fp6)
+	assert.NotEqual(t, fp6, fp8)
+	assert.Empty(t, salt3)
+}
+
+func TestSessionSaltError(t *testing.T) {
+	// the request must fail instead of using a salt that differs from other nodes
+	s := NewSession(1, 2, "salt", NewMemCache(client, 100), 100, nil).RotateSalts(NewSaltManager(&saltStoreErr{}))
+	request := &ingest.Request{SiteID: 1, Time: time.Now().UTC(), UserAgent: "ua", IP: "81.2.69.142"}
+	cancel, err := s.Step(request)
+	assert.ErrorIs(t, err, errSaltStore)
+	assert.False(t, cancel)
+	assert.Zero(t, request.VisitorID)
+	assert.Nil(t, request.Session)
+}
+
+func TestSessionLockError(t *testing.T) {
+	// the request must fail instead of creating a duplicate session without holding the lock
+	cache := NewRedisCache(RedisCacheOptions{
+		Redis: &redis.UniversalOptions{
+			Addrs: []string{"localhost:1"},
+		},
+		Timeout:   time.Millisecond * 100,
+		LockTries: 1,
+	})
+	defer func() {
+		assert.NoError(t, cache.Close())
+	}()
+	s := NewSession(1, 2, "salt", cache, 100, nil)
+	request := &ingest.Request{SiteID: 1, Time: time.Now().UTC(), UserAgent: "ua", IP: "81.2.69.142"}
+	cancel, err := s.Step(request)
+	assert.ErrorIs(t, err, ErrSessionLock)
+	assert.False(t, cancel)
+	assert.Nil(t, request.Session)
+}
+
+func TestSessionRules(t *testing.T) {
+	// create an in-memory cache and session step with a longer timeout for the first site
+	cache := NewMemCache(client, 100)
+	s := NewSession(1, 2, "salt", cache, 100, func(siteID uint64) Rules {
+		if siteID == 1 {
+			return Rules{
+				Timeout:              time.Hour,
+				KeepOnCampaignChange: true,
+				IgnoreReferrers:      []string{"stripe.com"},
+			}
+		}
+
+		return Rules{}
+	})
+
+	synctest.Test(t, func(t *testing.T) {
+		// make the first request
+		req, _ := newSampleRequest()
+		cancel, err := s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		sessionID := req.Session.SessionID
+
+		// the session must be continued after the default timeout, a campaign change, and an ignored referrer
+		time.Sleep(sessionTimeout + time.Minute)
+		synctest.Wait()
+		req, _ = newSampleRequest()
+		req.UTMCampaign = "other"
+		req.Referrer = "https://checkout.stripe.com/pay"
+		req.ReferrerName = "Stripe"
+		cancel, err = s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		assert.NotNil(t, req.CancelSession)
+		assert.Equal(t, sessionID, req.Session.SessionID)
+		assert.Equal(t, "https://google.com", req.Session.Referrer)
+		assert.Equal(t, "utm_campaign", req.Session.UTMCampaign)
+
+		// a different referrer must still start a new session
+		time.Sleep(time.Minute)
+		synctest.Wait()
+		req, _ = newSampleRequest()
+		req.Referrer = "https://bing.com"
+		req.ReferrerName = "Bing"
+		cancel, err = s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		assert.Nil(t, req.CancelSession)
+		assert.NotEqual(t, sessionID, req.Session.SessionID)
+
+		// other sites must use the defaults
+		req, _ = newSampleRequest()
+		req.SiteID = 2
+		cancel, err = s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		sessionID = req.Session.SessionID
+		time.Sleep(sessionTimeout + time.Minute)
+		synctest.Wait()
+		req, _ = newSampleRequest()
+		req.SiteID = 2
+		cancel, err = s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		assert.Nil(t, req.CancelSession)
+		assert.NotEqual(t, sessionID, req.Session.SessionID)
+	})
+}
+
+func TestSessionClientID(t *testing.T) {
+	// create an in-memory cache and session step
+	cache := NewMemCache(client, 100)
+	s := NewSession(1, 2, "salt", cache, 100, nil)
+
+	synctest.Test(t, func(t *testing.T) {
+		// make the first request using a client ID
+		req, _ := newSampleRequest()
+		req.ClientID = "client"
+		cancel, err := s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		assert.False(t, req.IsReturning)
+		assert.False(t, req.Session.IsReturning)
+		assert.Equal(t, s.hash("client"), req.VisitorID)
+
+		// the visitor returns two days later from a different IP
+		visitorID := req.Session.VisitorID
+		sessionID := req.Session.SessionID
+		time.Sleep(time.Hour * 48)
+		synctest.Wait()
+		req, _ = newSampleRequest()
+		req.ClientID = "client"
+		req.IP = "2001:9e8:d5d2:b00:ce0a:96e4:ae42:c935"
+		cancel, err = s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+
+		// the request must have created a new session for the same visitor
+		assert.NotNil(t, req.Session)
+		assert.Nil(t, req.CancelSession)
+		assert.Equal(t, visitorID, req.Session.VisitorID)
+		assert.NotEqual(t, sessionID, req.Session.SessionID)
+		assert.True(t, req.IsReturning)
+		assert.True(t, req.Session.IsReturning)
+
+		// page views within the session must be returning too
+		time.Sleep(time.Minute)
+		synctest.Wait()
+		req, _ = newSampleRequest()
+		req.ClientID = "client"
+		req.Path = "/about"
+		cancel, err = s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		assert.NotNil(t, req.CancelSession)
+		assert.True(t, req.IsReturning)
+
+		// visitors without a client ID are never returning
+		req, _ = newSampleRequest()
+		req.UserAgent = "other"
+		cancel, err = s.Step(req)
+		assert.False(t, cancel)
+		assert.NoError(t, err)
+		assert.False(t, req.IsReturning)
+		assert.NotEqual(t, visitorID, req.VisitorID)
+	})
+}
+
+func TestSessionHash(t *testing.T) {
+	s := NewSession(1, 2, "salt", nil, 100, nil)
+	assert.Equal(t, s.hash("client"), s.hash("client"))
+	assert.NotEqual(t, s.hash("client"), s.hash("client2"))
+	assert.NotEqual(t, s.hash("client"), NewSession(1, 3, "salt", nil, 100, nil).hash("client"))
+	assert.NotEqual(t, s.hash("client"), NewSession(1, 2, "pepper", nil, 100, nil).hash("client"))
+}
+
+func TestSessionReturningWindow(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		// the sessions time out, so that the visitor is recognized by the seen marker
+		cache := NewMemCache(db.NewMock(), 100)
+		defer cache.Close()
+		s := NewSession(1, 2, "salt", cache, 100, func(uint64) Rules {
+			return Rules{ReturningWindow: time.Hour * 24}
+		})
+		req, _ := newSampleRequest()
+		req.ClientID = "client"
+		_, err := s.Step(req)
+		assert.NoError(t, err)
+		assert.False(t, req.IsReturning)
+
+		// the visitor returns within the window
+		time.Sleep(time.Hour * 12)
+		synctest.Wait()
+		req, _ = newSampleRequest()
+		req.ClientID = "client"
+		_, err = s.Step(req)
+		assert.NoError(t, err)
+		assert.True(t, req.IsReturning)
+
+		// the previous session is outside the window
+		time.Sleep(time.Hour * 25)
+		synctest.Wait()
+		req, _ = newSampleRequest()
+		req.ClientID = "client"
+		_, err = s.Step(req)
+		assert.NoError(t, err)
+		assert.False(t, req.IsReturning)
+	})
+}
+
+func TestSessionRulesCache(t *testing.T) {
+	// the MemCache must keep sessions for the longest timeout and salts for the max age of the site
+	cache := NewMemCache(db.NewMock(), 100)
+	defer cache.Close()
+	s := NewSession(1, 2, "salt", cache, 100, func(siteID uint64) Rules {
+		if siteID == 1 {
+			return Rules{Timeout: time.Hour * 2, MaxAge: time.Hour * 3}
+		}
+
+		return Rules{}
+	}).RotateSalts(NewSaltManager(cache))
+	req, now := newSampleRequest()
+	_, err := s.Step(req)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour*2, cache.ttl)
+	assert.Equal(t, saltExpiry(saltDay(now), time.Hour*3), cache.salts[saltKey{1, saltDay(now)}].expires)
+	req, _ = newSampleRequest()
+	req.SiteID = 2
+	_, err = s.Step(req)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour*2, cache.ttl)
+	assert.Equal(t, saltExpiry(saltDay(now), sessionMaxAge), cache.salts[saltKey{2, saltDay(now)}].expires)
 }
 
 func newSampleRequest() (*ingest.Request, time.Time) {
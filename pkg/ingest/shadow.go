@@ -0,0 +1,49 @@
+package ingest
+
+import (
+	"slices"
+)
+
+// Shadow runs a bot filter step in shadow (dry-run) mode.
+// Instead of cancelling a request, it records the decision in Request.ShadowBotReason and lets the request pass.
+// This can be used to trial new filter rules on production traffic before enforcing them.
+type Shadow struct {
+	step    PipeStep
+	reasons []string
+}
+
+// NewShadow returns a new Shadow step for the given step.
+// If no reasons are passed, all decisions of the step are shadowed.
+// Otherwise, only the given reasons (like "http11-sf") are shadowed and all other decisions are enforced.
+// Note that the step stops at the first matching rule, so a shadowed rule can hide enforced rules checked afterward.
+func NewShadow(step PipeStep, reasons ...string) *Shadow {
+	return &Shadow{
+		step:    step,
+		reasons: reasons,
+	}
+}
+
+// Step implements ingest.PipeStep to process a step.
+func (s *Shadow) Step(request *Request) (bool, error) {
+	isBot, botReason := request.IsBot, request.BotReason
+	session, cancelSession, retractSession := request.Session, request.CancelSession, request.RetractSession
+	cancel, err := s.step.Step(request)
+
+	if err != nil || !cancel {
+		return false, err
+	}
+
+	if len(s.reasons) > 0 && !slices.Contains(s.reasons, request.BotReason) {
+		return true, nil
+	}
+
+	// keep the first shadow decision in case multiple steps are shadowed
+	if request.ShadowBotReason == "" {
+		request.ShadowBotReason = request.BotReason
+	}
+
+	// the session must not be removed by the step either
+	request.IsBot, request.BotReason = isBot, botReason
+	request.Session, request.CancelSession, request.RetractSession = session, cancelSession, retractSession
+	return false, nil
+}
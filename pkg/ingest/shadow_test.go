@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadow(t *testing.T) {
+	shadow := NewShadow(&botStep{})
+	request := newTestRequest("/bot")
+	cancel, err := shadow.Step(request)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.False(t, request.IsBot)
+	assert.Empty(t, request.BotReason)
+	assert.Equal(t, "path", request.ShadowBotReason)
+	request = newTestRequest("/")
+	cancel, err = shadow.Step(request)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Empty(t, request.ShadowBotReason)
+}
+
+func TestShadowRetractSession(t *testing.T) {
+	request := newTestRequest("/")
+	session, cancelSession := new(model.Session), new(model.Session)
+	request.Session, request.CancelSession = session, cancelSession
+	cancel, err := NewShadow(&retractSessionStep{}).Step(request)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Equal(t, "retract", request.ShadowBotReason)
+	assert.Same(t, session, request.Session)
+	assert.Same(t, cancelSession, request.CancelSession)
+	assert.False(t, request.RetractSession)
+}
+
+func TestShadowReasons(t *testing.T) {
+	request := newTestRequest("/bot")
+	cancel, err := NewShadow(&botStep{}, "other").Step(request)
+	assert.NoError(t, err)
+	assert.True(t, cancel)
+	assert.True(t, request.IsBot)
+	assert.Equal(t, "path", request.BotReason)
+	assert.Empty(t, request.ShadowBotReason)
+	request = newTestRequest("/bot")
+	cancel, err = NewShadow(&botStep{}, "other", "path").Step(request)
+	assert.NoError(t, err)
+	assert.False(t, cancel)
+	assert.Equal(t, "path", request.ShadowBotReason)
+}
+
+func TestShadowPipe(t *testing.T) {
+	storage := db.NewMock()
+	pipe := NewPipe(PipeOptions{
+		Storage: storage,
+	}).Use(NewShadow(&botStep{}), &sessionStep{})
+	assert.NoError(t, pipe.Process(newTestRequest("/")))
+	assert.NoError(t, pipe.Process(newTestRequest("/bot")))
+	pipe.Stop()
+	assert.Len(t, storage.PageViews(), 2)
+	requests := storage.Requests()
+	assert.Len(t, requests, 2)
+	shadowed := 0
+
+	for _, request := range requests {
+		assert.False(t, request.Bot)
+		assert.Empty(t, request.BotReason)
+
+		if request.ShadowBotReason == "path" {
+			shadowed++
+		}
+	}
+
+	assert.Equal(t, 1, shadowed)
+}
+
+type retractSessionStep struct{}
+
+func (s *retractSessionStep) Step(request *Request) (bool, error) {
+	request.BotReason = "retract"
+	request.Session = nil
+	request.RetractSession = true
+	return true, nil
+}
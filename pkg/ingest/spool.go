@@ -0,0 +1,438 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+)
+
+const (
+	spoolSessions  = "sessions"
+	spoolPageViews = "page_views"
+	spoolEvents    = "events"
+	spoolRequests  = "requests"
+	spoolCrawlers  = "crawlers"
+	spoolBatch     = "batch"
+
+	spoolFileExt        = ".json"
+	spoolTmpFileExt     = ".tmp"
+	defaultSpoolMaxSize = 1024 * 1024 * 512 // 512 MB
+)
+
+var (
+	// ErrSpoolFull is returned if a batch cannot be written to the Spool, because it would exceed the maximum size.
+	ErrSpoolFull = errors.New("spool full")
+)
+
+type spoolBatchData struct {
+	Sessions  []model.Session  `json:"sessions,omitempty"`
+	PageViews []model.PageView `json:"page_views,omitempty"`
+	Events    []model.Event    `json:"events,omitempty"`
+	Requests  []model.Request  `json:"requests,omitempty"`
+	Crawlers  []model.Crawler  `json:"crawlers,omitempty"`
+}
+
+// SpoolEntry is a batch persisted in the Spool.
+type SpoolEntry struct {
+	// Name is the filename of the batch.
+	Name string
+
+	// Kind is the type of data stored in the batch (sessions, page_views, events, requests, crawlers, or batch for mixed data).
+	Kind string
+
+	// Size is the size of the batch in bytes.
+	Size int64
+
+	// Time is the time the batch has been persisted.
+	Time time.Time
+}
+
+// Spool is a write-ahead spool for batches that could not be saved to the db.Storage.
+// Batches are persisted as files in a directory and can be replayed once the db.Storage has recovered.
+type Spool struct {
+	dir       string
+	maxSize   int64
+	size      int64
+	count     int
+	seq       atomic.Uint64
+	replaying atomic.Bool
+	m         sync.Mutex
+}
+
+// NewSpool creates a new Spool for the given directory and maximum size in bytes.
+// The directory will be created if it doesn't exist.
+// If the maximum size is <= 0, the default of 512 MB will be used.
+func NewSpool(dir string, maxSize int64) (*Spool, error) {
+	if dir == "" {
+		return nil, errors.New("spool directory missing")
+	}
+
+	if maxSize <= 0 {
+		maxSize = defaultSpoolMaxSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// remove partially written batches left behind by a crash
+	tmpFiles, err := filepath.Glob(filepath.Join(dir, "*"+spoolTmpFileExt))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range tmpFiles {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	spool := &Spool{
+		dir:     dir,
+		maxSize: maxSize,
+	}
+	entries, err := spool.Entries()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		spool.size += entry.Size
+		spool.count++
+	}
+
+	return spool, nil
+}
+
+// Entries returns all batches currently persisted in the Spool, ordered by time.
+func (spool *Spool) Entries() ([]SpoolEntry, error) {
+	files, err := os.ReadDir(spool.dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]SpoolEntry, 0, len(files))
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != spoolFileExt {
+			continue
+		}
+
+		ns, kind, ok := spool.parseFilename(file.Name())
+
+		if !ok {
+			continue
+		}
+
+		info, err := file.Info()
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, SpoolEntry{
+			Name: file.Name(),
+			Kind: kind,
+			Size: info.Size(),
+			Time: time.Unix(0, ns).UTC(),
+		})
+	}
+
+	// the filename starts with a fixed length timestamp and sequence number
+	slices.SortFunc(entries, func(a, b SpoolEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries, nil
+}
+
+// Len returns the number of batches in the Spool.
+func (spool *Spool) Len() int {
+	spool.m.Lock()
+	defer spool.m.Unlock()
+	return spool.count
+}
+
+// Size returns the total size of all batches in the Spool in bytes.
+func (spool *Spool) Size() int64 {
+	spool.m.Lock()
+	defer spool.m.Unlock()
+	return spool.size
+}
+
+// Purge removes all batches from the Spool.
+func (spool *Spool) Purge() error {
+	entries, err := spool.Entries()
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := spool.remove(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replay saves all batches in the Spool to given db.Storage in the order they have been persisted.
+// Batches are removed from the Spool after they have been saved successfully.
+// Batches of mixed data are saved one table at a time and the saved data is removed from the batch right away,
+// so that it won't be saved twice if saving another table fails.
+// Replay stops on the first error and returns the number of batches replayed.
+func (spool *Spool) Replay(ctx context.Context, storage db.Storage) (int, error) {
+	// only one replay can run at a time, so that batches are not saved twice
+	if !spool.replaying.CompareAndSwap(false, true) {
+		return 0, nil
+	}
+
+	defer spool.replaying.Store(false)
+	entries, err := spool.Entries()
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+
+		data, err := os.ReadFile(filepath.Join(spool.dir, entry.Name))
+
+		if err != nil {
+			return i, err
+		}
+
+		if err := spool.save(ctx, storage, &entry, data); err != nil {
+			return i, fmt.Errorf("error replaying spool entry %s: %w", entry.Name, err)
+		}
+
+		if err := spool.remove(entry); err != nil {
+			return i, err
+		}
+	}
+
+	return len(entries), nil
+}
+
+func (spool *Spool) save(ctx context.Context, storage db.Storage, entry *SpoolEntry, data []byte) error {
+	switch entry.Kind {
+	case spoolSessions:
+		var sessions []model.Session
+
+		if err := json.Unmarshal(data, &sessions); err != nil {
+			return err
+		}
+
+		return storage.SaveSessions(ctx, sessions)
+	case spoolPageViews:
+		var pageViews []model.PageView
+
+		if err := json.Unmarshal(data, &pageViews); err != nil {
+			return err
+		}
+
+		return storage.SavePageViews(ctx, pageViews)
+	case spoolEvents:
+		var events []model.Event
+
+		if err := json.Unmarshal(data, &events); err != nil {
+			return err
+		}
+
+		return storage.SaveEvents(ctx, events)
+	case spoolRequests:
+		var requests []model.Request
+
+		if err := json.Unmarshal(data, &requests); err != nil {
+			return err
+		}
+
+		return storage.SaveRequests(ctx, requests)
+	case spoolCrawlers:
+		var crawlers []model.Crawler
+
+		if err := json.Unmarshal(data, &crawlers); err != nil {
+			return err
+		}
+
+		return db.SaveCrawlers(ctx, storage, crawlers)
+	case spoolBatch:
+		return spool.saveBatch(ctx, storage, entry, data)
+	default:
+		return fmt.Errorf("unknown spool entry type: %s", entry.Kind)
+	}
+}
+
+func (spool *Spool) saveBatch(ctx context.Context, storage db.Storage, entry *SpoolEntry, data []byte) error {
+	var batch spoolBatchData
+
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return err
+	}
+
+	parts := []func() (bool, error){
+		func() (bool, error) { return saveSpoolPart(ctx, &batch.Sessions, storage.SaveSessions) },
+		func() (bool, error) { return saveSpoolPart(ctx, &batch.PageViews, storage.SavePageViews) },
+		func() (bool, error) { return saveSpoolPart(ctx, &batch.Events, storage.SaveEvents) },
+		func() (bool, error) { return saveSpoolPart(ctx, &batch.Requests, storage.SaveRequests) },
+		func() (bool, error) {
+			return saveSpoolPart(ctx, &batch.Crawlers, func(ctx context.Context, crawlers []model.Crawler) error {
+				return db.SaveCrawlers(ctx, storage, crawlers)
+			})
+		},
+	}
+
+	for i, save := range parts {
+		saved, err := save()
+
+		if err != nil {
+			return err
+		}
+
+		// the entry is removed after the last part has been saved
+		if saved && i < len(parts)-1 {
+			out, err := json.Marshal(batch)
+
+			if err != nil {
+				return err
+			}
+
+			if err := spool.rewrite(entry, out); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (spool *Spool) write(kind string, data []byte) error {
+	spool.m.Lock()
+	defer spool.m.Unlock()
+	size := int64(len(data))
+
+	if spool.size+size > spool.maxSize {
+		return ErrSpoolFull
+	}
+
+	name := fmt.Sprintf("%020d_%010d_%s%s", time.Now().UnixNano(), spool.seq.Add(1), kind, spoolFileExt)
+
+	if err := writeSpoolFile(filepath.Join(spool.dir, name), data); err != nil {
+		return err
+	}
+
+	spool.size += size
+	spool.count++
+	return nil
+}
+
+func (spool *Spool) rewrite(entry *SpoolEntry, data []byte) error {
+	spool.m.Lock()
+	defer spool.m.Unlock()
+
+	if err := writeSpoolFile(filepath.Join(spool.dir, entry.Name), data); err != nil {
+		return err
+	}
+
+	size := int64(len(data))
+	spool.size = max(spool.size+size-entry.Size, 0)
+	entry.Size = size
+	return nil
+}
+
+func (spool *Spool) remove(entry SpoolEntry) error {
+	spool.m.Lock()
+	defer spool.m.Unlock()
+
+	if err := os.Remove(filepath.Join(spool.dir, entry.Name)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	spool.size = max(spool.size-entry.Size, 0)
+	spool.count = max(spool.count-1, 0)
+	return nil
+}
+
+func (spool *Spool) parseFilename(name string) (int64, string, bool) {
+	parts := strings.SplitN(strings.TrimSuffix(name, spoolFileExt), "_", 3)
+
+	if len(parts) != 3 {
+		return 0, "", false
+	}
+
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+
+	if err != nil {
+		return 0, "", false
+	}
+
+	return ns, parts[2], true
+}
+
+// writeSpoolFile writes to a temporary file first, so that a crash cannot leave a partial batch behind.
+func writeSpoolFile(path string, data []byte) error {
+	if err := os.WriteFile(path+spoolTmpFileExt, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+spoolTmpFileExt, path)
+}
+
+func saveSpoolPart[T any](ctx context.Context, data *[]T, save func(context.Context, []T) error) (bool, error) {
+	if len(*data) == 0 {
+		return false, nil
+	}
+
+	if err := save(ctx, *data); err != nil {
+		return false, err
+	}
+
+	*data = nil
+	return true, nil
+}
+
+func spoolFunc[T any](spool *Spool, kind string, data []T) func() error {
+	if spool == nil || len(data) == 0 {
+		return nil
+	}
+
+	return func() error {
+		return writeSpool(spool, kind, data)
+	}
+}
+
+func writeSpool[T any](spool *Spool, kind string, data []T) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	out, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	return spool.write(kind, out)
+}
@@ -0,0 +1,234 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/db"
+	"github.com/pirsch-analytics/pirsch/v7/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpool(t *testing.T) {
+	// create a spool and write a few batches
+	dir := t.TempDir()
+	spool, err := NewSpool(dir, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, writeSpool(spool, spoolSessions, []model.Session{{ExitPath: "/"}}))
+	assert.NoError(t, writeSpool(spool, spoolPageViews, []model.PageView{{Path: "/"}, {Path: "/foo"}}))
+	assert.NoError(t, writeSpool(spool, spoolEvents, []model.Event{{Name: "event"}}))
+	assert.NoError(t, writeSpool(spool, spoolRequests, []model.Request{{Path: "/"}}))
+	assert.NoError(t, writeSpool(spool, spoolRequests, []model.Request{}))
+	assert.Equal(t, 4, spool.Len())
+	assert.NotZero(t, spool.Size())
+
+	// the entries must be ordered by time
+	entries, err := spool.Entries()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+	assert.Equal(t, spoolSessions, entries[0].Kind)
+	assert.Equal(t, spoolPageViews, entries[1].Kind)
+	assert.Equal(t, spoolEvents, entries[2].Kind)
+	assert.Equal(t, spoolRequests, entries[3].Kind)
+	assert.False(t, entries[0].Time.IsZero())
+
+	// reopening the spool must restore the size
+	size := spool.Size()
+	spool, err = NewSpool(dir, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, spool.Len())
+	assert.Equal(t, size, spool.Size())
+
+	// replay the batches to the storage
+	storage := db.NewMock()
+	n, err := spool.Replay(context.Background(), storage)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Len(t, storage.Sessions(), 1)
+	assert.Len(t, storage.PageViews(), 2)
+	assert.Len(t, storage.Events(), 1)
+	assert.Len(t, storage.Requests(), 1)
+	assert.Zero(t, spool.Len())
+	assert.Zero(t, spool.Size())
+
+	// purge the spool
+	assert.NoError(t, writeSpool(spool, spoolSessions, []model.Session{{ExitPath: "/"}}))
+	assert.Equal(t, 1, spool.Len())
+	assert.NoError(t, spool.Purge())
+	assert.Zero(t, spool.Len())
+	entries, err = spool.Entries()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, writeSpool(spool, spoolRequests, []model.Request{{Path: "/"}}))
+	spool.maxSize = spool.Size() * 3 / 2
+	assert.ErrorIs(t, writeSpool(spool, spoolRequests, []model.Request{{Path: "/"}}), ErrSpoolFull)
+	assert.Equal(t, 1, spool.Len())
+}
+
+func TestSpoolReplayError(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, writeSpool(spool, spoolSessions, []model.Session{{ExitPath: "/"}}))
+	assert.NoError(t, writeSpool(spool, spoolPageViews, []model.PageView{{Path: "/"}}))
+
+	// the batches must be kept if the storage fails
+	storage := newStorageWithError(errors.New("error on save"))
+	n, err := spool.Replay(context.Background(), storage)
+	assert.Error(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, 2, spool.Len())
+
+	// and replayed once it recovered
+	storage.setErrorOnSave(nil)
+	n, err = spool.Replay(context.Background(), storage)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, storage.Sessions(), 1)
+	assert.Len(t, storage.PageViews(), 1)
+}
+
+func TestSpoolRemoveTmpFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001_0000000001_sessions.json.tmp"), []byte("[{"), 0644))
+	spool, err := NewSpool(dir, 0)
+	assert.NoError(t, err)
+	assert.Zero(t, spool.Len())
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestSpoolReplayBatchPartially(t *testing.T) {
+	spool, err := NewSpool(t.TempDir(), 0)
+	assert.NoError(t, err)
+	data, err := json.Marshal(spoolBatchData{
+		Sessions:  []model.Session{{ExitPath: "/"}},
+		PageViews: []model.PageView{{Path: "/"}},
+		Requests:  []model.Request{{Path: "/"}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, spool.write(spoolBatch, data))
+
+	// the sessions must be saved only once if saving the page views fails
+	storage := &storageWithPageViewError{Mock: db.NewMock(), err: errors.New("error on save")}
+	n, err := spool.Replay(context.Background(), storage)
+	assert.Error(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, 1, spool.Len())
+	assert.Less(t, spool.Size(), int64(len(data)))
+	assert.Len(t, storage.Sessions(), 1)
+	storage.err = nil
+	n, err = spool.Replay(context.Background(), storage)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, storage.Sessions(), 1)
+	assert.Len(t, storage.PageViews(), 1)
+	assert.Len(t, storage.Requests(), 1)
+	assert.Zero(t, spool.Len())
+	assert.Zero(t, spool.Size())
+}
+
+func TestPipeSpool(t *testing.T) {
+	dir := t.TempDir()
+
+	synctest.Test(t, func(t *testing.T) {
+		// create a pipeline with failing storage
+		storage := newStorageWithError(errors.New("error on save"))
+		pipe := NewPipe(PipeOptions{
+			Storage:       storage,
+			Worker:        1,
+			WorkerTimeout: time.Second * 5,
+			SpoolDir:      dir,
+		}).Use(&sessionStep{})
+
+		// create a sample request
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/146.0.0.0 Safari/537.36")
+		assert.NoError(t, pipe.Process(&Request{
+			Request: req,
+		}))
+
+		// the batches must have been spooled while still retrying
+		time.Sleep(time.Second * 10)
+		synctest.Wait()
+		pipe.Stop()
+		assert.Empty(t, storage.Sessions())
+		assert.Empty(t, storage.PageViews())
+		assert.Equal(t, 2, pipe.Spool().Len())
+	})
+
+	synctest.Test(t, func(t *testing.T) {
+		// the spooled data must be replayed on the next start
+		storage := db.NewMock()
+		pipe := NewPipe(PipeOptions{
+			Storage:  storage,
+			SpoolDir: dir,
+		})
+		synctest.Wait()
+		pipe.Stop()
+		assert.Len(t, storage.Sessions(), 1)
+		assert.Len(t, storage.PageViews(), 1)
+		assert.Zero(t, pipe.Spool().Len())
+	})
+}
+
+func TestPipeSpoolRecovery(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		// create a pipeline with failing storage
+		storage := newStorageWithError(errors.New("error on save"))
+		pipe := NewPipe(PipeOptions{
+			Storage:       storage,
+			Worker:        1,
+			WorkerTimeout: time.Second * 5,
+			SpoolDir:      t.TempDir(),
+		}).Use(&sessionStep{})
+		defer pipe.Stop()
+
+		// create a sample request
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/146.0.0.0 Safari/537.36")
+		assert.NoError(t, pipe.Process(&Request{
+			Request: req,
+		}))
+
+		// the batches must have been spooled after exhausting the maximum number of retries
+		time.Sleep(time.Second * 300)
+		synctest.Wait()
+		assert.Empty(t, storage.Sessions())
+		assert.Empty(t, storage.PageViews())
+		assert.Equal(t, 2, pipe.Spool().Len())
+
+		// the spool must be replayed once the storage recovered
+		storage.setErrorOnSave(nil)
+		time.Sleep(time.Second * 6)
+		synctest.Wait()
+		assert.Len(t, storage.Sessions(), 1)
+		assert.Len(t, storage.PageViews(), 1)
+		assert.Zero(t, pipe.Spool().Len())
+	})
+}
+
+type storageWithPageViewError struct {
+	*db.Mock
+	err error
+}
+
+func (client *storageWithPageViewError) SavePageViews(ctx context.Context, pageViews []model.PageView) error {
+	if client.err != nil {
+		return client.err
+	}
+
+	return client.Mock.SavePageViews(ctx, pageViews)
+}
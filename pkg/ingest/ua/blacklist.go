@@ -81,6 +81,7 @@ var userAgentBlacklist = []string{
 	"admuncher",
 	"adobeuxtech",
 	"ads.txt",
+	"adsbot-google",
 	"adstxt",
 	"adwords",
 	"ae/0.1",
@@ -93,6 +94,8 @@ var userAgentBlacklist = []string{
 	"aggregation",
 	"aggregator",
 	"ahc",
+	"ahrefsbot",
+	"ahrefssiteaudit",
 	"akregator",
 	"alertra",
 	"alexa",
@@ -108,6 +111,7 @@ var userAgentBlacklist = []string{
 	"amazon",
 	"amazon music podcast",
 	"amazon.com",
+	"amazonbot",
 	"amiga",
 	"amiga-aweb",
 	"amigavoyager",
@@ -122,14 +126,17 @@ var userAgentBlacklist = []string{
 	"anonymous_agent",
 	"anthill",
 	"anthropic",
+	"anthropic-ai",
 	"anyconnect",
 	"anyevent-http",
 	"aol explorer",
 	"apache",
+	"apis-google",
 	"appadviceapp",
 	"appie",
 	"appinsights",
 	"apple-pubsub",
+	"applebot",
 	"applicationhealthservice",
 	"appvername",
 	"aps_ladle_patholog_freshet",
@@ -137,6 +144,7 @@ var userAgentBlacklist = []string{
 	"arachni",
 	"architext",
 	"archive",
+	"archive.org_bot",
 	"archiveteam",
 	"aria2",
 	"arks",
@@ -167,6 +175,7 @@ var userAgentBlacklist = []string{
 	"axios",
 	"azureus",
 	"baidu",
+	"baiduspider",
 	"barcapro",
 	"barooders",
 	"barracuda sentinel",
@@ -179,6 +188,7 @@ var userAgentBlacklist = []string{
 	"biglotron",
 	"bigpointclient",
 	"bin/bash",
+	"bingbot",
 	"bingpreview",
 	"binlar",
 	"bit.ly",
@@ -222,6 +232,7 @@ var userAgentBlacklist = []string{
 	"burpcollaborator",
 	"bwh3_user_agent",
 	"bxss.me",
+	"bytespider",
 	"c1647ea35c6eb53d6a562328ec47e",
 	"cafecito",
 	"cakephp",
@@ -236,11 +247,13 @@ var userAgentBlacklist = []string{
 	"catch",
 	"catchpoint",
 	"catexplorador",
+	"ccbot",
 	"ccleaner",
 	"celestial",
 	"cfnetwork",
 	"chamaeleon.de",
 	"chatgpt",
+	"chatgpt-user",
 	"chatterino",
 	"check",
 	"checklink",
@@ -259,6 +272,9 @@ var userAgentBlacklist = []string{
 	"clash-verge",
 	"clashforwindows",
 	"classify-workers",
+	"claude-searchbot",
+	"claude-user",
+	"claudebot",
 	"clementine",
 	"clickfunnels-ua",
 	"client",
@@ -362,6 +378,7 @@ var userAgentBlacklist = []string{
 	"domaner.xyz",
 	"donutp",
 	"dormouse",
+	"dotbot",
 	"download",
 	"doximity-pipeline",
 	"dreampassport",
@@ -425,6 +442,7 @@ var userAgentBlacklist = []string{
 	"feedbin",
 	"feedburner",
 	"feedfetcher",
+	"feedfetcher-google",
 	"feedreader",
 	"ferret",
 	"fetch",
@@ -496,10 +514,18 @@ var userAgentBlacklist = []string{
 	"goodjudge",
 	"googal",
 	"google-conversion-service",
+	"google-inspectiontool",
+	"google-read-aloud",
+	"google-safety",
+	"google-site-verification",
 	"googleanalytics",
+	"googlebot",
+	"googleother",
+	"googleproducer",
 	"goose",
 	"gosquared",
 	"gozilla",
+	"gptbot",
 	"grabber",
 	"grabyapi",
 	"grammarly",
@@ -735,6 +761,8 @@ var userAgentBlacklist = []string{
 	"meltwaternews",
 	"mention",
 	"meshrom",
+	"meta-externalagent",
+	"meta-externalfetcher",
 	"metainspector",
 	"metamatrix",
 	"metauri",
@@ -764,6 +792,7 @@ var userAgentBlacklist = []string{
 	"mixmax-linkpreview",
 	"mixnodecache",
 	"mizilla",
+	"mj12bot",
 	"mjukisbyxor",
 	"mmb1_pedrillo",
 	"mnogosearch",
@@ -849,6 +878,7 @@ var userAgentBlacklist = []string{
 	"nuzzel",
 	"nvd0rz",
 	"oadoi",
+	"oai-searchbot",
 	"oast.online",
 	"object object",
 	"object promise",
@@ -911,6 +941,8 @@ var userAgentBlacklist = []string{
 	"perimeterx",
 	"perl",
 	"perman",
+	"perplexity-user",
+	"perplexitybot",
 	"pg_",
 	"phantom",
 	"photon",
@@ -1063,6 +1095,7 @@ var userAgentBlacklist = []string{
 	"secweb",
 	"select",
 	"selenium",
+	"semrushbot",
 	"sentry",
 	"seo",
 	"seostats",
@@ -1351,6 +1384,8 @@ var userAgentBlacklist = []string{
 	"yacy",
 	"yahoo",
 	"yandex",
+	"yandexbot",
+	"yandeximages",
 	"yealink",
 	"yeti",
 	"yoarcwhatsaps",
@@ -2,6 +2,8 @@ package ua
 
 import (
 	"net"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -38,79 +40,118 @@ func (f *BotFilter) Step(request *ingest.Request) (bool, error) {
 		return false, nil
 	}
 
-	// empty User-Agents are usually bots
-	rawUserAgent := request.Request.UserAgent()
-	userAgent := strings.TrimSpace(strings.ToLower(rawUserAgent))
-
-	if userAgent == "" ||
-		len(userAgent) <= minUserAgentLength ||
-		len(userAgent) > maxUserAgentLength ||
-		util.ContainsNonASCIICharacters(userAgent) {
-		request.BotReason = "ua-chars"
+	if reasons := f.botReasons(request, false); len(reasons) > 0 {
+		request.BotReason = reasons[0]
 		return true, nil
 	}
 
-	// ignore User-Agents that are an IP address
-	host := rawUserAgent
+	return false, nil
+}
 
-	if net.ParseIP(host) != nil {
-		request.BotReason = "ua-ip"
-		return true, nil
-	}
+// BotReasons implements the ingest.BotDetector interface.
+func (f *BotFilter) BotReasons(request *ingest.Request) []string {
+	return f.botReasons(request, true)
+}
 
-	if strings.Contains(host, ":") {
-		host, _, _ = net.SplitHostPort(rawUserAgent)
-	}
+func (f *BotFilter) botReasons(request *ingest.Request, all bool) []string {
+	reasons := make([]string, 0)
+	userAgent := strings.TrimSpace(strings.ToLower(request.Request.UserAgent()))
 
-	if net.ParseIP(host) != nil {
-		request.BotReason = "ua-ip"
-		return true, nil
+	for _, check := range botChecks {
+		if check.match(f, request, userAgent) {
+			reasons = append(reasons, check.reason)
+
+			if !all {
+				break
+			}
+		}
 	}
 
+	return reasons
+}
+
+type botCheck struct {
+	reason string
+	match  func(f *BotFilter, request *ingest.Request, userAgent string) bool
+}
+
+// botChecks are the heuristics in the order they are checked.
+// The User-Agent passed to them is trimmed and lowercase.
+var botChecks = []botCheck{
+	// empty User-Agents are usually bots
+	{"ua-chars", func(_ *BotFilter, _ *ingest.Request, userAgent string) bool {
+		return userAgent == "" ||
+			len(userAgent) <= minUserAgentLength ||
+			len(userAgent) > maxUserAgentLength ||
+			util.ContainsNonASCIICharacters(userAgent)
+	}},
+
+	// ignore User-Agents that are an IP address
+	{"ua-ip", func(f *BotFilter, request *ingest.Request, _ string) bool {
+		return f.isIP(request.Request.UserAgent())
+	}},
+
 	// filter UUIDs
-	if _, err := uuid.Parse(rawUserAgent); err == nil {
-		request.BotReason = "ua-uuid"
-		return true, nil
-	}
+	{"ua-uuid", func(_ *BotFilter, request *ingest.Request, _ string) bool {
+		_, err := uuid.Parse(request.Request.UserAgent())
+		return err == nil
+	}},
 
 	// filter User-Agent
-	if f.ignoreBrowserVersion(request.Browser, request.BrowserVersion) {
-		request.BotReason = "browser"
-		return true, nil
-	}
-
-	if request.Browser == pkg.BrowserFirefox && request.BrowserRevision != request.BrowserVersion {
-		request.BotReason = "ua-rv-mismatch"
-		return true, nil
-	}
+	{"browser", func(f *BotFilter, request *ingest.Request, _ string) bool {
+		return f.ignoreBrowserVersion(request.Browser, request.BrowserVersion)
+	}},
+	{"ua-rv-mismatch", func(_ *BotFilter, request *ingest.Request, _ string) bool {
+		return request.Browser == pkg.BrowserFirefox && request.BrowserRevision != request.BrowserVersion
+	}},
 
 	// filter for bot keywords
-	browser := strings.ToLower(request.Browser)
+	{"ch-browser", func(_ *BotFilter, request *ingest.Request, _ string) bool {
+		browser := strings.ToLower(request.Browser)
+		return slices.ContainsFunc(browserBlacklist, func(botBrowser string) bool {
+			return strings.Contains(browser, botBrowser)
+		})
+	}},
+	{"ua-keyword", func(_ *BotFilter, _ *ingest.Request, userAgent string) bool {
+		return blacklistedKeyword(userAgent)
+	}},
 
-	for _, botBrowser := range browserBlacklist {
-		if strings.Contains(browser, botBrowser) {
-			request.BotReason = "ch-browser"
-			return true, nil
-		}
-	}
+	// filter for bot regex
+	{"ua-regex", func(_ *BotFilter, _ *ingest.Request, userAgent string) bool {
+		return blacklistedRegex(userAgent)
+	}},
+}
 
-	// filter for bot keywords
-	for _, botUserAgent := range userAgentBlacklist {
-		if strings.Contains(userAgent, botUserAgent) {
-			request.BotReason = "ua-keyword"
-			return true, nil
-		}
+// Blacklisted returns whether given User-Agent contains a keyword or matches a regex of the User-Agent blacklist.
+func Blacklisted(userAgent string) bool {
+	userAgent = strings.TrimSpace(strings.ToLower(userAgent))
+	return blacklistedKeyword(userAgent) || blacklistedRegex(userAgent)
+}
+
+func blacklistedKeyword(userAgent string) bool {
+	return slices.ContainsFunc(userAgentBlacklist, func(botUserAgent string) bool {
+		return strings.Contains(userAgent, botUserAgent)
+	})
+}
+
+func blacklistedRegex(userAgent string) bool {
+	return slices.ContainsFunc(userAgentRegexBlacklist, func(botUserAgent *regexp.Regexp) bool {
+		return botUserAgent.MatchString(userAgent)
+	})
+}
+
+func (f *BotFilter) isIP(userAgent string) bool {
+	if net.ParseIP(userAgent) != nil {
+		return true
 	}
 
-	// filter for bot regex
-	for _, botUserAgent := range userAgentRegexBlacklist {
-		if botUserAgent.MatchString(userAgent) {
-			request.BotReason = "ua-regex"
-			return true, nil
-		}
+	host := userAgent
+
+	if strings.Contains(host, ":") {
+		host, _, _ = net.SplitHostPort(userAgent)
 	}
 
-	return false, nil
+	return net.ParseIP(host) != nil
 }
 
 func (f *BotFilter) ignoreBrowserVersion(browser, version string) bool {
@@ -206,3 +206,28 @@ func TestBotFilterBrowserVersion(t *testing.T) {
 	assert.False(t, cancel)
 	assert.Empty(t, r.BotReason)
 }
+
+func TestBotFilterBotReasons(t *testing.T) {
+	userAgents := []struct {
+		userAgent string
+		reasons   []string
+	}{
+		{"172.22.0.11", []string{"ua-chars", "ua-ip"}},
+		{"Mozilla/5.0 (Windows NT 6.1; WOW64; rv:31.0) Gecko/20130401 Firefox/31.0 (compatible; crawler)", []string{"browser", "ua-keyword"}},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", []string{}},
+	}
+	u := NewUserAgent()
+	f := NewBotFilter()
+
+	for _, userAgent := range userAgents {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("User-Agent", userAgent.userAgent)
+		r := &ingest.Request{
+			Request: req,
+		}
+		_, err := u.Step(r)
+		assert.NoError(t, err)
+		assert.Equalf(t, userAgent.reasons, f.BotReasons(r), userAgent.userAgent)
+		assert.Empty(t, r.BotReason)
+	}
+}
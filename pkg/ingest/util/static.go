@@ -0,0 +1,33 @@
+package util
+
+var (
+	// StaticExtensions is the default list of file extensions (including the dot) for static files, which are not tracked as page views.
+	StaticExtensions = []string{
+		".avif",
+		".css",
+		".eot",
+		".gif",
+		".ico",
+		".jpeg",
+		".jpg",
+		".js",
+		".json",
+		".map",
+		".mjs",
+		".mp3",
+		".mp4",
+		".otf",
+		".pdf",
+		".png",
+		".svg",
+		".ttf",
+		".txt",
+		".wasm",
+		".webm",
+		".webp",
+		".woff",
+		".woff2",
+		".xml",
+		".zip",
+	}
+)
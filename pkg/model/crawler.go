@@ -0,0 +1,23 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Crawler is a request made by a search engine or AI crawler.
+type Crawler struct {
+	SiteID   uint64    `db:"site_id" json:"site_id"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Path     string    `json:"path"`
+	Crawler  string    `json:"crawler"`
+	Category string    `json:"category"`
+	Status   string    `json:"status"`
+}
+
+// String implements the Stringer interface.
+func (crawler Crawler) String() string {
+	out, _ := json.Marshal(crawler)
+	return string(out)
+}
@@ -10,12 +10,19 @@ type Data struct {
 	SiteID         uint64    `db:"site_id" json:"site_id" csv:"site_id"`
 	VisitorID      uint64    `db:"visitor_id" json:"visitor_id" csv:"visitor_id"`
 	SessionID      uint32    `db:"session_id" json:"session_id" csv:"session_id"`
+	IsReturning    bool      `db:"is_returning" json:"is_returning" csv:"is_returning"`
+	UserID         uint64    `db:"user_id" json:"user_id" csv:"user_id"`
 	Time           time.Time `json:"time" csv:"time"`
 	Hostname       string    `json:"hostname" csv:"hostname"`
 	Language       string    `json:"language" csv:"language"`
 	CountryCode    string    `db:"country_code" json:"country_code" csv:"country_code"`
 	Region         string    `json:"region" csv:"region"`
 	City           string    `json:"city" csv:"city"`
+	ContinentCode  string    `db:"continent_code" json:"continent_code" csv:"continent_code"`
+	TimeZone       string    `db:"time_zone" json:"time_zone" csv:"time_zone"`
+	Latitude       float32   `json:"latitude" csv:"latitude"`
+	Longitude      float32   `json:"longitude" csv:"longitude"`
+	InEU           bool      `db:"in_eu" json:"in_eu" csv:"in_eu"`
 	Referrer       string    `json:"referrer" csv:"referrer"`
 	ReferrerName   string    `db:"referrer_name" json:"referrer_name" csv:"referrer_name"`
 	ReferrerIcon   string    `db:"referrer_icon" json:"referrer_icon" csv:"referrer_icon"`
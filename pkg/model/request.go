@@ -7,24 +7,31 @@ import (
 
 // Request is the data structure used to log visitor requests.
 type Request struct {
-	SiteID      uint64            `db:"site_id" json:"site_id"`
-	VisitorID   uint64            `db:"visitor_id" json:"visitor_id"`
-	Time        time.Time         `json:"time"`
-	Hostname    string            `json:"hostname"`
-	Path        string            `json:"path"`
-	Query       string            `json:"query"`
-	IP          string            `json:"ip"`
-	UserAgent   string            `db:"user_agent" json:"user_agent"`
-	Headers     map[string]string `json:"headers"`
-	EventName   string            `db:"event_name" json:"event_name"`
-	Referrer    string            `json:"referrer"`
-	UTMSource   string            `db:"utm_source" json:"utm_source"`
-	UTMMedium   string            `db:"utm_medium" json:"utm_medium"`
-	UTMCampaign string            `db:"utm_campaign" json:"utm_campaign"`
-	UTMContent  string            `db:"utm_content" json:"utm_content"`
-	UTMTerm     string            `db:"utm_term" json:"utm_term"`
-	Bot         bool              `json:"bot"`
-	BotReason   string            `db:"bot_reason" json:"bot_reason"`
+	SiteID          uint64            `db:"site_id" json:"site_id"`
+	VisitorID       uint64            `db:"visitor_id" json:"visitor_id"`
+	Time            time.Time         `json:"time"`
+	Hostname        string            `json:"hostname"`
+	Path            string            `json:"path"`
+	Query           string            `json:"query"`
+	IP              string            `json:"ip"`
+	ASN             uint32            `json:"asn"`
+	ASNOrganization string            `db:"asn_organization" json:"asn_organization"`
+	UserAgent       string            `db:"user_agent" json:"user_agent"`
+	Headers         map[string]string `json:"headers"`
+	EventName       string            `db:"event_name" json:"event_name"`
+	Referrer        string            `json:"referrer"`
+	UTMSource       string            `db:"utm_source" json:"utm_source"`
+	UTMMedium       string            `db:"utm_medium" json:"utm_medium"`
+	UTMCampaign     string            `db:"utm_campaign" json:"utm_campaign"`
+	UTMContent      string            `db:"utm_content" json:"utm_content"`
+	UTMTerm         string            `db:"utm_term" json:"utm_term"`
+	Bot             bool              `json:"bot"`
+	BotReason       string            `db:"bot_reason" json:"bot_reason"`
+	Crawler         string            `json:"crawler"`
+	CrawlerVerified bool              `db:"crawler_verified" json:"crawler_verified"`
+	BotScore        float64           `db:"bot_score" json:"bot_score"`
+	BotReasons      []string          `db:"bot_reasons" json:"bot_reasons"`
+	ShadowBotReason string            `db:"shadow_bot_reason" json:"shadow_bot_reason"`
 }
 
 // String implements the Stringer interface.
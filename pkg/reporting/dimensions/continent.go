@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// Continent is a Dimension.
+type Continent struct{}
+
+// Table implements the Dimension interface.
+func (d Continent) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d Continent) Column(_ string) string {
+	return "continent_code"
+}
+
+// Expression implements the Dimension interface.
+func (d Continent) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d Continent) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d Continent) ScanType() any {
+	return new(string)
+}
@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// Crawler is a Dimension.
+type Crawler struct{}
+
+// Table implements the Dimension interface.
+func (d Crawler) Table() []string {
+	return []string{pkg.TableCrawlers}
+}
+
+// Column implements the Dimension interface.
+func (d Crawler) Column(_ string) string {
+	return "crawler"
+}
+
+// Expression implements the Dimension interface.
+func (d Crawler) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d Crawler) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d Crawler) ScanType() any {
+	return new(string)
+}
@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// CrawlerCategory is a Dimension.
+type CrawlerCategory struct{}
+
+// Table implements the Dimension interface.
+func (d CrawlerCategory) Table() []string {
+	return []string{pkg.TableCrawlers}
+}
+
+// Column implements the Dimension interface.
+func (d CrawlerCategory) Column(_ string) string {
+	return "category"
+}
+
+// Expression implements the Dimension interface.
+func (d CrawlerCategory) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d CrawlerCategory) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d CrawlerCategory) ScanType() any {
+	return new(string)
+}
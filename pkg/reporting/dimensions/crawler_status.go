@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// CrawlerStatus is a Dimension.
+type CrawlerStatus struct{}
+
+// Table implements the Dimension interface.
+func (d CrawlerStatus) Table() []string {
+	return []string{pkg.TableCrawlers}
+}
+
+// Column implements the Dimension interface.
+func (d CrawlerStatus) Column(_ string) string {
+	return "status"
+}
+
+// Expression implements the Dimension interface.
+func (d CrawlerStatus) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d CrawlerStatus) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d CrawlerStatus) ScanType() any {
+	return new(string)
+}
@@ -9,7 +9,7 @@ type Hostname struct{}
 
 // Table implements the Dimension interface.
 func (d Hostname) Table() []string {
-	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents, pkg.TableCrawlers}
 }
 
 // Column implements the Dimension interface.
@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// InEU is a Dimension.
+type InEU struct{}
+
+// Table implements the Dimension interface.
+func (d InEU) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d InEU) Column(_ string) string {
+	return "in_eu"
+}
+
+// Expression implements the Dimension interface.
+func (d InEU) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d InEU) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d InEU) ScanType() any {
+	return new(bool)
+}
@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// Latitude is a Dimension.
+type Latitude struct{}
+
+// Table implements the Dimension interface.
+func (d Latitude) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d Latitude) Column(_ string) string {
+	return "latitude"
+}
+
+// Expression implements the Dimension interface.
+func (d Latitude) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d Latitude) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d Latitude) ScanType() any {
+	return new(float32)
+}
@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// Longitude is a Dimension.
+type Longitude struct{}
+
+// Table implements the Dimension interface.
+func (d Longitude) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d Longitude) Column(_ string) string {
+	return "longitude"
+}
+
+// Expression implements the Dimension interface.
+func (d Longitude) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d Longitude) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d Longitude) ScanType() any {
+	return new(float32)
+}
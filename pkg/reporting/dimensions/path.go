@@ -7,7 +7,7 @@ type Path struct{}
 
 // Table implements the Dimension interface.
 func (d Path) Table() []string {
-	return []string{pkg.TablePageViews, pkg.TableEvents}
+	return []string{pkg.TablePageViews, pkg.TableEvents, pkg.TableCrawlers}
 }
 
 // Column implements the Dimension interface.
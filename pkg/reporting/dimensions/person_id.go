@@ -0,0 +1,35 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// PersonID is a Dimension.
+// It is the user ID if set and the visitor ID otherwise, to identify people across devices and sessions.
+// The user ID is resolved per session, so that page views and events before the login belong to the same person.
+type PersonID struct{}
+
+// Table implements the Dimension interface.
+func (d PersonID) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d PersonID) Column(_ string) string {
+	return "person_id"
+}
+
+// Expression implements the Dimension interface.
+func (d PersonID) Expression() string {
+	return "if(session_user_id = 0, visitor_id, session_user_id)"
+}
+
+// Args implements the Dimension interface.
+func (d PersonID) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d PersonID) ScanType() any {
+	return new(uint64)
+}
@@ -0,0 +1,35 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// PersonSessionID is a Dimension.
+// It is the session ID for visitors without a user ID and zero otherwise, so that sessions of users are connected.
+// Like PersonID, it uses the user ID resolved for the whole session.
+type PersonSessionID struct{}
+
+// Table implements the Dimension interface.
+func (d PersonSessionID) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d PersonSessionID) Column(_ string) string {
+	return "person_session_id"
+}
+
+// Expression implements the Dimension interface.
+func (d PersonSessionID) Expression() string {
+	return "if(session_user_id = 0, session_id, 0)"
+}
+
+// Args implements the Dimension interface.
+func (d PersonSessionID) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d PersonSessionID) ScanType() any {
+	return new(uint32)
+}
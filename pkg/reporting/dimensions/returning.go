@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// Returning is a Dimension.
+type Returning struct{}
+
+// Table implements the Dimension interface.
+func (d Returning) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d Returning) Column(_ string) string {
+	return "is_returning"
+}
+
+// Expression implements the Dimension interface.
+func (d Returning) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d Returning) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d Returning) ScanType() any {
+	return new(bool)
+}
@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// TimeZone is a Dimension.
+type TimeZone struct{}
+
+// Table implements the Dimension interface.
+func (d TimeZone) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d TimeZone) Column(_ string) string {
+	return "time_zone"
+}
+
+// Expression implements the Dimension interface.
+func (d TimeZone) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d TimeZone) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d TimeZone) ScanType() any {
+	return new(string)
+}
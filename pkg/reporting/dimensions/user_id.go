@@ -0,0 +1,33 @@
+package dimensions
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg"
+)
+
+// UserID is a Dimension.
+type UserID struct{}
+
+// Table implements the Dimension interface.
+func (d UserID) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// Column implements the Dimension interface.
+func (d UserID) Column(_ string) string {
+	return "user_id"
+}
+
+// Expression implements the Dimension interface.
+func (d UserID) Expression() string {
+	return ""
+}
+
+// Args implements the Dimension interface.
+func (d UserID) Args() []any {
+	return nil
+}
+
+// ScanType implements the Metric interface.
+func (d UserID) ScanType() any {
+	return new(uint64)
+}
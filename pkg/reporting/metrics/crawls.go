@@ -0,0 +1,36 @@
+package metrics
+
+import "github.com/pirsch-analytics/pirsch/v7/pkg"
+
+// Crawls is a Metric.
+type Crawls struct{}
+
+// Table implements the Metric interface.
+func (m Crawls) Table() []string {
+	return []string{pkg.TableCrawlers}
+}
+
+// JoinTable implements the Metric interface.
+func (m Crawls) JoinTable() string {
+	return ""
+}
+
+// Column implements the Metric interface.
+func (m Crawls) Column() string {
+	return "crawls"
+}
+
+// Expression implements the Metric interface.
+func (m Crawls) Expression(_ string) (string, bool) {
+	return "count(*)", false
+}
+
+// ScanType implements the Metric interface.
+func (m Crawls) ScanType() any {
+	return new(uint64)
+}
+
+// Zero implements the Metric interface.
+func (m Crawls) Zero() any {
+	return uint64(0)
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import "github.com/pirsch-analytics/pirsch/v7/pkg"
+
+// UniquePaths is a Metric.
+type UniquePaths struct{}
+
+// Table implements the Metric interface.
+func (m UniquePaths) Table() []string {
+	return []string{pkg.TableCrawlers}
+}
+
+// JoinTable implements the Metric interface.
+func (m UniquePaths) JoinTable() string {
+	return ""
+}
+
+// Column implements the Metric interface.
+func (m UniquePaths) Column() string {
+	return "unique_paths"
+}
+
+// Expression implements the Metric interface.
+func (m UniquePaths) Expression(_ string) (string, bool) {
+	return "uniq(path)", false
+}
+
+// ScanType implements the Metric interface.
+func (m UniquePaths) ScanType() any {
+	return new(uint64)
+}
+
+// Zero implements the Metric interface.
+func (m UniquePaths) Zero() any {
+	return uint64(0)
+}
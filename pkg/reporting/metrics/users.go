@@ -0,0 +1,36 @@
+package metrics
+
+import "github.com/pirsch-analytics/pirsch/v7/pkg"
+
+// Users is a Metric.
+type Users struct{}
+
+// Table implements the Metric interface.
+func (m Users) Table() []string {
+	return []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+}
+
+// JoinTable implements the Metric interface.
+func (m Users) JoinTable() string {
+	return ""
+}
+
+// Column implements the Metric interface.
+func (m Users) Column() string {
+	return "users"
+}
+
+// Expression implements the Metric interface.
+func (m Users) Expression(_ string) (string, bool) {
+	return "uniqIf(user_id, user_id != 0)", false
+}
+
+// ScanType implements the Metric interface.
+func (m Users) ScanType() any {
+	return new(uint64)
+}
+
+// Zero implements the Metric interface.
+func (m Users) Zero() any {
+	return uint64(0)
+}
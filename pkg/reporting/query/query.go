@@ -35,6 +35,7 @@ type Query struct {
 	primaryTable   string
 	joinTable      string
 	joinStep       int
+	stitchUsers    bool
 	primaryFilter  []classifiedFilter
 	subqueryFilter []classifiedFilter
 }
@@ -107,10 +108,16 @@ func (q *Query) Funnel(req request.FunnelRequest) report.FunnelReport {
 	}
 
 	// union all steps to get visitor counts
+	visitor := dimensions.VisitorID{}.Column("")
+
+	if req.StitchUsers {
+		visitor = dimensions.PersonID{}.Column("")
+	}
+
 	query.WriteString("SELECT * FROM (")
 
 	for i := range req.Filter {
-		query.WriteString(fmt.Sprintf("SELECT %d step, uniq(visitor_id) visitors FROM step%d ", i+1, i+1))
+		query.WriteString(fmt.Sprintf("SELECT %d step, uniq(%s) visitors FROM step%d ", i+1, visitor, i+1))
 
 		if i != len(req.Filter)-1 {
 			query.WriteString("UNION ALL ")
@@ -188,6 +195,13 @@ func (q *Query) buildFunnelStepQuery(req request.FunnelRequest, stepIndex int, s
 		dimensions.SessionID{},
 	}
 
+	if req.StitchUsers {
+		stepDimensions = []dimensions.Dimension{
+			dimensions.PersonID{},
+			dimensions.PersonSessionID{},
+		}
+	}
+
 	if stepIndex > 0 {
 		stepDimensions = append(stepDimensions, dimensions.Step{Number: stepIndex + 1})
 	}
@@ -210,6 +224,81 @@ func (q *Query) funnelStepsEqual(a, b []request.Filter) bool {
 	return len(a) == len(b) && fmt.Sprint(a) == fmt.Sprint(b)
 }
 
+// Shadow runs given request.ShadowRequest and returns the report.ShadowReport.
+// It compares the decisions of bot filters running in shadow mode against the actual ones based on the request log.
+func (q *Query) Shadow(req request.ShadowRequest) report.ShadowReport {
+	if errs := req.Validate(); errs != nil {
+		return report.ShadowReport{
+			Meta: report.Meta{
+				Errors: errs,
+			},
+		}
+	}
+
+	where, args := q.buildQueryWhereSiteAndPeriod(req.SiteID, req.Period)
+	r := report.ShadowReport{
+		Request: req,
+		Reasons: make([]report.ShadowReason, 0),
+	}
+
+	if err := q.db.QueryRow(req.Ctx, `SELECT count() requests, countIf(bot OR bot_reason != '') bots FROM "request_v7" `+where, args...).Scan(&r.Requests, &r.Bots); err != nil {
+		return report.ShadowReport{
+			Meta: report.Meta{
+				Errors: []error{err},
+			},
+		}
+	}
+
+	query := `SELECT shadow_bot_reason, count() requests, uniq(visitor_id) visitors, countIf(bot OR bot_reason != '') bots
+		FROM "request_v7" ` + where + `AND shadow_bot_reason != ''
+		GROUP BY shadow_bot_reason
+		ORDER BY requests DESC, shadow_bot_reason`
+	rows, err := q.db.Query(req.Ctx, query, args...)
+
+	if err != nil {
+		return report.ShadowReport{
+			Meta: report.Meta{
+				Errors: []error{err},
+			},
+		}
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+	passed := r.Requests - r.Bots
+
+	for rows.Next() {
+		var reason report.ShadowReason
+
+		if err := rows.Scan(&reason.Reason, &reason.Requests, &reason.Visitors, &reason.Bots); err != nil {
+			return report.ShadowReport{
+				Meta: report.Meta{
+					Errors: []error{err},
+				},
+			}
+		}
+
+		reason.Passed = reason.Requests - reason.Bots
+
+		if passed > 0 {
+			reason.RelativePassed = float64(reason.Passed) / float64(passed)
+		}
+
+		r.Reasons = append(r.Reasons, reason)
+	}
+
+	if err := rows.Err(); err != nil {
+		return report.ShadowReport{
+			Meta: report.Meta{
+				Errors: []error{err},
+			},
+		}
+	}
+
+	return r
+}
+
 func (q *Query) prepare(req *request.Request) []error {
 	if errs := req.Validate(); errs != nil {
 		return errs
@@ -217,6 +306,7 @@ func (q *Query) prepare(req *request.Request) []error {
 
 	q.resolvePrimaryTable(*req)
 	q.resolveJoinTable(*req)
+	q.stitchUsers = q.requiresPersons(req.Dimensions)
 
 	for _, filter := range req.Filter {
 		if err := q.classifyFilter(filter); err != nil {
@@ -405,6 +495,14 @@ func (q *Query) zeroValues(metrics []metrics.Metric) []any {
 }
 
 func (q *Query) resolvePrimaryTable(req request.Request) {
+	// crawler metrics can only be calculated from the crawler table
+	for _, m := range req.Metrics {
+		if slices.Contains(m.Table(), pkg.TableCrawlers) {
+			q.primaryTable = pkg.TableCrawlers
+			return
+		}
+	}
+
 	// dimensions drive the primary table
 	if len(req.Dimensions) > 0 {
 		q.primaryTable = q.resolveBestTable(q.dimensionTables(req.Dimensions))
@@ -422,7 +520,7 @@ func (q *Query) resolvePrimaryTable(req request.Request) {
 }
 
 func (q *Query) resolveBestTable(tableSets [][]string) string {
-	candidates := []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents}
+	candidates := []string{pkg.TableSessions, pkg.TablePageViews, pkg.TableEvents, pkg.TableCrawlers}
 	valid := make([]string, 0, len(candidates))
 
 	for _, candidate := range candidates {
@@ -758,7 +856,14 @@ func (q *Query) buildQuery(req request.Request) (string, []any) {
 	selectQuery, selectArgs := q.buildQuerySelect(req)
 	query.WriteString(selectQuery)
 	args = append(args, selectArgs...)
-	query.WriteString(q.buildQuereFrom(q.primaryTable, req.Options.Sample))
+
+	if q.stitchUsers {
+		fromQuery, fromArgs := q.buildQueryFromPersons(req)
+		query.WriteString(fromQuery)
+		args = append(args, fromArgs...)
+	} else {
+		query.WriteString(q.buildQuereFrom(q.primaryTable, req.Options.Sample))
+	}
 
 	if withRequired {
 		query.WriteString(q.buildQueryWithJoin(req))
@@ -918,9 +1023,31 @@ func (q *Query) buildQueryWithJoin(req request.Request) string {
 }
 
 func (q *Query) buildQueryWithJoinStep() string {
+	if q.stitchUsers {
+		// users are connected across sessions, visitors without a user ID within the session
+		person, personSession := dimensions.PersonID{}, dimensions.PersonSessionID{}
+		return fmt.Sprintf("JOIN step%d s ON %s = s.%s AND %s = s.%s ",
+			q.joinStep,
+			person.Expression(),
+			person.Column(""),
+			personSession.Expression(),
+			personSession.Column(""))
+	}
+
 	return fmt.Sprintf("JOIN step%d s ON visitor_id = s.visitor_id AND session_id = s.session_id ", q.joinStep)
 }
 
+func (q *Query) requiresPersons(list []dimensions.Dimension) bool {
+	for _, d := range list {
+		switch d.(type) {
+		case dimensions.PersonID, dimensions.PersonSessionID:
+			return true
+		}
+	}
+
+	return false
+}
+
 func (q *Query) buildQueryWithFilterDimensions(req request.Request) []dimensions.Dimension {
 	groupBy := make([]dimensions.Dimension, 0, len(req.Dimensions))
 
@@ -977,13 +1104,22 @@ func (q *Query) buildQuerySelectColumn(dimension dimensions.Dimension) string {
 }
 
 func (q *Query) buildQuereFrom(table string, sample uint) string {
-	if sample > 0 {
+	// the crawler table is not sampled by visitor
+	if sample > 0 && table != pkg.TableCrawlers {
 		return fmt.Sprintf("FROM %s SAMPLE %d ", table, sample)
 	}
 
 	return fmt.Sprintf("FROM %s ", table)
 }
 
+func (q *Query) buildQueryFromPersons(req request.Request) (string, []any) {
+	// resolve the user ID for the whole session, so that rows before the login belong to the same person
+	where, args := q.buildQueryWhereSiteAndPeriod(req.SiteID, req.Period)
+	return fmt.Sprintf("FROM (SELECT *, max(user_id) OVER (PARTITION BY visitor_id, session_id) session_user_id %s%s) t ",
+		q.buildQuereFrom(q.primaryTable, req.Options.Sample),
+		where), args
+}
+
 func (q *Query) buildQueryWhere(req request.Request) (string, []any) {
 	var query strings.Builder
 	args := make([]any, 0)
@@ -1899,6 +1899,519 @@ func TestQueryFunnel(t *testing.T) {
 	assert.Equal(t, float64(1), r.Steps[3].DropOff)
 }
 
+func TestQueryShadow(t *testing.T) {
+	db.CleanupDB(t, client)
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, client.SaveRequests(context.Background(), []model.Request{
+		{SiteID: 1, VisitorID: 1, Time: now},
+		{SiteID: 1, VisitorID: 2, Time: now, ShadowBotReason: "http11-sf"},
+		{SiteID: 1, VisitorID: 2, Time: now, ShadowBotReason: "http11-sf"},
+		{SiteID: 1, VisitorID: 3, Time: now, BotReason: "ua-keyword", ShadowBotReason: "http11-sf"},
+		{SiteID: 1, VisitorID: 4, Time: now, Bot: true, BotReason: "ua-keyword", ShadowBotReason: "ua-rv-mismatch"},
+		{SiteID: 2, VisitorID: 5, Time: now, ShadowBotReason: "http11-sf"},
+	}))
+	q, from, to := newQuery()
+	r := q.Shadow(request.ShadowRequest{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+	})
+	assert.Empty(t, r.Meta.Errors)
+	assert.Equal(t, uint64(5), r.Requests)
+	assert.Equal(t, uint64(2), r.Bots)
+	assert.Len(t, r.Reasons, 2)
+	assert.Equal(t, "http11-sf", r.Reasons[0].Reason)
+	assert.Equal(t, uint64(3), r.Reasons[0].Requests)
+	assert.Equal(t, uint64(2), r.Reasons[0].Visitors)
+	assert.Equal(t, uint64(1), r.Reasons[0].Bots)
+	assert.Equal(t, uint64(2), r.Reasons[0].Passed)
+	assert.InDelta(t, 0.6666, r.Reasons[0].RelativePassed, 0.001)
+	assert.Equal(t, "ua-rv-mismatch", r.Reasons[1].Reason)
+	assert.Equal(t, uint64(1), r.Reasons[1].Bots)
+	assert.Zero(t, r.Reasons[1].Passed)
+	r = q.Shadow(request.ShadowRequest{})
+	assert.Len(t, r.Meta.Errors, 1)
+}
+
+func TestQueryCrawlers(t *testing.T) {
+	db.CleanupDB(t, client)
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, client.SaveCrawlers(context.Background(), []model.Crawler{
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/", Crawler: "GPTBot", Category: "ai", Status: "verified"},
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/", Crawler: "GPTBot", Category: "ai", Status: "verified"},
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/blog", Crawler: "GPTBot", Category: "ai", Status: "verified"},
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/", Crawler: "ClaudeBot", Category: "ai", Status: "unverified"},
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/blog", Crawler: "Googlebot", Category: "search", Status: "verified"},
+		{SiteID: 2, Time: now, Hostname: "example.com", Path: "/", Crawler: "GPTBot", Category: "ai", Status: "verified"},
+	}))
+	q, from, to := newQuery()
+	req := request.Request{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+		Dimensions: []dimensions.Dimension{
+			dimensions.Crawler{},
+		},
+		Metrics: []metrics.Metric{
+			metrics.Crawls{},
+			metrics.UniquePaths{},
+		},
+		OrderBy: []request.OrderBy{
+			{Metric: metrics.Crawls{}, Direction: request.DirectionDESC},
+			{Dimension: dimensions.Crawler{}, Direction: request.DirectionASC},
+		},
+	}
+	r := q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Equal(t, pkg.TableCrawlers, q.primaryTable)
+	assert.Len(t, r.Results, 3)
+	assert.Equal(t, "GPTBot", r.Results[0].DimensionValues[0])
+	assert.Equal(t, uint64(3), r.Results[0].MetricValues[0])
+	assert.Equal(t, uint64(2), r.Results[0].MetricValues[1])
+	assert.Equal(t, "ClaudeBot", r.Results[1].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[1].MetricValues[0])
+	assert.Equal(t, "Googlebot", r.Results[2].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[2].MetricValues[1])
+
+	// crawls per path filtered by category
+	q, _, _ = newQuery()
+	req.Dimensions = []dimensions.Dimension{
+		dimensions.Path{},
+	}
+	req.Metrics = []metrics.Metric{
+		metrics.Crawls{},
+	}
+	req.Filter = []request.Filter{
+		{
+			Dimension: dimensions.CrawlerCategory{},
+			Values:    []any{"ai"},
+		},
+	}
+	req.OrderBy = []request.OrderBy{
+		{Dimension: dimensions.Path{}, Direction: request.DirectionASC},
+	}
+	r = q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Equal(t, pkg.TableCrawlers, q.primaryTable)
+	assert.Len(t, r.Results, 2)
+	assert.Equal(t, "/", r.Results[0].DimensionValues[0])
+	assert.Equal(t, uint64(3), r.Results[0].MetricValues[0])
+	assert.Equal(t, "/blog", r.Results[1].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[1].MetricValues[0])
+}
+
+func TestQueryCrawlerStatus(t *testing.T) {
+	db.CleanupDB(t, client)
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, client.SaveCrawlers(context.Background(), []model.Crawler{
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/", Crawler: "Googlebot", Category: "search", Status: "verified"},
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/blog", Crawler: "Googlebot", Category: "search", Status: "verified"},
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/", Crawler: "Googlebot", Category: "search", Status: "spoofed"},
+		{SiteID: 1, Time: now, Hostname: "example.com", Path: "/", Crawler: "ClaudeBot", Category: "ai", Status: "unverified"},
+		{SiteID: 2, Time: now, Hostname: "example.com", Path: "/", Crawler: "Googlebot", Category: "search", Status: "spoofed"},
+	}))
+	q, from, to := newQuery()
+	req := request.Request{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+		Dimensions: []dimensions.Dimension{
+			dimensions.CrawlerStatus{},
+		},
+		Metrics: []metrics.Metric{
+			metrics.Crawls{},
+		},
+		Filter: []request.Filter{
+			{
+				Dimension: dimensions.Crawler{},
+				Values:    []any{"Googlebot"},
+			},
+		},
+		OrderBy: []request.OrderBy{
+			{Dimension: dimensions.CrawlerStatus{}, Direction: request.DirectionASC},
+		},
+	}
+	r := q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Equal(t, pkg.TableCrawlers, q.primaryTable)
+	assert.Len(t, r.Results, 2)
+	assert.Equal(t, "spoofed", r.Results[0].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[0].MetricValues[0])
+	assert.Equal(t, "verified", r.Results[1].DimensionValues[0])
+	assert.Equal(t, uint64(2), r.Results[1].MetricValues[0])
+
+	// verified crawls per path
+	q, _, _ = newQuery()
+	req.Dimensions = []dimensions.Dimension{
+		dimensions.Path{},
+	}
+	req.Filter = []request.Filter{
+		{
+			Dimension: dimensions.CrawlerStatus{},
+			Values:    []any{"verified"},
+		},
+	}
+	req.OrderBy = []request.OrderBy{
+		{Dimension: dimensions.Path{}, Direction: request.DirectionASC},
+	}
+	r = q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Len(t, r.Results, 2)
+	assert.Equal(t, "/", r.Results[0].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[0].MetricValues[0])
+	assert.Equal(t, "/blog", r.Results[1].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[1].MetricValues[0])
+}
+
+func TestQueryGeoLocation(t *testing.T) {
+	db.CleanupDB(t, client)
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+	berlin := model.Data{SiteID: 1, Time: now, CountryCode: "de", ContinentCode: "eu", TimeZone: "Europe/Berlin", Latitude: 52.5, Longitude: 13.4, InEU: true}
+	newYork := model.Data{SiteID: 1, Time: now, CountryCode: "us", ContinentCode: "na", TimeZone: "America/New_York", Latitude: 40.7, Longitude: -74}
+	data := []model.Data{berlin, berlin, newYork}
+	sessions := make([]model.Session, 0, len(data))
+	pageViews := make([]model.PageView, 0, len(data))
+
+	for i, d := range data {
+		d.VisitorID = uint64(i + 1)
+		d.SessionID = uint32(i + 1)
+		sessions = append(sessions, model.Session{
+			Data:      d,
+			Sign:      1,
+			Version:   1,
+			Start:     d.Time,
+			EntryPath: "/",
+			ExitPath:  "/",
+			PageViews: 1,
+		})
+		pageViews = append(pageViews, model.PageView{
+			Data: d,
+			Path: "/",
+		})
+	}
+
+	assert.NoError(t, client.SaveSessions(context.Background(), sessions))
+	assert.NoError(t, client.SavePageViews(context.Background(), pageViews))
+	q, from, to := newQuery()
+	req := request.Request{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+		Dimensions: []dimensions.Dimension{
+			dimensions.Continent{},
+			dimensions.TimeZone{},
+			dimensions.Latitude{},
+			dimensions.Longitude{},
+			dimensions.InEU{},
+		},
+		Metrics: []metrics.Metric{
+			metrics.Visitors{},
+		},
+		OrderBy: []request.OrderBy{
+			{Metric: metrics.Visitors{}, Direction: request.DirectionDESC},
+		},
+	}
+	r := q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Equal(t, pkg.TableSessions, q.primaryTable)
+	assert.Len(t, r.Results, 2)
+	assert.Equal(t, []any{"eu", "Europe/Berlin", float32(52.5), float32(13.4), true}, r.Results[0].DimensionValues)
+	assert.Equal(t, uint64(2), r.Results[0].MetricValues[0])
+	assert.Equal(t, []any{"na", "America/New_York", float32(40.7), float32(-74), false}, r.Results[1].DimensionValues)
+	assert.Equal(t, uint64(1), r.Results[1].MetricValues[0])
+
+	// page views outside the EU per time zone
+	q, _, _ = newQuery()
+	req.Dimensions = []dimensions.Dimension{
+		dimensions.TimeZone{},
+	}
+	req.Metrics = []metrics.Metric{
+		metrics.PageViews{},
+	}
+	req.Filter = []request.Filter{
+		{
+			Dimension: dimensions.InEU{},
+			Values:    []any{false},
+		},
+	}
+	req.OrderBy = nil
+	r = q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Len(t, r.Results, 1)
+	assert.Equal(t, "America/New_York", r.Results[0].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[0].MetricValues[0])
+
+	// visitors filtered by continent
+	q, _, _ = newQuery()
+	req.Dimensions = []dimensions.Dimension{
+		dimensions.Continent{},
+	}
+	req.Metrics = []metrics.Metric{
+		metrics.Visitors{},
+	}
+	req.Filter = []request.Filter{
+		{
+			Dimension: dimensions.Continent{},
+			Values:    []any{"eu"},
+		},
+	}
+	r = q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Len(t, r.Results, 1)
+	assert.Equal(t, "eu", r.Results[0].DimensionValues[0])
+	assert.Equal(t, uint64(2), r.Results[0].MetricValues[0])
+}
+
+func TestQueryReturning(t *testing.T) {
+	db.CleanupDB(t, client)
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+	// the first visitor returns the next day, the second visitor is new
+	data := []model.Data{
+		{SiteID: 1, VisitorID: 1, SessionID: 1, Time: now},
+		{SiteID: 1, VisitorID: 1, SessionID: 2, Time: now.Add(time.Hour * 24), IsReturning: true},
+		{SiteID: 1, VisitorID: 2, SessionID: 3, Time: now.Add(time.Hour * 24)},
+	}
+	sessions := make([]model.Session, 0, len(data))
+	pageViews := make([]model.PageView, 0, len(data))
+
+	for _, d := range data {
+		sessions = append(sessions, model.Session{
+			Data:      d,
+			Sign:      1,
+			Version:   1,
+			Start:     d.Time,
+			EntryPath: "/",
+			ExitPath:  "/",
+			PageViews: 1,
+		})
+		pageViews = append(pageViews, model.PageView{
+			Data: d,
+			Path: "/",
+		})
+	}
+
+	assert.NoError(t, client.SaveSessions(context.Background(), sessions))
+	assert.NoError(t, client.SavePageViews(context.Background(), pageViews))
+	q, from, to := newQuery()
+	req := request.Request{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+		Dimensions: []dimensions.Dimension{
+			dimensions.Returning{},
+		},
+		Metrics: []metrics.Metric{
+			metrics.Visitors{},
+			metrics.Sessions{},
+		},
+		OrderBy: []request.OrderBy{
+			{Dimension: dimensions.Returning{}, Direction: request.DirectionASC},
+		},
+	}
+	r := q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Equal(t, pkg.TableSessions, q.primaryTable)
+	assert.Len(t, r.Results, 2)
+	assert.Equal(t, false, r.Results[0].DimensionValues[0])
+	assert.Equal(t, uint64(2), r.Results[0].MetricValues[0])
+	assert.Equal(t, uint64(2), r.Results[0].MetricValues[1])
+	assert.Equal(t, true, r.Results[1].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[1].MetricValues[0])
+	assert.Equal(t, uint64(1), r.Results[1].MetricValues[1])
+
+	// returning page views per path
+	q, _, _ = newQuery()
+	req.Dimensions = []dimensions.Dimension{
+		dimensions.Path{},
+	}
+	req.Metrics = []metrics.Metric{
+		metrics.PageViews{},
+	}
+	req.Filter = []request.Filter{
+		{
+			Dimension: dimensions.Returning{},
+			Values:    []any{true},
+		},
+	}
+	req.OrderBy = nil
+	r = q.Run(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Len(t, r.Results, 1)
+	assert.Equal(t, "/", r.Results[0].DimensionValues[0])
+	assert.Equal(t, uint64(1), r.Results[0].MetricValues[0])
+}
+
+func TestQueryFunnelStitchUsers(t *testing.T) {
+	db.CleanupDB(t, client)
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+	// the user visits the pricing page on one device and signs up on another,
+	// the anonymous visitor does the same in two different sessions
+	data := []model.Data{
+		{SiteID: 1, VisitorID: 1, SessionID: 1, UserID: 42, Time: now},
+		{SiteID: 1, VisitorID: 2, SessionID: 2, UserID: 42, Time: now.Add(time.Hour)},
+		{SiteID: 1, VisitorID: 3, SessionID: 3, Time: now},
+		{SiteID: 1, VisitorID: 3, SessionID: 4, Time: now.Add(time.Hour)},
+	}
+	paths := []string{"/pricing", "/signup", "/pricing", "/signup"}
+	sessions := make([]model.Session, 0, len(data))
+	pageViews := make([]model.PageView, 0, len(data))
+
+	for i, d := range data {
+		sessions = append(sessions, model.Session{
+			Data:      d,
+			Sign:      1,
+			Version:   1,
+			Start:     d.Time,
+			EntryPath: paths[i],
+			ExitPath:  paths[i],
+			PageViews: 1,
+		})
+		pageViews = append(pageViews, model.PageView{
+			Data: d,
+			Path: paths[i],
+		})
+	}
+
+	assert.NoError(t, client.SaveSessions(context.Background(), sessions))
+	assert.NoError(t, client.SavePageViews(context.Background(), pageViews))
+	q, from, to := newQuery()
+	req := request.FunnelRequest{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+		Filter: [][]request.Filter{
+			{
+				{
+					Dimension: dimensions.Path{},
+					Values:    []any{"/pricing"},
+				},
+			},
+			{
+				{
+					Dimension: dimensions.Path{},
+					Values:    []any{"/signup"},
+				},
+			},
+		},
+	}
+	r := q.Funnel(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Len(t, r.Steps, 2)
+	assert.Equal(t, uint64(2), r.Steps[0].Visitors)
+	assert.Equal(t, uint64(0), r.Steps[1].Visitors)
+
+	// the user must be counted once and complete the funnel across devices
+	req.StitchUsers = true
+	r = q.Funnel(req)
+	assert.Empty(t, r.Meta.Errors)
+	assert.Len(t, r.Steps, 2)
+	assert.Equal(t, uint64(2), r.Steps[0].Visitors)
+	assert.Equal(t, uint64(1), r.Steps[1].Visitors)
+
+	// unique users per user ID
+	q, _, _ = newQuery()
+	res := q.Run(request.Request{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+		Metrics: []metrics.Metric{
+			metrics.Visitors{},
+			metrics.Users{},
+		},
+	})
+	assert.Empty(t, res.Meta.Errors)
+	assert.Len(t, res.Results, 1)
+	assert.Equal(t, uint64(3), res.Results[0].MetricValues[0])
+	assert.Equal(t, uint64(1), res.Results[0].MetricValues[1])
+}
+
+func TestQueryFunnelStitchUsersLogin(t *testing.T) {
+	db.CleanupDB(t, client)
+	now := time.Date(2026, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+	// the visitor views the pricing page anonymously, logs in within the same session, and signs up on another device
+	anonymous := model.Data{SiteID: 1, VisitorID: 1, SessionID: 1, Time: now}
+	login := model.Data{SiteID: 1, VisitorID: 1, SessionID: 1, UserID: 42, Time: now.Add(time.Minute)}
+	device := model.Data{SiteID: 1, VisitorID: 2, SessionID: 2, UserID: 42, Time: now.Add(time.Hour)}
+	assert.NoError(t, client.SaveSessions(context.Background(), []model.Session{
+		{Data: anonymous, Sign: 1, Version: 1, Start: now, EntryPath: "/pricing", ExitPath: "/pricing", PageViews: 1},
+		{Data: anonymous, Sign: -1, Version: 1, Start: now, EntryPath: "/pricing", ExitPath: "/pricing", PageViews: 1},
+		{Data: login, Sign: 1, Version: 2, Start: now, EntryPath: "/pricing", ExitPath: "/account", PageViews: 2},
+		{Data: device, Sign: 1, Version: 1, Start: device.Time, EntryPath: "/signup", ExitPath: "/signup", PageViews: 1},
+	}))
+	assert.NoError(t, client.SavePageViews(context.Background(), []model.PageView{
+		{Data: anonymous, Path: "/pricing"},
+		{Data: login, Path: "/account"},
+		{Data: device, Path: "/signup"},
+	}))
+	q, from, to := newQuery()
+	r := q.Funnel(request.FunnelRequest{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+		Filter: [][]request.Filter{
+			{
+				{
+					Dimension: dimensions.Path{},
+					Values:    []any{"/pricing"},
+				},
+			},
+			{
+				{
+					Dimension: dimensions.Path{},
+					Values:    []any{"/signup"},
+				},
+			},
+		},
+		StitchUsers: true,
+	})
+	assert.Empty(t, r.Meta.Errors)
+	assert.Len(t, r.Steps, 2)
+	assert.Equal(t, uint64(1), r.Steps[0].Visitors)
+	assert.Equal(t, uint64(1), r.Steps[1].Visitors)
+
+	// page views before the login belong to the user
+	q, _, _ = newQuery()
+	res := q.Run(request.Request{
+		SiteID: 1,
+		Period: request.Period{
+			From: from,
+			To:   to,
+		},
+		Dimensions: []dimensions.Dimension{
+			dimensions.PersonID{},
+			dimensions.Path{},
+		},
+		Metrics: []metrics.Metric{
+			metrics.PageViews{},
+		},
+	})
+	assert.Empty(t, res.Meta.Errors)
+	assert.Len(t, res.Results, 3)
+
+	for _, result := range res.Results {
+		assert.Equal(t, uint64(42), result.DimensionValues[0])
+	}
+}
+
 func TestBuildQueryFilterJSONPath(t *testing.T) {
 	input := []string{
 		"field",
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"github.com/pirsch-analytics/pirsch/v7/pkg/reporting/request"
+)
+
+// ShadowReport compares the decisions of bot filters running in shadow mode against the actual decisions.
+type ShadowReport struct {
+	// Request is the request.ShadowRequest for this report.
+	Request request.ShadowRequest
+
+	// Requests is the total number of requests.
+	Requests uint64
+
+	// Bots is the total number of requests that have been blocked.
+	Bots uint64
+
+	// Reasons is the list of shadow decisions ordered by the number of requests.
+	Reasons []ShadowReason
+
+	// Meta contains metadata information for this report.
+	Meta Meta
+}
+
+// ShadowReason is the statistics for a shadow bot reason.
+type ShadowReason struct {
+	// Reason is the bot reason the request would have been blocked for.
+	Reason string
+
+	// Requests is the number of requests flagged in shadow mode.
+	Requests uint64
+
+	// Visitors is the unique number of visitors flagged in shadow mode.
+	Visitors uint64
+
+	// Bots is the number of flagged requests that have also been blocked by an enforced filter.
+	Bots uint64
+
+	// Passed is the number of flagged requests that have been accepted and would be lost when enforcing the filter.
+	Passed uint64
+
+	// RelativePassed is the number of flagged requests that have been accepted relative to all accepted requests.
+	RelativePassed float64
+}
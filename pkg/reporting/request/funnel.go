@@ -23,6 +23,11 @@ type FunnelRequest struct {
 	// To use other operators, they need to be set in the Filter recursively.
 	Filter [][]Filter
 
+	// StitchUsers connects the funnel steps by the user ID instead of the session, if the visitor has one.
+	// Users completing the steps in different sessions or on different devices are counted once.
+	// Visitors without a user ID must still complete all steps within the same session.
+	StitchUsers bool
+
 	// Options are optional fields for the FunnelRequest.
 	Options *Options
 }
@@ -0,0 +1,43 @@
+package request
+
+import (
+	"context"
+	"time"
+)
+
+// ShadowRequest generates a bot filter shadow report.
+type ShadowRequest struct {
+	// Ctx can be used to set a timeout or to cancel queries.
+	Ctx context.Context
+
+	// SiteID is the site ID for the request.
+	SiteID uint64
+
+	// Period is the period and timezone for the report.ShadowReport.
+	Period Period
+}
+
+// Validate validates the ShadowRequest and returns an error if a report.ShadowReport cannot be constructed for the specified fields.
+func (r *ShadowRequest) Validate() []error {
+	if r.Ctx == nil {
+		r.Ctx = context.Background()
+	}
+
+	if r.Period.Timezone == nil {
+		r.Period.Timezone = time.UTC
+	}
+
+	if r.Period.WeekdayMode == 0 {
+		r.Period.WeekdayMode = WeekdayMonday
+	}
+
+	if r.Period.From.After(r.Period.To) {
+		r.Period.From, r.Period.To = r.Period.To, r.Period.From
+	}
+
+	if err := validateSiteID(r.SiteID); err != nil {
+		return []error{err}
+	}
+
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 	"os/exec"
 	"sort"
 	"strings"
+
+	"github.com/pirsch-analytics/pirsch/v7/pkg/ingest/crawler"
 )
 
 // run this script from the root directory to update the blacklist.go
@@ -39,6 +41,14 @@ func main() {
 
 	entries := readLines(list)
 	regexEntries := readLines(regexList)
+
+	// all known crawlers must be filtered as well
+	for _, c := range crawler.Crawlers {
+		for _, keyword := range c.UserAgent {
+			entries[strings.ToLower(keyword)] = struct{}{}
+		}
+	}
+
 	ua := make([]string, 0, len(entries))
 	uaRegex := make([]string, 0, len(regexEntries))
 